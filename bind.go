@@ -0,0 +1,143 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"context"
+	"encoding"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	paramDecoderType    = reflect.TypeOf((*ParamDecoder)(nil)).Elem()
+)
+
+// ParamDecoder is implemented by types which decode themselves from the value
+// of a param, see BindParams.
+type ParamDecoder interface {
+	DecodeParam(value string) error
+}
+
+// BindParams sets the fields of the struct dst points to to the values of the
+// params in the request context, see ParamsFromContext. The param of a field
+// is given by its param or route tag:
+//  type UserParams struct {
+//      ID     int    `param:"id"`
+//      Name   string `route:"name"`
+//      Active bool   `param:"active"`
+//  }
+//
+//  err := httprouter.BindParams(req.Context(), &ps)
+// Fields without a param or route tag are left unchanged. Values are converted
+// to the type of the field, which may be a string, bool, integer, a type
+// implementing ParamDecoder or encoding.TextUnmarshaler, in this order of
+// precedence, or a 16 byte array for UUIDs in their canonical form, e.g.
+// 123e4567-e89b-12d3-a456-426614174000.
+// It returns an error if a param is missing or can't be converted.
+func BindParams(ctx context.Context, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return errors.New("httprouter: BindParams requires a non-nil pointer to a struct")
+	}
+	v = v.Elem()
+	ps := ParamsFromContext(ctx)
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("param")
+		if name == "" {
+			name = field.Tag.Get("route")
+		}
+		if name == "" || name == "-" {
+			continue
+		}
+		if field.PkgPath != "" {
+			return fmt.Errorf("httprouter: param %s bound to unexported field %s", name, field.Name)
+		}
+
+		value, ok := lookupParam(ps, name)
+		if !ok {
+			return fmt.Errorf("httprouter: missing param %s", name)
+		}
+		if err := setField(v.Field(i), value); err != nil {
+			return fmt.Errorf("httprouter: invalid value of param %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// lookupParam returns the value of the first param with the given name and
+// whether there is such a param.
+func lookupParam(ps Params, name string) (string, bool) {
+	for _, p := range ps {
+		if p.Key == name {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+// setField sets the field to the value converted to the type of the field.
+func setField(field reflect.Value, value string) error {
+	if reflect.PtrTo(field.Type()).Implements(paramDecoderType) {
+		return field.Addr().Interface().(ParamDecoder).DecodeParam(value)
+	}
+	if reflect.PtrTo(field.Type()).Implements(textUnmarshalerType) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Array:
+		if field.Len() != 16 || field.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported field type %s", field.Type())
+		}
+		uuid, err := parseUUID(value)
+		if err != nil {
+			return err
+		}
+		reflect.Copy(field, reflect.ValueOf(uuid[:]))
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+// parseUUID parses a UUID in its canonical form.
+func parseUUID(s string) (uuid [16]byte, err error) {
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return uuid, fmt.Errorf("invalid UUID %q", s)
+	}
+	hexDigits := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:36]
+	if _, err := hex.Decode(uuid[:], []byte(hexDigits)); err != nil {
+		return uuid, fmt.Errorf("invalid UUID %q", s)
+	}
+	return uuid, nil
+}
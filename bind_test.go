@@ -0,0 +1,166 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testUUID [16]byte
+
+type upperString string
+
+func (s *upperString) UnmarshalText(text []byte) error {
+	*s = upperString(strings.ToUpper(string(text)))
+	return nil
+}
+
+// sortKey decodes both as a param and as text, see BindParams.
+type sortKey struct {
+	field string
+	desc  bool
+}
+
+func (k *sortKey) DecodeParam(value string) error {
+	if value == "" {
+		return errors.New("empty sort key")
+	}
+	k.desc = value[0] == '-'
+	k.field = strings.TrimPrefix(value, "-")
+	return nil
+}
+
+func (k *sortKey) UnmarshalText(text []byte) error {
+	return errors.New("not used")
+}
+
+func TestBindParams(t *testing.T) {
+	type orderParams struct {
+		ID       int         `param:"id"`
+		Name     string      `route:"name"`
+		Active   bool        `param:"active"`
+		Version  uint8       `param:"version"`
+		Token    testUUID    `param:"token"`
+		Region   upperString `param:"region"`
+		Sort     sortKey     `route:"sort"`
+		Ignored  string
+		Excluded string `param:"-"`
+		Skipped  string `route:"-"`
+	}
+
+	ps := Params{
+		{"id", "42"},
+		{"name", "gopher"},
+		{"active", "true"},
+		{"version", "3"},
+		{"token", "123e4567-e89b-12d3-a456-426614174000"},
+		{"region", "eu"},
+		{"sort", "-created"},
+		{"Excluded", "x"},
+		{"Skipped", "x"},
+	}
+
+	var dst orderParams
+	dst.Ignored = "unchanged"
+	if err := BindParams(WithParams(context.Background(), ps), &dst); err != nil {
+		t.Fatalf("binding failed: %v", err)
+	}
+
+	want := orderParams{
+		ID:      42,
+		Name:    "gopher",
+		Active:  true,
+		Version: 3,
+		Token: testUUID{
+			0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3,
+			0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00,
+		},
+		Region:  "EU",
+		Sort:    sortKey{field: "created", desc: true},
+		Ignored: "unchanged",
+	}
+	if dst != want {
+		t.Errorf("wrong binding:\n got: %+v\nwant: %+v", dst, want)
+	}
+}
+
+func TestBindParamsErrors(t *testing.T) {
+	type intParams struct {
+		ID int `param:"id"`
+	}
+	type uintParams struct {
+		N uint8 `param:"n"`
+	}
+	type boolParams struct {
+		B bool `param:"b"`
+	}
+	type uuidParams struct {
+		U testUUID `param:"u"`
+	}
+	type floatParams struct {
+		F float64 `param:"f"`
+	}
+	type sortParams struct {
+		S sortKey `route:"s"`
+	}
+
+	tests := []struct {
+		name string
+		ps   Params
+		dst  interface{}
+	}{
+		{"missing param", nil, &intParams{}},
+		{"invalid int", Params{{"id", "abc"}}, &intParams{}},
+		{"empty int", Params{{"id", ""}}, &intParams{}},
+		{"overflow", Params{{"n", "256"}}, &uintParams{}},
+		{"invalid bool", Params{{"b", "maybe"}}, &boolParams{}},
+		{"invalid uuid", Params{{"u", "123e4567-e89b-12d3-a456"}}, &uuidParams{}},
+		{"invalid uuid digits", Params{{"u", "123e4567-e89b-12d3-a456-42661417400g"}}, &uuidParams{}},
+		{"unsupported type", Params{{"f", "1.5"}}, &floatParams{}},
+		{"decoder error", Params{{"s", ""}}, &sortParams{}},
+		{"missing route param", Params{{"id", "1"}}, &sortParams{}},
+		{"no pointer", Params{{"id", "1"}}, intParams{}},
+		{"nil pointer", Params{{"id", "1"}}, (*intParams)(nil)},
+	}
+	for _, test := range tests {
+		if err := BindParams(WithParams(context.Background(), test.ps), test.dst); err == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+	}
+}
+
+func TestBindParamsHandler(t *testing.T) {
+	type userParams struct {
+		ID   int    `param:"id"`
+		Name string `param:"name"`
+	}
+
+	var got userParams
+	router := New()
+	router.Handler(http.MethodGet, "/users/@id/@name", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := BindParams(req.Context(), &got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+
+	r, _ := http.NewRequest(http.MethodGet, "/users/7/gopher", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || got != (userParams{7, "gopher"}) {
+		t.Errorf("binding in handler failed: Code=%d, got %+v", w.Code, got)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/users/x/gopher", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid param not rejected: Code=%d", w.Code)
+	}
+}
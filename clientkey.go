@@ -0,0 +1,45 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// clientKey returns the key identifying the client of a request, see
+// Router.ClientKeyFunc.
+func (r *Router) clientKey(req *http.Request) string {
+	if r.ClientKeyFunc != nil {
+		return r.ClientKeyFunc(req)
+	}
+	return r.clientIP(req)
+}
+
+// clientIP returns the IP address of the client of a request, see
+// Router.ClientIPHeader and Router.TrustedProxies.
+func (r *Router) clientIP(req *http.Request) string {
+	if r.ClientIPHeader != "" {
+		if value := req.Header.Get(r.ClientIPHeader); value != "" {
+			// Each proxy appends the address it received the request from,
+			// so only the addresses on the right can be trusted
+			hops := strings.Split(value, ",")
+			i := len(hops) - 1
+			if r.TrustedProxies > 1 {
+				i = len(hops) - r.TrustedProxies
+			}
+			if i < 0 {
+				i = 0
+			}
+			return strings.TrimSpace(hops[i])
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,122 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRouterClientKeyFunc(t *testing.T) {
+	var key string
+	handle := func(_ http.ResponseWriter, r *http.Request, _ Params) {
+		key = ContextClientKey(r.Context())
+	}
+
+	router := New()
+	router.GET("/plain", handle)
+	router.GET("/limited", handle).RateLimit(1, time.Hour)
+
+	// without ClientKeyFunc, only rate limited routes get the IP address
+	tests := []struct {
+		path string
+		key  string
+	}{
+		{"/plain", ""},
+		{"/limited", "192.0.2.1"},
+	}
+	for _, test := range tests {
+		key = ""
+		r := httptest.NewRequest(http.MethodGet, test.path, nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		if key != test.key {
+			t.Errorf("%s: wrong client key %q, want %q", test.path, key, test.key)
+		}
+	}
+
+	// the address appended by the first trusted proxy, regardless of the
+	// addresses sent by the client
+	router = New()
+	router.ClientIPHeader = "X-Forwarded-For"
+	router.GET("/limited", handle).RateLimit(1, time.Hour)
+
+	ipTests := []struct {
+		proxies      int
+		forwardedFor string
+		key          string
+	}{
+		{0, "198.51.100.7", "198.51.100.7"},
+		{0, "203.0.113.9, 198.51.100.11", "198.51.100.11"},
+		{1, "203.0.113.9 , 198.51.100.8", "198.51.100.8"},
+		{2, "203.0.113.9, 198.51.100.9, 10.0.0.2", "198.51.100.9"},
+		{3, "198.51.100.10, 10.0.0.2", "198.51.100.10"},
+	}
+	for _, test := range ipTests {
+		key = ""
+		router.TrustedProxies = test.proxies
+		r := httptest.NewRequest(http.MethodGet, "/limited", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("X-Forwarded-For", test.forwardedFor)
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		if key != test.key {
+			t.Errorf("TrustedProxies=%d, X-Forwarded-For %q: wrong client key %q, want %q", test.proxies, test.forwardedFor, key, test.key)
+		}
+	}
+
+	// the last address of X-Forwarded-For not added by a trusted proxy
+	trusted := map[string]bool{"10.0.0.1": true, "10.0.0.2": true}
+	router = New()
+	router.ClientKeyFunc = func(r *http.Request) string {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		if !trusted[host] {
+			return host
+		}
+		hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			if hop := strings.TrimSpace(hops[i]); hop != "" && !trusted[hop] {
+				return hop
+			}
+		}
+		return host
+	}
+	router.GET("/plain", handle)
+	router.GET("/limited", handle).RateLimit(1, time.Hour)
+
+	keyTests := []struct {
+		path         string
+		remoteAddr   string
+		forwardedFor string
+		key          string
+		code         int
+	}{
+		{"/plain", "192.0.2.1:1234", "", "192.0.2.1", http.StatusOK},
+		{"/plain", "192.0.2.1:1234", "198.51.100.7", "192.0.2.1", http.StatusOK},
+		{"/plain", "10.0.0.1:1234", "203.0.113.9, 198.51.100.7, 10.0.0.2", "198.51.100.7", http.StatusOK},
+		{"/limited", "10.0.0.1:1234", "198.51.100.7", "198.51.100.7", http.StatusOK},
+		{"/limited", "10.0.0.2:1234", "198.51.100.7", "198.51.100.7", http.StatusTooManyRequests},
+		{"/limited", "10.0.0.1:1234", "203.0.113.9", "203.0.113.9", http.StatusOK},
+	}
+	for _, test := range keyTests {
+		key = ""
+		r := httptest.NewRequest(http.MethodGet, test.path, nil)
+		r.RemoteAddr = test.remoteAddr
+		if test.forwardedFor != "" {
+			r.Header.Set("X-Forwarded-For", test.forwardedFor)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("%s from %s (X-Forwarded-For %q): Code=%d, want %d", test.path, test.remoteAddr, test.forwardedFor, w.Code, test.code)
+		}
+		if test.code == http.StatusOK && key != test.key {
+			t.Errorf("%s from %s (X-Forwarded-For %q): wrong client key %q, want %q", test.path, test.remoteAddr, test.forwardedFor, key, test.key)
+		}
+	}
+}
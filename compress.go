@@ -0,0 +1,160 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+)
+
+// Compress compresses the responses of this route with gzip if the client
+// accepts it, as indicated by the Accept-Encoding request header.
+// Responses which already have a Content-Encoding, responses without a body
+// and responses with a content type which is already compressed, like images,
+// videos and archives, are sent unchanged.
+func (rt *Route) Compress() *Route {
+	rt.compress = true
+	return rt
+}
+
+// acceptsGzip reports whether the Accept-Encoding header of the request
+// contains gzip with a non-zero quality.
+func acceptsGzip(req *http.Request) bool {
+	for _, header := range req.Header["Accept-Encoding"] {
+		for _, coding := range strings.Split(header, ",") {
+			coding = strings.TrimSpace(coding)
+			params := ""
+			if i := strings.IndexByte(coding, ';'); i >= 0 {
+				coding, params = strings.TrimSpace(coding[:i]), coding[i+1:]
+			}
+			if !strings.EqualFold(coding, "gzip") && !strings.EqualFold(coding, "x-gzip") {
+				continue
+			}
+			return !zeroQuality(params)
+		}
+	}
+	return false
+}
+
+// isCompressedType reports whether responses of the given content type are
+// already compressed, so that compressing them again is a waste of time.
+func isCompressedType(contentType string) bool {
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+
+	switch {
+	case contentType == "image/svg+xml":
+		return false
+	case strings.HasPrefix(contentType, "image/"),
+		strings.HasPrefix(contentType, "video/"),
+		strings.HasPrefix(contentType, "audio/"),
+		strings.HasPrefix(contentType, "font/woff"):
+		return true
+	}
+	switch contentType {
+	case "application/gzip",
+		"application/x-gzip",
+		"application/zip",
+		"application/x-bzip2",
+		"application/x-xz",
+		"application/x-7z-compressed",
+		"application/x-rar-compressed",
+		"application/zstd",
+		"application/pdf":
+		return true
+	}
+	return false
+}
+
+// gzipResponseWriter compresses the body of a response with gzip. The header
+// is held back until the first non-empty write, so that responses without a
+// body are sent unchanged.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	req         *http.Request
+	gz          *gzip.Writer
+	code        int // status code passed to WriteHeader, if any
+	wroteHeader bool
+}
+
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader || w.code != 0 {
+		return
+	}
+	w.code = code
+	if w.req.Method == http.MethodHead ||
+		code == http.StatusNoContent || code == http.StatusNotModified {
+		// There is no body to compress
+		w.writeHeader(false)
+	}
+}
+
+// writeHeader sends the header, with or without compressing the body as
+// decided by the header and the status code.
+func (w *gzipResponseWriter) writeHeader(compress bool) {
+	w.wroteHeader = true
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+
+	h := w.Header()
+	if compress && w.req.Method != http.MethodHead &&
+		w.code != http.StatusNoContent && w.code != http.StatusNotModified &&
+		h.Get("Content-Encoding") == "" && !isCompressedType(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.code)
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		if len(b) == 0 {
+			return 0, nil
+		}
+		h := w.Header()
+		if h.Get("Content-Type") == "" {
+			// detect the content type before compressing, like the
+			// ResponseWriter of net/http would do on the uncompressed data
+			h.Set("Content-Type", http.DetectContentType(b))
+		}
+		w.writeHeader(true)
+	}
+	if w.gz == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.gz.Write(b)
+}
+
+// Flush flushes the data compressed so far to the client.
+func (w *gzipResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.writeHeader(true)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// close finishes the compressed response, if any, or sends the header held
+// back for a response without a body.
+func (w *gzipResponseWriter) close() {
+	if !w.wroteHeader {
+		if w.code != 0 {
+			w.writeHeader(false)
+		}
+		return
+	}
+	if w.gz != nil {
+		w.gz.Close()
+	}
+}
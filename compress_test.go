@@ -0,0 +1,193 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouteCompress(t *testing.T) {
+	body := strings.Repeat(`{"name":"gopher"}`, 100)
+
+	router := New()
+	router.GET("/json", func(w http.ResponseWriter, _ *http.Request, _ Params) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}).Compress()
+	router.HEAD("/json", func(w http.ResponseWriter, _ *http.Request, _ Params) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+	}).Compress()
+	router.GET("/png", func(w http.ResponseWriter, _ *http.Request, _ Params) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte(body))
+	}).Compress()
+	router.GET("/stream", func(w http.ResponseWriter, _ *http.Request, _ Params) {
+		w.Write([]byte("first"))
+		w.(http.Flusher).Flush()
+		w.Write([]byte("second"))
+	}).Compress()
+	router.GET("/nocontent", func(w http.ResponseWriter, _ *http.Request, _ Params) {
+		w.WriteHeader(http.StatusNoContent)
+	}).Compress()
+	router.GET("/empty", func(w http.ResponseWriter, _ *http.Request, _ Params) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(nil)
+	}).Compress()
+	router.POST("/created", func(w http.ResponseWriter, _ *http.Request, _ Params) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(body))
+	}).Compress()
+	router.GET("/plain", func(w http.ResponseWriter, _ *http.Request, _ Params) {
+		w.Write([]byte(body))
+	})
+
+	serveMethod := func(method, path, acceptEncoding string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, path, nil)
+		if acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+	serve := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		return serveMethod(http.MethodGet, path, acceptEncoding)
+	}
+	decode := func(w *httptest.ResponseRecorder) string {
+		gz, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatalf("invalid gzip response: %v", err)
+		}
+		b, err := ioutil.ReadAll(gz)
+		if err != nil {
+			t.Fatalf("invalid gzip response: %v", err)
+		}
+		return string(b)
+	}
+
+	// compressed when accepted
+	w := serve("/json", "deflate, gzip")
+	if enc := w.Header().Get("Content-Encoding"); enc != "gzip" {
+		t.Fatalf("wrong Content-Encoding: %q", enc)
+	}
+	if vary := w.Header().Get("Vary"); vary != "Accept-Encoding" {
+		t.Errorf("wrong Vary header: %q", vary)
+	}
+	if w.Body.Len() >= len(body) {
+		t.Errorf("response not compressed: %d bytes", w.Body.Len())
+	}
+	if got := decode(w); got != body {
+		t.Errorf("wrong decompressed body: %q", got)
+	}
+
+	// passthrough when not accepted
+	for _, accept := range []string{"", "deflate", "gzip;q=0"} {
+		w = serve("/json", accept)
+		if enc := w.Header().Get("Content-Encoding"); enc != "" {
+			t.Errorf("Accept-Encoding %q: unexpected Content-Encoding %q", accept, enc)
+		}
+		if vary := w.Header().Get("Vary"); vary != "Accept-Encoding" {
+			t.Errorf("Accept-Encoding %q: wrong Vary header: %q", accept, vary)
+		}
+		if w.Body.String() != body {
+			t.Errorf("Accept-Encoding %q: wrong body", accept)
+		}
+	}
+
+	// already compressed content types are not compressed again
+	w = serve("/png", "gzip")
+	if enc := w.Header().Get("Content-Encoding"); enc != "" {
+		t.Errorf("unexpected Content-Encoding for image: %q", enc)
+	}
+	if w.Body.String() != body {
+		t.Error("wrong body for image")
+	}
+
+	// flushing
+	w = serve("/stream", "gzip")
+	if !w.Flushed {
+		t.Error("response not flushed")
+	}
+	if got := decode(w); got != "firstsecond" {
+		t.Errorf("wrong decompressed body: %q", got)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("wrong detected Content-Type: %q", ct)
+	}
+
+	// responses without a body are sent unchanged
+	tests := []struct {
+		method, path string
+		code         int
+	}{
+		{http.MethodGet, "/nocontent", http.StatusNoContent},
+		{http.MethodGet, "/empty", http.StatusOK},
+		{http.MethodHead, "/json", http.StatusOK},
+	}
+	for _, test := range tests {
+		w = serveMethod(test.method, test.path, "gzip")
+		if w.Code != test.code {
+			t.Errorf("%s %s: wrong status code: %d", test.method, test.path, w.Code)
+		}
+		if enc := w.Header().Get("Content-Encoding"); enc != "" {
+			t.Errorf("%s %s: unexpected Content-Encoding %q", test.method, test.path, enc)
+		}
+		if test.method != http.MethodHead && w.Body.Len() != 0 {
+			t.Errorf("%s %s: unexpected body: %q", test.method, test.path, w.Body.String())
+		}
+	}
+
+	// the status code is kept when the body is compressed
+	w = serveMethod(http.MethodPost, "/created", "gzip")
+	if w.Code != http.StatusCreated || w.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("wrong response: Code=%d, Content-Encoding=%q", w.Code, w.Header().Get("Content-Encoding"))
+	}
+	if got := decode(w); got != body {
+		t.Errorf("wrong decompressed body: %q", got)
+	}
+
+	// routes without Compress are not affected
+	w = serve("/plain", "gzip")
+	if enc := w.Header().Get("Content-Encoding"); enc != "" {
+		t.Errorf("unexpected Content-Encoding: %q", enc)
+	}
+	if vary := w.Header().Get("Vary"); vary != "" {
+		t.Errorf("unexpected Vary header: %q", vary)
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"GZIP", true},
+		{"x-gzip", true},
+		{"deflate, gzip;q=1.0, *;q=0.5", true},
+		{"gzip; q=0.5", true},
+		{"gzip;q=0", false},
+		{"gzip;q=0.000", false},
+		{"br, deflate", false},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		if test.header != "" {
+			r.Header.Set("Accept-Encoding", test.header)
+		}
+		if got := acceptsGzip(r); got != test.want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", test.header, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"context"
+	"net/http"
+)
+
+// contextKey is the type of the keys of the unexported values the router
+// stores in request contexts.
+type contextKey int
+
+const (
+	outerParamsContextKey contextKey = iota
+	routeContextKey
+	skipContextKey
+	clientKeyContextKey
+	panicStackContextKey
+	matchedRouteContextKey
+	appliedMiddlewareContextKey
+	lookupDepthContextKey
+)
+
+type paramsKey struct{}
+
+// ParamsKey is the request context key under which URL params are stored.
+var ParamsKey = paramsKey{}
+
+// ContextParams returns the URL params from a request context, or nil if
+// none are present. It is the same as ParamsFromContext.
+func ContextParams(ctx context.Context) Params {
+	p, _ := ctx.Value(ParamsKey).(Params)
+	return p
+}
+
+// ParamsFromContext pulls the URL parameters from a request context,
+// or returns nil if none are present.
+func ParamsFromContext(ctx context.Context) Params {
+	return ContextParams(ctx)
+}
+
+// WithParams returns a copy of ctx carrying the given URL parameters, which
+// can be retrieved by ParamsFromContext.
+func WithParams(ctx context.Context, ps Params) context.Context {
+	return context.WithValue(ctx, ParamsKey, ps)
+}
+
+// NewRequestWithParams returns a shallow copy of req with the given URL
+// parameters in its context, like the router passes them to a http.Handler.
+// It is meant to test handlers without a router.
+func NewRequestWithParams(req *http.Request, ps Params) *http.Request {
+	return req.WithContext(WithParams(req.Context(), ps))
+}
+
+// ContextMatchedRoute returns the route matching the request from a request
+// context, or nil if it is not present. Its pattern and method, see Route.Path
+// and Route.Method, let middleware like metrics or logging group requests by
+// route rather than by the raw path.
+// The route is available to the middleware of the router and of the route,
+// see Router.Use and RouteSpec.Middleware, and to the handles they wrap.
+// Global middleware, see Router.UseGlobal, runs before the route is matched,
+// but can get it once the request was passed on to the router and served.
+func ContextMatchedRoute(ctx context.Context) *Route {
+	if rt, ok := ctx.Value(routeContextKey).(*Route); ok {
+		return rt
+	}
+	if slot, ok := ctx.Value(matchedRouteContextKey).(*routeSlot); ok {
+		return slot.route
+	}
+	return nil
+}
+
+// routeSlot receives the route matching a request for the global middleware,
+// see ContextMatchedRoute.
+type routeSlot struct {
+	route *Route
+}
+
+// ContextClientKey returns the key of the client of a request from the request
+// context, see Router.ClientKeyFunc, or an empty string if the key was not
+// computed for the request.
+func ContextClientKey(ctx context.Context) string {
+	key, _ := ctx.Value(clientKeyContextKey).(string)
+	return key
+}
+
+// AppliedMiddleware returns the names of the middleware applied to a request
+// so far, in the order of execution, from a request context.
+// The name of a middleware is the name of its function, e.g. "main.Logging".
+// Anonymous functions get a synthesized name like "main.main.func1".
+func AppliedMiddleware(ctx context.Context) []string {
+	p, _ := ctx.Value(appliedMiddlewareContextKey).(*[]string)
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+// ContextLookupDepth returns the number of tree nodes visited to match the
+// route of the request from a request context and whether it was measured,
+// see Router.MeasureLookupDepth.
+func ContextLookupDepth(ctx context.Context) (int, bool) {
+	depth, ok := ctx.Value(lookupDepthContextKey).(int)
+	return depth, ok
+}
+
+// ContextPanicStack returns the stack trace of a panic recovered by the router
+// from a request context, or nil if it was not captured, see
+// Router.CapturePanicStack. It is meant to be called by the PanicHandler.
+func ContextPanicStack(ctx context.Context) []byte {
+	stack, _ := ctx.Value(panicStackContextKey).([]byte)
+	return stack
+}
+
+// outerParams returns the params of the enclosing router of a host router or a
+// mounted router, see Router.Host and Router.Mount.
+func outerParams(ctx context.Context) Params {
+	ps, _ := ctx.Value(outerParamsContextKey).(Params)
+	return ps
+}
@@ -0,0 +1,102 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestContextAccessors(t *testing.T) {
+	var (
+		params    Params
+		route     *Route
+		clientKey string
+		depth     int
+		measured  bool
+		applied   []string
+	)
+	handle := func(_ http.ResponseWriter, r *http.Request, _ Params) {
+		ctx := r.Context()
+		params = ContextParams(ctx)
+		route = ContextMatchedRoute(ctx)
+		clientKey = ContextClientKey(ctx)
+		depth, measured = ContextLookupDepth(ctx)
+		applied = AppliedMiddleware(ctx)
+	}
+
+	router := New()
+	router.MeasureLookupDepth = true
+	router.ClientKeyFunc = func(r *http.Request) string {
+		return r.Header.Get("X-Client")
+	}
+	router.Use(headerMiddleware)
+	want := router.GET("/users/@id", handle)
+
+	r := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	r.Header.Set("X-Client", "client")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	if params.ByName("id") != "42" {
+		t.Errorf("wrong params: %v", params)
+	}
+	if route != want {
+		t.Errorf("wrong matched route: %v", route)
+	}
+	if clientKey != "client" {
+		t.Errorf("wrong client key: %q", clientKey)
+	}
+	if !measured || depth == 0 {
+		t.Errorf("wrong lookup depth: %d, %v", depth, measured)
+	}
+	if !reflect.DeepEqual(applied, []string{"httprouter.headerMiddleware"}) {
+		t.Errorf("wrong applied middleware: %v", applied)
+	}
+}
+
+func TestContextAccessorsAbsent(t *testing.T) {
+	ctx := context.Background()
+	if ps := ContextParams(ctx); ps != nil {
+		t.Errorf("unexpected params: %v", ps)
+	}
+	if rt := ContextMatchedRoute(ctx); rt != nil {
+		t.Errorf("unexpected matched route: %v", rt)
+	}
+	if key := ContextClientKey(ctx); key != "" {
+		t.Errorf("unexpected client key: %q", key)
+	}
+	if depth, ok := ContextLookupDepth(ctx); ok || depth != 0 {
+		t.Errorf("unexpected lookup depth: %d, %v", depth, ok)
+	}
+	if applied := AppliedMiddleware(ctx); applied != nil {
+		t.Errorf("unexpected applied middleware: %v", applied)
+	}
+	if stack := ContextPanicStack(ctx); stack != nil {
+		t.Errorf("unexpected panic stack: %s", stack)
+	}
+	if ps := outerParams(ctx); ps != nil {
+		t.Errorf("unexpected host params: %v", ps)
+	}
+}
+
+func TestContextKeysDistinct(t *testing.T) {
+	// values stored under string keys by other packages don't collide with
+	// the values of the router
+	ctx := context.WithValue(context.Background(), "params", Params{{Key: "id", Value: "1"}})
+	if ps := ContextParams(ctx); ps != nil {
+		t.Errorf("params read from a foreign key: %v", ps)
+	}
+
+	ctx = WithParams(context.Background(), Params{{Key: "id", Value: "1"}})
+	if ps := ParamsFromContext(ctx); ps.ByName("id") != "1" {
+		t.Errorf("wrong params: %v", ps)
+	}
+	if rt := ContextMatchedRoute(ctx); rt != nil {
+		t.Errorf("unexpected matched route: %v", rt)
+	}
+}
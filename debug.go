@@ -0,0 +1,148 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// debugInfo is the reply of the DebugHandler.
+type debugInfo struct {
+	Routes []debugRoute           `json:"routes"`
+	Config map[string]interface{} `json:"config"`
+	Trees  map[string]debugTree   `json:"trees"`
+}
+
+type debugRoute struct {
+	Method     string   `json:"method"`
+	Path       string   `json:"path"`
+	Name       string   `json:"name,omitempty"`
+	Middleware []string `json:"middleware,omitempty"`
+}
+
+// debugTree holds statistics of the tree of a method.
+type debugTree struct {
+	Nodes    int `json:"nodes"`
+	Handles  int `json:"handles"`
+	MaxDepth int `json:"maxDepth"`
+}
+
+// DebugHandler returns a handler replying with a JSON document describing the
+// router: the registered routes with their method, path, name and middleware
+// (see Route.MiddlewareNames), the configuration of the router, i.e. the
+// values of its option fields, of handlers and hooks only whether they are
+// set, and statistics of the trees by method. It is meant
+// to be mounted at a debug path, e.g.:
+//
+//	router.Handler(http.MethodGet, "/debug/router", router.DebugHandler())
+//
+// Like registering routes, the handler is not concurrency-safe with the
+// registration of routes.
+func (r *Router) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := json.MarshalIndent(r.debugInfo(), "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+}
+
+func (r *Router) debugInfo() debugInfo {
+	routes := r.Routes()
+	info := debugInfo{
+		Routes: make([]debugRoute, len(routes)),
+		Config: r.debugConfig(),
+		Trees:  make(map[string]debugTree, len(r.trees)),
+	}
+	for i, rt := range routes {
+		info.Routes[i] = debugRoute{Method: rt.Method(), Path: rt.Path(), Name: rt.Name()}
+		if names := rt.MiddlewareNames(); len(names) > 0 {
+			info.Routes[i].Middleware = names
+		}
+	}
+
+	for method, root := range r.trees {
+		var stats debugTree
+		root.collectStats(&stats, 1)
+		info.Trees[method] = stats
+	}
+	return info
+}
+
+// debugConfig returns the configuration of the router listed by the
+// DebugHandler. Handlers and hooks can't be serialized, so only whether they
+// are set is listed.
+func (r *Router) debugConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"SaveMatchedRoutePath":         r.SaveMatchedRoutePath,
+		"AllowEmptySegments":           r.AllowEmptySegments,
+		"MatchBareCatchAll":            r.MatchBareCatchAll,
+		"RedirectTrailingSlash":        r.RedirectTrailingSlash,
+		"RedirectTrailingSlashMethods": r.RedirectTrailingSlashMethods,
+		"ServeTrailingSlashInPlace":    r.ServeTrailingSlashInPlace,
+		"RedirectFixedPath":            r.RedirectFixedPath,
+		"RedirectFixedCase":            r.RedirectFixedCase,
+		"RedirectTrailingSlashCode":    r.RedirectTrailingSlashCode,
+		"RedirectFixedPathCode":        r.RedirectFixedPathCode,
+		"UnicodeCaseFold":              r.UnicodeCaseFold,
+		"DebugRedirects":               r.DebugRedirects,
+		"CanonicalRedirect":            r.CanonicalRedirect,
+		"OnRedirect":                   r.OnRedirect != nil,
+		"OnRedirectSuppressed":         r.OnRedirectSuppressed != nil,
+		"AbsoluteRedirects":            r.AbsoluteRedirects,
+		"TrustForwardedProto":          r.TrustForwardedProto,
+		"SecureRedirect":               r.SecureRedirect,
+		"RedirectPolicy":               r.RedirectPolicy != nil,
+		"StrictNoRedirect":             r.StrictNoRedirect,
+		"PreserveEncodedSlash":         r.PreserveEncodedSlash,
+		"MuxPatterns":                  r.MuxPatterns,
+		"ServeMuxPatterns":             r.ServeMuxPatterns,
+		"Decorator":                    r.Decorator != nil,
+		"SuggestRoutes":                r.SuggestRoutes,
+		"MeasureLookupDepth":           r.MeasureLookupDepth,
+		"UseRequestURIFallback":        r.UseRequestURIFallback,
+		"RequireAccept":                r.RequireAccept,
+		"ClientIPHeader":               r.ClientIPHeader,
+		"TrustedProxies":               r.TrustedProxies,
+		"ClientKeyFunc":                r.ClientKeyFunc != nil,
+		"AutoHEAD":                     r.AutoHEAD,
+		"HandleMethodNotAllowed":       r.HandleMethodNotAllowed,
+		"HandleOPTIONS":                r.HandleOPTIONS,
+		"GlobalOPTIONS":                r.GlobalOPTIONS != nil,
+		"OptionsMethodFilter":          r.OptionsMethodFilter != nil,
+		"HandleTRACE":                  r.HandleTRACE,
+		"NotFound":                     r.NotFound != nil,
+		"Fallback":                     r.Fallback != nil,
+		"MethodNotAllowed":             r.MethodNotAllowed != nil,
+		"MaxHeaderBytes":               r.MaxHeaderBytes,
+		"DefaultMaxBody":               r.DefaultMaxBody,
+		"DefaultTimeout":               r.DefaultTimeout.String(),
+		"MissingQueryParam":            r.MissingQueryParam != nil,
+		"ValidationErrorHandler":       r.ValidationErrorHandler != nil,
+		"HeaderTimeout":                r.HeaderTimeout,
+		"LoadingHandler":               r.LoadingHandler != nil,
+		"Loading":                      r.isLoading(),
+		"PanicHandler":                 r.PanicHandler != nil,
+		"CapturePanicStack":            r.CapturePanicStack,
+	}
+}
+
+// collectStats adds the statistics of the subtree of n at the given depth.
+func (n *node) collectStats(stats *debugTree, depth int) {
+	stats.Nodes++
+	if n.handle != nil {
+		stats.Handles++
+	}
+	if depth > stats.MaxDepth {
+		stats.MaxDepth = depth
+	}
+	for _, child := range n.children {
+		child.collectStats(stats, depth+1)
+	}
+}
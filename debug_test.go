@@ -0,0 +1,74 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRouterDebugHandler(t *testing.T) {
+	router := New()
+	router.DefaultTimeout = 5 * time.Second
+	router.RedirectTrailingSlashMethods = []string{http.MethodGet}
+	router.GET("/", fakeHandler("/"))
+	router.GET("/users/@id", fakeHandler("/users/@id")).Named("user")
+	router.With(headerMiddleware).POST("/users", fakeHandler("/users"))
+	router.Handler(http.MethodGet, "/debug/router", router.DebugHandler())
+
+	r, _ := http.NewRequest(http.MethodGet, "/debug/router", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("debug handler failed: Code=%d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("wrong Content-Type: %q", ct)
+	}
+
+	var info struct {
+		Routes []debugRoute
+		Config map[string]interface{}
+		Trees  map[string]debugTree
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+
+	wantRoutes := []debugRoute{
+		{http.MethodGet, "/", "", nil},
+		{http.MethodGet, "/users/@id", "user", nil},
+		{http.MethodPost, "/users", "", []string{"httprouter.headerMiddleware"}},
+		{http.MethodGet, "/debug/router", "", nil},
+	}
+	if !reflect.DeepEqual(info.Routes, wantRoutes) {
+		t.Errorf("wrong routes:\n got: %v\nwant: %v", info.Routes, wantRoutes)
+	}
+
+	wantConfig := map[string]interface{}{
+		"RedirectTrailingSlash":        true,
+		"HandleMethodNotAllowed":       true,
+		"DefaultTimeout":               "5s",
+		"RedirectTrailingSlashMethods": []interface{}{"GET"},
+		"NotFound":                     false,
+		"Loading":                      false,
+	}
+	for key, want := range wantConfig {
+		if got := info.Config[key]; !reflect.DeepEqual(got, want) {
+			t.Errorf("wrong config %s: got %v, want %v", key, got, want)
+		}
+	}
+
+	if stats := info.Trees[http.MethodGet]; stats.Handles != 3 || stats.Nodes < 3 || stats.MaxDepth < 2 {
+		t.Errorf("wrong stats of GET tree: %+v", stats)
+	}
+	if stats := info.Trees[http.MethodPost]; stats != (debugTree{Nodes: 1, Handles: 1, MaxDepth: 1}) {
+		t.Errorf("wrong stats of POST tree: %+v", stats)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/mbict/httprouter"
+)
+
+// statusWriter records the status code written to a response.
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func ExampleNewRequestWithParams() {
+	// A handler which is usually registered as /hello/@name
+	hello := func(w http.ResponseWriter, req *http.Request) {
+		ps := httprouter.ParamsFromContext(req.Context())
+		fmt.Fprintf(w, "hello, %s!", ps.ByName("name"))
+	}
+
+	// Test the handler without a router
+	req := httptest.NewRequest(http.MethodGet, "/hello/gopher", nil)
+	req = httprouter.NewRequestWithParams(req, httprouter.Params{
+		{Key: "name", Value: "gopher"},
+	})
+	w := httptest.NewRecorder()
+	hello(w, req)
+
+	fmt.Println(w.Body.String())
+	// Output: hello, gopher!
+}
+
+func ExampleRouter_Use() {
+	router := httprouter.New()
+	router.GET("/users/@id", func(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
+		fmt.Fprintf(w, "user %s", ps.ByName("id"))
+	})
+
+	// Global middleware runs for all requests, including 404 and 405 replies
+	router.UseGlobal(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+			next.ServeHTTP(sw, req)
+			fmt.Println(req.Method, req.URL.Path, sw.code)
+		})
+	})
+
+	// Middleware of the router only runs for matched routes, with the params
+	// and the matched route in the request context
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			fmt.Println("route", httprouter.ContextMatchedRoute(ctx).Path(),
+				"id", httprouter.ContextParams(ctx).ByName("id"))
+			next.ServeHTTP(w, req)
+		})
+	})
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/users/42", nil),
+		httptest.NewRequest(http.MethodGet, "/nothing", nil),
+		httptest.NewRequest(http.MethodPost, "/users/42", nil),
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	// Output:
+	// route /users/@id id 42
+	// GET /users/42 200
+	// GET /nothing 404
+	// POST /users/42 405
+}
@@ -0,0 +1,262 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Group registers routes with a common path prefix on a router:
+//
+//	api := router.Group("/api/v1")
+//	api.GET("/users", ListUsers)  // GET /api/v1/users
+//	api.POST("/orders", AddOrder) // POST /api/v1/orders
+//
+// The routes are registered on the router like routes registered directly,
+// a Group only prepends its prefix to their paths and wraps its middleware, if
+// any, around their handles, see With.
+//
+// Groups can be nested, see Group.Group. A nested group inherits the
+// middleware, the redirect policy and the NotFound and MethodNotAllowed
+// handlers of its parent, which it can extend or override.
+type Group struct {
+	router *Router
+	parent *Group
+	prefix string
+
+	// The middleware of the group itself, without the middleware of its
+	// parents
+	middleware []func(http.Handler) http.Handler
+
+	// Whether requests are redirected to the routes of the group: 0 inherits
+	// the policy of the parent, see NoRedirect and AllowRedirect
+	redirect int8
+
+	// Configurable http.Handler which is called instead of the NotFound
+	// handler of the router when no matching route is found for a path
+	// below the prefix of the group, e.g. to reply with JSON errors below
+	// /api. Router.Fallback still takes precedence.
+	NotFound http.Handler
+
+	// Configurable http.Handler which is called instead of the
+	// MethodNotAllowed handler of the router for requests to paths below the
+	// prefix of the group, see Router.MethodNotAllowed.
+	MethodNotAllowed http.Handler
+}
+
+// Group returns a Group registering routes with the given path prefix on the
+// router. The prefix must begin with '/' and must not end with '/'.
+func (r *Router) Group(prefix string) *Group {
+	checkPrefix(prefix)
+	return r.newGroup(nil, prefix, nil)
+}
+
+// Group returns a Group nested in the group, registering routes with the
+// prefix of the group followed by the given prefix, e.g.:
+//
+//	api := router.Group("/api")
+//	v1 := api.Group("/v1") // routes below /api/v1
+//
+// The prefix must begin with '/' and must not end with '/'.
+func (g *Group) Group(prefix string) *Group {
+	checkPrefix(prefix)
+	return g.router.newGroup(g, g.prefix+prefix, nil)
+}
+
+// checkPrefix panics if prefix is not a valid prefix of a group.
+func checkPrefix(prefix string) {
+	if len(prefix) < 1 || prefix[0] != '/' {
+		panic("prefix must begin with '/' in prefix '" + prefix + "'")
+	}
+	if strings.HasSuffix(prefix, "/") {
+		panic("prefix must not end with '/' in prefix '" + prefix + "'")
+	}
+}
+
+// newGroup returns a new group of the router. Groups with a prefix are
+// registered to look up their NotFound and MethodNotAllowed handlers.
+func (r *Router) newGroup(parent *Group, prefix string, middleware []func(http.Handler) http.Handler) *Group {
+	g := &Group{router: r, parent: parent, prefix: prefix, middleware: middleware}
+	if prefix != "" {
+		if r.groups == nil {
+			r.groups = make(map[string][]*Group)
+		}
+		r.groups[prefix] = append(r.groups[prefix], g)
+	}
+	return g
+}
+
+// With returns a Group registering routes on the router with the given
+// middleware wrapped around their handles, e.g. for routes requiring
+// authentication:
+//
+//	router.With(Auth, Audit).GET("/admin", Admin)
+//
+// The first middleware is the outermost one. It runs after the middleware of
+// the router, see Use, like the middleware of a RouteSpec.
+func (r *Router) With(middleware ...func(http.Handler) http.Handler) *Group {
+	return r.newGroup(nil, "", middleware)
+}
+
+// With returns a Group nested in the group with the same prefix, which wraps
+// the given middleware around the handles of its routes in addition to the
+// middleware of the group, see Router.With.
+// Unmatched requests below the prefix are answered by the NotFound and
+// MethodNotAllowed handlers of the group; those of the returned group are
+// not used.
+func (g *Group) With(middleware ...func(http.Handler) http.Handler) *Group {
+	return &Group{router: g.router, parent: g, prefix: g.prefix, middleware: middleware}
+}
+
+// Use appends middleware to the middleware of the group, which is wrapped
+// around the handles of the routes registered afterwards by the group and the
+// groups nested in it. The middleware of a group runs after the middleware of
+// its parent.
+func (g *Group) Use(middleware ...func(http.Handler) http.Handler) *Group {
+	g.middleware = append(g.middleware, middleware...)
+	return g
+}
+
+// NoRedirect prevents the router from redirecting requests to the routes
+// registered afterwards by the group and the groups nested in it, see
+// Route.NoRedirect.
+func (g *Group) NoRedirect() *Group {
+	g.redirect = -1
+	return g
+}
+
+// AllowRedirect lets the router redirect requests to the routes registered
+// afterwards by the group and the groups nested in it, like to routes
+// registered directly, overriding NoRedirect of a parent group.
+func (g *Group) AllowRedirect() *Group {
+	g.redirect = 1
+	return g
+}
+
+// chain returns the middleware of the group and its parents, the outermost
+// first.
+func (g *Group) chain() []func(http.Handler) http.Handler {
+	if g.parent == nil {
+		return g.middleware
+	}
+	outer := g.parent.chain()
+	if len(g.middleware) == 0 {
+		return outer
+	}
+	mw := make([]func(http.Handler) http.Handler, 0, len(outer)+len(g.middleware))
+	mw = append(mw, outer...)
+	return append(mw, g.middleware...)
+}
+
+// noRedirect reports whether redirects to the routes of the group are
+// prevented by the group or its closest parent with a redirect policy.
+func (g *Group) noRedirect() bool {
+	for ; g != nil; g = g.parent {
+		if g.redirect != 0 {
+			return g.redirect < 0
+		}
+	}
+	return false
+}
+
+// Prefix returns the path prefix of the group.
+func (g *Group) Prefix() string {
+	return g.prefix
+}
+
+// groupHandler returns the handler selected by get of the group with the
+// longest prefix containing the path which has one, or nil if there is none.
+// Of groups with the same prefix, the nested one created last wins.
+// Only the prefixes of the path ending at a segment boundary are looked up.
+func (r *Router) groupHandler(path string, get func(g *Group) http.Handler) http.Handler {
+	if len(r.groups) == 0 {
+		return nil
+	}
+	for end := len(path); end > 0; end = strings.LastIndexByte(path[:end], '/') {
+		groups := r.groups[path[:end]]
+		for i := len(groups) - 1; i >= 0; i-- {
+			if h := get(groups[i]); h != nil {
+				return h
+			}
+		}
+	}
+	return nil
+}
+
+func groupNotFound(g *Group) http.Handler         { return g.NotFound }
+func groupMethodNotAllowed(g *Group) http.Handler { return g.MethodNotAllowed }
+
+// GET is a shortcut for group.Handle(http.MethodGet, path, handle)
+func (g *Group) GET(path string, handle Handle) *Route {
+	return g.Handle(http.MethodGet, path, handle)
+}
+
+// Any is a shortcut for group.Handle(MethodAny, path, handle)
+func (g *Group) Any(path string, handle Handle) *Route {
+	return g.Handle(MethodAny, path, handle)
+}
+
+// HEAD is a shortcut for group.Handle(http.MethodHead, path, handle)
+func (g *Group) HEAD(path string, handle Handle) *Route {
+	return g.Handle(http.MethodHead, path, handle)
+}
+
+// OPTIONS is a shortcut for group.Handle(http.MethodOptions, path, handle)
+func (g *Group) OPTIONS(path string, handle Handle) *Route {
+	return g.Handle(http.MethodOptions, path, handle)
+}
+
+// POST is a shortcut for group.Handle(http.MethodPost, path, handle)
+func (g *Group) POST(path string, handle Handle) *Route {
+	return g.Handle(http.MethodPost, path, handle)
+}
+
+// PUT is a shortcut for group.Handle(http.MethodPut, path, handle)
+func (g *Group) PUT(path string, handle Handle) *Route {
+	return g.Handle(http.MethodPut, path, handle)
+}
+
+// PATCH is a shortcut for group.Handle(http.MethodPatch, path, handle)
+func (g *Group) PATCH(path string, handle Handle) *Route {
+	return g.Handle(http.MethodPatch, path, handle)
+}
+
+// DELETE is a shortcut for group.Handle(http.MethodDelete, path, handle)
+func (g *Group) DELETE(path string, handle Handle) *Route {
+	return g.Handle(http.MethodDelete, path, handle)
+}
+
+// Handle registers a new request handle for the prefix of the group followed
+// by the given path, which must begin with '/', see Router.Handle.
+func (g *Group) Handle(method, path string, handle Handle) *Route {
+	if len(path) < 1 || path[0] != '/' {
+		panic("path must begin with '/' in path '" + path + "'")
+	}
+	middleware := g.chain()
+	var names []string
+	if handle != nil && len(middleware) > 0 {
+		handle, names = wrapMiddleware(handle, middleware)
+	}
+	rt := g.router.Handle(method, g.prefix+path, handle)
+	rt.middleware = middleware
+	rt.middlewareNames = names
+	if g.noRedirect() {
+		rt.NoRedirect()
+	}
+	return rt
+}
+
+// Handler is an adapter which allows the usage of an http.Handler as a
+// request handle in the group, see Router.Handler.
+func (g *Group) Handler(method, path string, handler http.Handler) *Route {
+	return g.Handle(method, path, handlerHandle(handler))
+}
+
+// HandlerFunc is an adapter which allows the usage of an http.HandlerFunc as a
+// request handle in the group, see Router.HandlerFunc.
+func (g *Group) HandlerFunc(method, path string, handler http.HandlerFunc) *Route {
+	return g.Handler(method, path, handler)
+}
@@ -0,0 +1,249 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRouterGroup(t *testing.T) {
+	var route string
+	handle := func(path string) Handle {
+		return func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+			route = path + ps.ByName("id")
+		}
+	}
+
+	router := New()
+	api := router.Group("/api/v1")
+	if api.Prefix() != "/api/v1" {
+		t.Errorf("wrong prefix: %q", api.Prefix())
+	}
+	api.GET("/users", handle("GET /api/v1/users"))
+	api.GET("/users/@id", handle("GET /api/v1/users/"))
+	api.POST("/orders", handle("POST /api/v1/orders"))
+	api.Handler(http.MethodPut, "/orders/@id", http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		route = "PUT /api/v1/orders/" + ParamsFromContext(req.Context()).ByName("id")
+	}))
+	router.GET("/users", handle("GET /users"))
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		route  string
+	}{
+		{http.MethodGet, "/api/v1/users", http.StatusOK, "GET /api/v1/users"},
+		{http.MethodGet, "/api/v1/users/42", http.StatusOK, "GET /api/v1/users/42"},
+		{http.MethodPost, "/api/v1/orders", http.StatusOK, "POST /api/v1/orders"},
+		{http.MethodPut, "/api/v1/orders/7", http.StatusOK, "PUT /api/v1/orders/7"},
+		{http.MethodGet, "/users", http.StatusOK, "GET /users"},
+		{http.MethodGet, "/orders", http.StatusNotFound, ""},
+		{http.MethodGet, "/api/v1/orders", http.StatusMethodNotAllowed, ""},
+	}
+	for _, test := range tests {
+		route = ""
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code || route != test.route {
+			t.Errorf("routing %s %s failed: Code=%d, route=%q", test.method, test.path, w.Code, route)
+		}
+	}
+
+	// the routes are registered on the router
+	api.GET("/me", handle("GET /api/v1/me")).Named("me")
+	if rt := router.NamedRoute("me"); rt == nil || rt.Path() != "/api/v1/me" {
+		t.Errorf("group route not registered on the router: %v", rt)
+	}
+}
+
+func TestRouterGroupInvalid(t *testing.T) {
+	router := New()
+	for _, prefix := range []string{"", "api", "/api/"} {
+		if recv := catchPanic(func() { router.Group(prefix) }); recv == nil {
+			t.Errorf("no panic for invalid prefix %q", prefix)
+		}
+	}
+	if recv := catchPanic(func() {
+		router.Group("/api").GET("users", fakeHandler("users"))
+	}); recv == nil {
+		t.Error("no panic for path without leading '/'")
+	}
+}
+
+func TestRouterWith(t *testing.T) {
+	tag := func(value string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Add("X-Middleware", value)
+				next.ServeHTTP(w, req)
+			})
+		}
+	}
+	var id string
+	handle := func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		id = ps.ByName("id")
+	}
+
+	router := New()
+	router.Use(tag("router"))
+	router.GET("/public/@id", handle)
+	admin := router.With(tag("auth"))
+	admin.GET("/admin/@id", handle)
+	admin.With(tag("audit")).DELETE("/admin/@id", handle)
+	router.Group("/api").With(tag("api")).GET("/items/@id", handle)
+
+	tests := []struct {
+		method     string
+		path       string
+		middleware []string
+	}{
+		{http.MethodGet, "/public/1", []string{"router"}},
+		{http.MethodGet, "/admin/2", []string{"router", "auth"}},
+		{http.MethodDelete, "/admin/3", []string{"router", "auth", "audit"}},
+		{http.MethodGet, "/api/items/4", []string{"router", "api"}},
+	}
+	for _, test := range tests {
+		id = ""
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if got := w.Header()["X-Middleware"]; !reflect.DeepEqual(got, test.middleware) {
+			t.Errorf("routing %s %s: wrong middleware %v, want %v", test.method, test.path, got, test.middleware)
+		}
+		if want := test.path[strings.LastIndexByte(test.path, '/')+1:]; id != want {
+			t.Errorf("routing %s %s: wrong param id=%q, want %q", test.method, test.path, id, want)
+		}
+	}
+
+	// With doesn't modify the group it is called on
+	if len(admin.middleware) != 1 {
+		t.Errorf("group middleware modified: %d", len(admin.middleware))
+	}
+}
+
+func TestRouterGroupNotFound(t *testing.T) {
+	reply := func(body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+			w.Write([]byte(body))
+		})
+	}
+
+	router := New()
+	router.NotFound = reply("router not found")
+	router.MethodNotAllowed = reply("router not allowed")
+	router.GET("/about", fakeHandler("/about"))
+	api := router.Group("/api")
+	api.NotFound = reply("api not found")
+	api.MethodNotAllowed = reply("api not allowed")
+	api.GET("/users", fakeHandler("/api/users"))
+	v2 := router.Group("/api/v2")
+	v2.NotFound = reply("v2 not found")
+	v2.GET("/users", fakeHandler("/api/v2/users"))
+	router.Group("/plain").GET("/x", fakeHandler("/plain/x"))
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/nothing", "router not found"},
+		{http.MethodPost, "/about", "router not allowed"},
+		{http.MethodGet, "/api", "api not found"},
+		{http.MethodGet, "/api/nothing", "api not found"},
+		{http.MethodGet, "/apis", "router not found"},
+		{http.MethodPost, "/api/users", "api not allowed"},
+		{http.MethodGet, "/api/v2/nothing", "v2 not found"},
+		{http.MethodPost, "/api/v2/users", "api not allowed"},
+		{http.MethodGet, "/plain/nothing", "router not found"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusTeapot || w.Body.String() != test.body {
+			t.Errorf("routing %s %s failed: Code=%d, Body=%q, want %q", test.method, test.path, w.Code, w.Body.String(), test.body)
+		}
+	}
+
+	// groups derived by With are not registered for their handlers
+	for i := 0; i < 3; i++ {
+		api.With(func(next http.Handler) http.Handler { return next })
+	}
+	if n := len(router.groups["/api"]); n != 1 {
+		t.Errorf("%d groups registered for /api, want 1", n)
+	}
+}
+
+func TestRouterNestedGroups(t *testing.T) {
+	tag := func(value string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Add("X-Middleware", value)
+				next.ServeHTTP(w, req)
+			})
+		}
+	}
+	notFound := func(body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(body))
+		})
+	}
+
+	router := New()
+	api := router.Group("/api").Use(tag("api")).NoRedirect()
+	api.NotFound = notFound("api")
+	v1 := api.Group("/v1").Use(tag("v1"))
+	v1.GET("/users", fakeHandler("/api/v1/users"))
+	v2 := api.Group("/v2").AllowRedirect()
+	v2.NotFound = notFound("v2")
+	v2.With(tag("auth")).GET("/users", fakeHandler("/api/v2/users"))
+	api.Use(tag("late")) // only applies to routes registered afterwards
+	api.GET("/status", fakeHandler("/api/status"))
+
+	if v1.Prefix() != "/api/v1" {
+		t.Errorf("wrong prefix of nested group: %q", v1.Prefix())
+	}
+
+	tests := []struct {
+		path       string
+		code       int
+		middleware []string
+		body       string
+	}{
+		{"/api/v1/users", http.StatusOK, []string{"api", "v1"}, ""},
+		{"/api/v2/users", http.StatusOK, []string{"api", "auth"}, ""},
+		{"/api/status", http.StatusOK, []string{"api", "late"}, ""},
+		{"/api/v1/users/", http.StatusNotFound, nil, "api"},
+		{"/api/v2/users/", http.StatusMovedPermanently, nil, ""},
+		{"/api/v1/nothing", http.StatusNotFound, nil, "api"},
+		{"/api/v2/nothing", http.StatusNotFound, nil, "v2"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(http.MethodGet, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("routing %s failed: Code=%d, want %d", test.path, w.Code, test.code)
+		}
+		if got := w.Header()["X-Middleware"]; !reflect.DeepEqual(got, test.middleware) {
+			t.Errorf("routing %s: wrong middleware %v, want %v", test.path, got, test.middleware)
+		}
+		if test.body != "" && w.Body.String() != test.body {
+			t.Errorf("routing %s: wrong body %q, want %q", test.path, w.Body.String(), test.body)
+		}
+	}
+
+	if recv := catchPanic(func() { api.Group("v3") }); recv == nil {
+		t.Error("no panic for invalid prefix of nested group")
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// hostRoute is a router for requests to the hosts matching a host pattern.
+type hostRoute struct {
+	pattern   string
+	labels    []string
+	hasParams bool
+	router    *Router
+}
+
+// Host returns a router for requests to hosts matching the given pattern,
+// creating it on the first call for the pattern. The returned router is
+// initialized like by New.
+// The pattern consists of dot-separated labels. A label of the form @name
+// matches any single label of the host and passes it as a param with the given
+// name to the handles of the host router, in addition to the path params:
+//  router.Host("@tenant.tenants.example.com").GET("/", Dashboard)
+// The port of the request host is ignored and labels are compared
+// case-insensitively. Patterns without params take priority over patterns
+// with params. Requests to hosts without a matching pattern are routed by
+// the router itself.
+func (r *Router) Host(pattern string) *Router {
+	for _, h := range r.hosts {
+		if h.pattern == pattern {
+			return h.router
+		}
+	}
+
+	labels := strings.Split(pattern, ".")
+	for _, label := range labels {
+		if label == "" || label == "@" {
+			panic("invalid host pattern '" + pattern + "'")
+		}
+	}
+
+	h := &hostRoute{
+		pattern:   pattern,
+		labels:    labels,
+		hasParams: strings.IndexByte(pattern, '@') >= 0,
+		router:    New(),
+	}
+	h.router.nested = true
+	r.hosts = append(r.hosts, h)
+	return h.router
+}
+
+// matchHost returns the host router for the given host and the captured host
+// params, if any.
+func (r *Router) matchHost(host string) (*Router, Params) {
+	if strings.LastIndexByte(host, ':') > strings.LastIndexByte(host, ']') {
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+	}
+	labels := strings.Split(host, ".")
+
+	// Patterns without params first
+	for _, h := range r.hosts {
+		if !h.hasParams && strings.EqualFold(h.pattern, host) {
+			return h.router, nil
+		}
+	}
+	for _, h := range r.hosts {
+		if h.hasParams {
+			if ps, ok := h.match(labels); ok {
+				return h.router, ps
+			}
+		}
+	}
+	return nil, nil
+}
+
+// match matches the labels of a host against the labels of the pattern.
+func (h *hostRoute) match(labels []string) (Params, bool) {
+	if len(labels) != len(h.labels) {
+		return nil, false
+	}
+	var ps Params
+	for i, label := range h.labels {
+		if label[0] == '@' {
+			ps = append(ps, Param{Key: label[1:], Value: labels[i]})
+		} else if !strings.EqualFold(label, labels[i]) {
+			return nil, false
+		}
+	}
+	return ps, true
+}
+
+// serveHost serves the request by the host router matching its host. It
+// returns false if no host router matches.
+func (r *Router) serveHost(w http.ResponseWriter, req *http.Request) bool {
+	hr, ps := r.matchHost(req.Host)
+	if hr == nil {
+		return false
+	}
+	if len(ps) > 0 {
+		req = req.WithContext(context.WithValue(req.Context(), outerParamsContextKey, ps))
+	}
+	hr.ServeHTTP(w, req)
+	return true
+}
@@ -0,0 +1,68 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRouterHost(t *testing.T) {
+	var served string
+	var params Params
+	handle := func(name string) Handle {
+		return func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+			served = name
+			params = append(Params(nil), ps...)
+		}
+	}
+
+	router := New()
+	router.GET("/", handle("default"))
+	router.Host("@tenant.tenants.example.com").GET("/projects/@id", handle("tenant"))
+	router.Host("admin.tenants.example.com").GET("/projects/@id", handle("admin"))
+	router.Host("@lang.@tenant.sites.example.com").GET("/", handle("site"))
+
+	if router.Host("@tenant.tenants.example.com") != router.Host("@tenant.tenants.example.com") {
+		t.Error("Host must return the same router for the same pattern")
+	}
+
+	testRequests := []struct {
+		host   string
+		path   string
+		code   int
+		served string
+		params Params
+	}{
+		{"acme.tenants.example.com", "/projects/1", http.StatusOK, "tenant", Params{{"id", "1"}, {"tenant", "acme"}}},
+		{"acme.tenants.example.com:8080", "/projects/2", http.StatusOK, "tenant", Params{{"id", "2"}, {"tenant", "acme"}}},
+		{"ACME.Tenants.Example.com", "/projects/3", http.StatusOK, "tenant", Params{{"id", "3"}, {"tenant", "ACME"}}},
+		{"admin.tenants.example.com", "/projects/4", http.StatusOK, "admin", Params{{"id", "4"}}},
+		{"en.acme.sites.example.com", "/", http.StatusOK, "site", Params{{"lang", "en"}, {"tenant", "acme"}}},
+		{"acme.tenants.example.com", "/", http.StatusNotFound, "", nil},
+		{"a.b.tenants.example.com", "/", http.StatusOK, "default", nil},
+		{"example.com", "/", http.StatusOK, "default", nil},
+		{"[::1]:8080", "/", http.StatusOK, "default", nil},
+	}
+	for _, tr := range testRequests {
+		served, params = "", nil
+		r, _ := http.NewRequest(http.MethodGet, tr.path, nil)
+		r.Host = tr.host
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != tr.code || served != tr.served || !reflect.DeepEqual(params, tr.params) {
+			t.Errorf("routing %s%s failed: Code=%d, served=%q, Params=%v", tr.host, tr.path, w.Code, served, params)
+		}
+	}
+
+	for _, pattern := range []string{"", "a..example.com", "@.example.com"} {
+		pattern := pattern
+		if recv := catchPanic(func() { router.Host(pattern) }); recv == nil {
+			t.Errorf("no panic for invalid host pattern %q", pattern)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Language sets the language of the responses of the route, e.g. "en" or
+// "fr-CA". Routes for the same method and path with a language are chosen by
+// the Accept-Language header of the request, respecting the quality values:
+//  router.GET("/welcome", welcomeEN).Language("en").DefaultLanguage()
+//  router.GET("/welcome", welcomeFR).Language("fr")
+// A language range of the header matches the language of a route if it is
+// equal to it, a prefix of it like "en" for "en-US", or "*". A language like
+// "en-US" in the header also matches a route for "en", but with lower
+// precedence than routes matching exactly.
+// If no route matches, the request is served by the route flagged with
+// DefaultLanguage, else by a route registered after the routes with a
+// language without one, else it is answered with 406 Not Acceptable.
+func (rt *Route) Language(tag string) *Route {
+	rt.language = strings.ToLower(tag)
+	return rt
+}
+
+// DefaultLanguage makes the route serve requests not matching any of the
+// routes for the same method and path with a language, see Language.
+func (rt *Route) DefaultLanguage() *Route {
+	rt.defaultLanguage = true
+	return rt
+}
+
+// languageRange is an element of an Accept-Language header.
+type languageRange struct {
+	tag     string
+	quality float64
+}
+
+// parseAcceptLanguage parses the Accept-Language header of the request.
+// Elements with a quality of 0 or an invalid quality are left out.
+func parseAcceptLanguage(req *http.Request) []languageRange {
+	var ranges []languageRange
+	for _, header := range req.Header["Accept-Language"] {
+		for _, element := range strings.Split(header, ",") {
+			element = strings.TrimSpace(element)
+			quality := 1.0
+			if i := strings.IndexByte(element, ';'); i >= 0 {
+				param := strings.Replace(element[i+1:], " ", "", -1)
+				element = strings.TrimSpace(element[:i])
+				if !strings.HasPrefix(param, "q=") {
+					continue
+				}
+				q, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					continue
+				}
+				quality = q
+			}
+			if element == "" || quality <= 0 {
+				continue
+			}
+			ranges = append(ranges, languageRange{strings.ToLower(element), quality})
+		}
+	}
+	return ranges
+}
+
+// languageMatch returns the quality with which the language ranges accept the
+// given language and the specificity of the best matching range: 3 for an
+// equal range, 2 for a prefix, 1 for a more specific range and 0 for "*".
+// The quality is 0 if no range matches.
+func languageMatch(ranges []languageRange, language string) (quality float64, specificity int) {
+	for _, lr := range ranges {
+		s := -1
+		switch {
+		case lr.tag == language:
+			s = 3
+		case strings.HasPrefix(language, lr.tag+"-"):
+			s = 2
+		case strings.HasPrefix(lr.tag, language+"-"):
+			s = 1
+		case lr.tag == "*":
+			s = 0
+		}
+		if s >= 0 && (lr.quality > quality || lr.quality == quality && s > specificity) {
+			quality, specificity = lr.quality, s
+		}
+	}
+	return quality, specificity
+}
+
+// negotiateLanguage returns the route of the routes with a language, starting
+// at rt, which best matches the Accept-Language header of the request, see
+// Language. Otherwise it returns the default route, if any.
+func (rt *Route) negotiateLanguage(req *http.Request, ps Params) *Route {
+	ranges := parseAcceptLanguage(req)
+
+	var best, fallback *Route
+	var bestQuality float64
+	var bestSpecificity int
+	cur := rt
+	for ; cur != nil && cur.language != ""; cur = cur.next {
+		if !cur.matches(req, ps) {
+			continue
+		}
+		if cur.defaultLanguage && fallback == nil {
+			fallback = cur
+		}
+		if len(req.Header["Accept-Language"]) == 0 {
+			// any language is acceptable
+			if best == nil {
+				best = cur
+			}
+			continue
+		}
+		quality, specificity := languageMatch(ranges, cur.language)
+		if quality > bestQuality || quality > 0 && quality == bestQuality && specificity > bestSpecificity {
+			best, bestQuality, bestSpecificity = cur, quality, specificity
+		}
+	}
+
+	if best != nil && (bestQuality > 0 || fallback == nil) {
+		return best
+	}
+	if fallback != nil {
+		return fallback
+	}
+	// the next route without a language, if any
+	return cur
+}
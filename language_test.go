@@ -0,0 +1,71 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouteLanguage(t *testing.T) {
+	handler := func(name string) Handle {
+		return func(w http.ResponseWriter, _ *http.Request, _ Params) {
+			w.Write([]byte(name))
+		}
+	}
+
+	router := New()
+	router.GET("/welcome", handler("en")).Language("en").DefaultLanguage()
+	router.GET("/welcome", handler("fr")).Language("fr")
+	router.GET("/welcome", handler("de-CH")).Language("de-CH")
+
+	strict := New()
+	strict.GET("/welcome", handler("en")).Language("en")
+	strict.GET("/welcome", handler("fr")).Language("fr")
+
+	fallback := New()
+	fallback.GET("/welcome", handler("fr")).Language("fr")
+	fallback.GET("/welcome", handler("any"))
+
+	tests := []struct {
+		router *Router
+		header string
+		code   int
+		body   string
+	}{
+		{router, "", http.StatusOK, "en"},
+		{router, "fr", http.StatusOK, "fr"},
+		{router, "FR", http.StatusOK, "fr"},
+		{router, "fr-CA", http.StatusOK, "fr"},
+		{router, "fr;q=0.5, en;q=0.8", http.StatusOK, "en"},
+		{router, "fr;q=0.9, en;q=0.8", http.StatusOK, "fr"},
+		{router, "de", http.StatusOK, "de-CH"},
+		{router, "de-CH;q=0.5, fr;q=0.6", http.StatusOK, "fr"},
+		{router, "it, *;q=0.1", http.StatusOK, "en"},
+		{router, "it", http.StatusOK, "en"},
+		{router, "fr;q=0, en-GB", http.StatusOK, "en"},
+		{router, "en-US, fr", http.StatusOK, "fr"},
+		{strict, "it", http.StatusNotAcceptable, "Not Acceptable\n"},
+		{strict, "", http.StatusOK, "en"},
+		{strict, "fr;q=0.1", http.StatusOK, "fr"},
+		{fallback, "it", http.StatusOK, "any"},
+		{fallback, "fr", http.StatusOK, "fr"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/welcome", nil)
+		if test.header != "" {
+			r.Header.Set("Accept-Language", test.header)
+		}
+		w := httptest.NewRecorder()
+		test.router.ServeHTTP(w, r)
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("Accept-Language %q: Code=%d, body=%q, want %d, %q", test.header, w.Code, w.Body.String(), test.code, test.body)
+		}
+		if vary := w.Header().Get("Vary"); vary != "Accept-Language" {
+			t.Errorf("Accept-Language %q: unexpected Vary header %q", test.header, vary)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// Use appends middleware to the middleware of the router, which is wrapped
+// around the handles of all matched routes, including routes registered
+// before. The first middleware is the outermost one. Middleware of the router
+// runs after the global middleware (see UseGlobal) and before the middleware
+// of a route, see RouteSpec.Middleware. It does not run for requests which
+// don't match a route, like requests answered by the NotFound or
+// MethodNotAllowed handler; middleware which must run for these, e.g. for
+// logging, belongs to the global middleware.
+// The params are available in the request context under ParamsKey.
+// Use is not concurrency-safe and should be called before serving requests.
+func (r *Router) Use(middleware ...func(http.Handler) http.Handler) {
+	if len(middleware) == 0 {
+		return
+	}
+	r.middleware = append(r.middleware, middleware...)
+	r.chain, r.chainNames = chainMiddleware(http.HandlerFunc(serveRoute), r.middleware)
+}
+
+// UseGlobal appends middleware to the global middleware of the router, which
+// is wrapped around the router itself and thus runs for all requests,
+// including requests answered by the NotFound or MethodNotAllowed handler,
+// redirects and automatic OPTIONS replies, e.g. for logging or recovery.
+// The first middleware is the outermost one. Global middleware runs before
+// the router matches the request, so the params are not available to it, but
+// the matched route is once the request was served, see ContextMatchedRoute.
+// UseGlobal is not concurrency-safe and should be called before serving
+// requests.
+func (r *Router) UseGlobal(middleware ...func(http.Handler) http.Handler) {
+	if len(middleware) == 0 {
+		return
+	}
+	r.globalMiddleware = append(r.globalMiddleware, middleware...)
+	r.globalChain, _ = chainMiddleware(http.HandlerFunc(r.serveHTTP), r.globalMiddleware)
+}
+
+// ApplyMiddleware wraps middleware around the handles of all registered routes
+// whose pattern matches the given glob and returns the number of wrapped
+// routes. The segments of the glob are matched against the segments of the
+// pattern like by path.Match, except for a final * segment, which matches one
+// or more segments:
+//  router.ApplyMiddleware("/admin/*", auth) // /admin/users, /admin/users/@id
+// The middleware runs inside the middleware of the route, see
+// RouteSpec.Middleware, and is kept if the handle is replaced.
+// Like registering routes, ApplyMiddleware is a registration-time operation:
+// routes registered afterwards are not wrapped, and it is not
+// concurrency-safe.
+func (r *Router) ApplyMiddleware(glob string, middleware ...func(http.Handler) http.Handler) int {
+	if len(middleware) == 0 {
+		return 0
+	}
+	n := 0
+	for _, rt := range r.routes {
+		if !matchGlob(glob, rt.path) {
+			continue
+		}
+		var names []string
+		rt.handle, names = wrapMiddleware(rt.handle, middleware)
+		rt.middleware = append(rt.middleware[:len(rt.middleware):len(rt.middleware)], middleware...)
+		rt.middlewareNames = append(rt.middlewareNames[:len(rt.middlewareNames):len(rt.middlewareNames)], names...)
+		rt.decoration = new(decoration)
+		n++
+	}
+	return n
+}
+
+// matchGlob reports whether the route pattern matches the glob, see
+// ApplyMiddleware.
+func matchGlob(glob, pattern string) bool {
+	globSegments := strings.Split(glob, "/")
+	segments := strings.Split(pattern, "/")
+	for i, g := range globSegments {
+		if i == len(globSegments)-1 && g == "*" {
+			return len(segments) > i && segments[i] != ""
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if ok, err := path.Match(g, segments[i]); !ok || err != nil {
+			return false
+		}
+	}
+	return len(segments) == len(globSegments)
+}
+
+// Chain is implemented by the middleware chains of composition libraries like
+// alice, whose Then method wraps the middleware of the chain around a handler.
+type Chain interface {
+	Then(h http.Handler) http.Handler
+}
+
+// ChainMiddleware adapts a Chain to a middleware of the router, see Use,
+// Group.Use and With. The middleware of the chain runs in the order of the
+// chain, at the position of the adapted middleware among the other
+// middleware, and gets the params in the request context, see ContextParams.
+func ChainMiddleware(c Chain) func(http.Handler) http.Handler {
+	return c.Then
+}
+
+// NextHandler is implemented by middleware in the style of negroni, which
+// calls next to pass the request on.
+type NextHandler interface {
+	ServeHTTP(w http.ResponseWriter, req *http.Request, next http.HandlerFunc)
+}
+
+// The NextHandlerFunc type is an adapter to allow the use of ordinary
+// functions as NextHandler.
+type NextHandlerFunc func(w http.ResponseWriter, req *http.Request, next http.HandlerFunc)
+
+// ServeHTTP calls f(w, req, next).
+func (f NextHandlerFunc) ServeHTTP(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	f(w, req, next)
+}
+
+// NextMiddleware adapts a NextHandler to a middleware of the router, see Use,
+// Group.Use and With. Like with ChainMiddleware, the params are available in
+// the request context.
+func NextMiddleware(h NextHandler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			h.ServeHTTP(w, req, next.ServeHTTP)
+		})
+	}
+}
+
+// serveRoute is the innermost handler of the middleware of the router. It
+// calls the handle of the matched route.
+func serveRoute(w http.ResponseWriter, req *http.Request) {
+	rt := req.Context().Value(routeContextKey).(*Route)
+	rt.decorated()(w, req, ParamsFromContext(req.Context()))
+}
+
+// wrapMiddleware wraps the middleware around the given handle and returns the
+// names of the middleware. The params are passed through the request context.
+func wrapMiddleware(handle Handle, middleware []func(http.Handler) http.Handler) (Handle, []string) {
+	h, names := chainMiddleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		handle(w, req, ParamsFromContext(req.Context()))
+	}), middleware)
+	return func(w http.ResponseWriter, req *http.Request, ps Params) {
+		if len(ps) > 0 {
+			req = NewRequestWithParams(req, ps)
+		}
+		h.ServeHTTP(w, req)
+	}, names
+}
+
+// chainMiddleware wraps the middleware around h and returns the names of the
+// middleware. The first middleware is the outermost one.
+func chainMiddleware(h http.Handler, middleware []func(http.Handler) http.Handler) (http.Handler, []string) {
+	names := make([]string, len(middleware))
+	for i := len(middleware) - 1; i >= 0; i-- {
+		next := middleware[i](h)
+		names[i] = middlewareName(middleware[i])
+		if nh, ok := next.(namedHandler); ok {
+			names[i], next = nh.name, nh.Handler
+		}
+		h = recordMiddleware(names[i], next)
+	}
+	return h, names
+}
+
+// namedHandler is the handler of a middleware registered by
+// RegisterMiddleware, carrying its name.
+type namedHandler struct {
+	name string
+	http.Handler
+}
+
+// RegisterMiddleware registers middleware under the given name, so that it
+// can be referenced by name, see Middleware and RouteSpec.MiddlewareNames,
+// e.g. in declarative route configurations:
+//
+//	router.RegisterMiddleware("auth", Auth)
+//	admin := router.Group("/admin").Use(router.Middleware("auth")...)
+//
+// The name is reported instead of the name of the function of the middleware,
+// see AppliedMiddleware and Route.MiddlewareNames.
+// It panics if the name is empty or already registered.
+func (r *Router) RegisterMiddleware(name string, middleware func(http.Handler) http.Handler) {
+	if name == "" {
+		panic("middleware name must not be empty")
+	}
+	if middleware == nil {
+		panic("middleware must not be nil")
+	}
+	if _, ok := r.namedMiddleware[name]; ok {
+		panic("a middleware named '" + name + "' is already registered")
+	}
+	if r.namedMiddleware == nil {
+		r.namedMiddleware = make(map[string]func(http.Handler) http.Handler)
+	}
+	r.namedMiddleware[name] = func(next http.Handler) http.Handler {
+		return namedHandler{name: name, Handler: middleware(next)}
+	}
+}
+
+// Middleware returns the middleware registered under the given names, see
+// RegisterMiddleware, to be passed to Use, With or Group.Use.
+// It panics if a name is not registered.
+func (r *Router) Middleware(names ...string) []func(http.Handler) http.Handler {
+	middleware, err := r.lookupMiddleware(names)
+	if err != nil {
+		panic(err.Error())
+	}
+	return middleware
+}
+
+// lookupMiddleware returns the middleware registered under the given names.
+func (r *Router) lookupMiddleware(names []string) ([]func(http.Handler) http.Handler, error) {
+	middleware := make([]func(http.Handler) http.Handler, len(names))
+	for i, name := range names {
+		mw, ok := r.namedMiddleware[name]
+		if !ok {
+			return nil, fmt.Errorf("no middleware named '%s' is registered", name)
+		}
+		middleware[i] = mw
+	}
+	return middleware, nil
+}
+
+// recordMiddleware returns a handler which records the given middleware name
+// in the request context before calling h, see AppliedMiddleware.
+func recordMiddleware(name string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if applied, ok := req.Context().Value(appliedMiddlewareContextKey).(*[]string); ok {
+			*applied = append(*applied, name)
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// middlewareName returns the name of the function of a middleware without the
+// package path, e.g. "httprouter.Logging", or "httprouter.Chain.Then" for a
+// Chain adapted by ChainMiddleware.
+func middlewareName(mw func(http.Handler) http.Handler) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(mw).Pointer())
+	if fn == nil {
+		return "middleware"
+	}
+	name := fn.Name()
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.TrimSuffix(name, "-fm")
+}
@@ -0,0 +1,413 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func headerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Add("X-Middleware", "global")
+		next.ServeHTTP(w, req)
+	})
+}
+
+func TestRouterUse(t *testing.T) {
+	var applied []string
+	var params Params
+	handle := func(_ http.ResponseWriter, req *http.Request, ps Params) {
+		applied = AppliedMiddleware(req.Context())
+		params = ps
+	}
+
+	router := New()
+	router.GET("/before/@id", handle) // registered before Use
+	router.Use(headerMiddleware, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Add("X-Middleware", "anonymous:"+ParamsFromContext(req.Context()).ByName("id"))
+			next.ServeHTTP(w, req)
+		})
+	})
+	router.Add(RouteSpec{
+		Method: http.MethodGet,
+		Path:   "/route/@id",
+		Handle: handle,
+		Middleware: []func(http.Handler) http.Handler{func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Add("X-Middleware", "route")
+				next.ServeHTTP(w, req)
+			})
+		}},
+	})
+
+	testRoutes := []struct {
+		route      string
+		middleware []string
+		applied    int
+	}{
+		{"/before/1", []string{"global", "anonymous:1"}, 2},
+		{"/route/2", []string{"global", "anonymous:2", "route"}, 3},
+	}
+	for _, tr := range testRoutes {
+		applied, params = nil, nil
+		r, _ := http.NewRequest(http.MethodGet, tr.route, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if got := w.Header()["X-Middleware"]; !reflect.DeepEqual(got, tr.middleware) {
+			t.Errorf("routing %s: wrong middleware order %v, expected %v", tr.route, got, tr.middleware)
+		}
+		if len(applied) != tr.applied {
+			t.Fatalf("routing %s: wrong applied middleware %v", tr.route, applied)
+		}
+		if applied[0] != "httprouter.headerMiddleware" {
+			t.Errorf("routing %s: wrong name of named middleware %q", tr.route, applied[0])
+		}
+		for _, name := range applied[1:] {
+			if !strings.HasPrefix(name, "httprouter.TestRouterUse.func") {
+				t.Errorf("routing %s: wrong synthesized name of anonymous middleware %q", tr.route, name)
+			}
+		}
+		if params.ByName("id") == "" {
+			t.Errorf("routing %s: params not passed to handle", tr.route)
+		}
+	}
+
+	// unmatched requests don't run the middleware
+	r, _ := http.NewRequest(http.MethodGet, "/nope", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound || w.Header().Get("X-Middleware") != "" {
+		t.Errorf("unexpected response for unmatched request: Code=%d, Header=%v", w.Code, w.Header())
+	}
+}
+
+func TestAppliedMiddlewareWithout(t *testing.T) {
+	var applied []string
+	router := New(WithMiddleware())
+	router.GET("/", func(_ http.ResponseWriter, req *http.Request, _ Params) {
+		applied = AppliedMiddleware(req.Context())
+	})
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	if applied != nil {
+		t.Errorf("unexpected applied middleware: %v", applied)
+	}
+}
+
+func TestRouterUseGlobal(t *testing.T) {
+	var global, matched []int
+	logCodes := func(codes *[]int) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				rec := httptest.NewRecorder()
+				next.ServeHTTP(rec, req)
+				*codes = append(*codes, rec.Code)
+				for key, values := range rec.Header() {
+					w.Header()[key] = values
+				}
+				w.WriteHeader(rec.Code)
+				w.Write(rec.Body.Bytes())
+			})
+		}
+	}
+
+	router := New()
+	router.GET("/users/@id", func(w http.ResponseWriter, req *http.Request, ps Params) {
+		w.Write([]byte(ps.ByName("id")))
+	})
+	router.UseGlobal(logCodes(&global))
+	router.Use(logCodes(&matched))
+	router.UseGlobal(headerMiddleware)
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{http.MethodGet, "/users/1", http.StatusOK},
+		{http.MethodGet, "/nothing", http.StatusNotFound},
+		{http.MethodPost, "/users/1", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/users/1/", http.StatusMovedPermanently},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("routing %s %s failed: Code=%d, want %d", test.method, test.path, w.Code, test.code)
+		}
+		if w.Header().Get("X-Middleware") != "global" {
+			t.Errorf("routing %s %s: global middleware not applied", test.method, test.path)
+		}
+	}
+
+	if want := []int{200, 404, 405, 301}; !reflect.DeepEqual(global, want) {
+		t.Errorf("global middleware saw %v, want %v", global, want)
+	}
+	if want := []int{200}; !reflect.DeepEqual(matched, want) {
+		t.Errorf("matched middleware saw %v, want %v", matched, want)
+	}
+}
+
+func TestRouterApplyMiddleware(t *testing.T) {
+	noop := func(http.ResponseWriter, *http.Request, Params) {}
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("Authorization") == "" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+
+	router := New()
+	router.GET("/admin", noop)
+	router.GET("/admin/users", noop)
+	router.GET("/admin/users/@id", noop)
+	router.POST("/admin/users", noop)
+	router.GET("/administrators", noop)
+	router.GET("/public/admin/users", noop)
+
+	if n := router.ApplyMiddleware("/admin/*", auth); n != 3 {
+		t.Errorf("wrong number of wrapped routes: %d, want 3", n)
+	}
+	router.GET("/admin/settings", noop)
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{http.MethodGet, "/admin", http.StatusOK},
+		{http.MethodGet, "/admin/users", http.StatusUnauthorized},
+		{http.MethodGet, "/admin/users/1", http.StatusUnauthorized},
+		{http.MethodPost, "/admin/users", http.StatusUnauthorized},
+		{http.MethodGet, "/administrators", http.StatusOK},
+		{http.MethodGet, "/public/admin/users", http.StatusOK},
+		{http.MethodGet, "/admin/settings", http.StatusOK},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("routing %s %s failed: Code=%d, want %d", test.method, test.path, w.Code, test.code)
+		}
+	}
+
+	// the middleware is kept when the handle is replaced
+	for _, rt := range router.Routes() {
+		if rt.Method() == http.MethodGet && rt.Path() == "/admin/users" {
+			rt.Replace(noop)
+		}
+	}
+	r, _ := http.NewRequest(http.MethodGet, "/admin/users", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("middleware not kept after Replace: Code=%d", w.Code)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		glob, pattern string
+		match         bool
+	}{
+		{"/admin/*", "/admin/users", true},
+		{"/admin/*", "/admin/users/@id", true},
+		{"/admin/*", "/admin", false},
+		{"/admin/*", "/admin/", false},
+		{"/admin/*", "/administrators", false},
+		{"/*/users", "/admin/users", true},
+		{"/*/users", "/admin/users/@id", false},
+		{"/api/v?/items", "/api/v2/items", true},
+		{"/api/v?/items", "/api/v10/items", false},
+		{"/users", "/users", true},
+	}
+	for _, test := range tests {
+		if match := matchGlob(test.glob, test.pattern); match != test.match {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", test.glob, test.pattern, match, test.match)
+		}
+	}
+}
+
+// testChain is a middleware chain like the ones of alice.
+type testChain []func(http.Handler) http.Handler
+
+func (c testChain) Then(h http.Handler) http.Handler {
+	for i := len(c) - 1; i >= 0; i-- {
+		h = c[i](h)
+	}
+	return h
+}
+
+func TestRouterMiddlewareAdapters(t *testing.T) {
+	var order []string
+	tag := func(value string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				order = append(order, value+":"+ContextParams(req.Context()).ByName("id"))
+				next.ServeHTTP(w, req)
+			})
+		}
+	}
+	next := func(value string) NextHandler {
+		return NextHandlerFunc(func(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+			order = append(order, value+":"+ContextParams(req.Context()).ByName("id"))
+			next(w, req)
+		})
+	}
+	var applied []string
+	handle := func(_ http.ResponseWriter, req *http.Request, _ Params) {
+		order = append(order, "handle")
+		applied = AppliedMiddleware(req.Context())
+	}
+
+	router := New()
+	router.Use(tag("router"), ChainMiddleware(testChain{tag("chain1"), tag("chain2")}))
+	router.With(NextMiddleware(next("next")), tag("route")).GET("/users/@id", handle)
+
+	r, _ := http.NewRequest(http.MethodGet, "/users/7", nil)
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	want := []string{"router:7", "chain1:7", "chain2:7", "next:7", "route:7", "handle"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("wrong order of middleware:\n got %v\nwant %v", order, want)
+	}
+	if len(applied) != 4 || applied[1] != "httprouter.Chain.Then" ||
+		applied[2] != "httprouter.NextMiddleware.func1" {
+		t.Errorf("wrong applied middleware: %v", applied)
+	}
+}
+
+func TestRouterRegisterMiddleware(t *testing.T) {
+	tag := func(value string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Add("X-Middleware", value)
+				next.ServeHTTP(w, req)
+			})
+		}
+	}
+	var applied []string
+	handle := func(_ http.ResponseWriter, req *http.Request, _ Params) {
+		applied = AppliedMiddleware(req.Context())
+	}
+
+	router := New()
+	router.RegisterMiddleware("auth", tag("auth"))
+	router.RegisterMiddleware("audit", tag("audit"))
+	router.Use(headerMiddleware)
+	admin := router.Group("/admin").Use(router.Middleware("auth")...)
+	users := admin.GET("/users", handle)
+	deleteUser := admin.With(router.Middleware("audit")...).DELETE("/users/@id", handle)
+	report, err := router.Add(RouteSpec{
+		Method:          http.MethodGet,
+		Path:            "/reports",
+		Handle:          handle,
+		Middleware:      []func(http.Handler) http.Handler{headerMiddleware},
+		MiddlewareNames: []string{"audit"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		route      *Route
+		method     string
+		path       string
+		middleware []string
+		names      []string
+	}{
+		{users, http.MethodGet, "/admin/users", []string{"global", "auth"},
+			[]string{"httprouter.headerMiddleware", "auth"}},
+		{deleteUser, http.MethodDelete, "/admin/users/1", []string{"global", "auth", "audit"},
+			[]string{"httprouter.headerMiddleware", "auth", "audit"}},
+		{report, http.MethodGet, "/reports", []string{"global", "global", "audit"},
+			[]string{"httprouter.headerMiddleware", "httprouter.headerMiddleware", "audit"}},
+	}
+	for _, test := range tests {
+		applied = nil
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if got := w.Header()["X-Middleware"]; !reflect.DeepEqual(got, test.middleware) {
+			t.Errorf("routing %s %s: wrong middleware %v, want %v", test.method, test.path, got, test.middleware)
+		}
+		if !reflect.DeepEqual(applied, test.names) {
+			t.Errorf("routing %s %s: wrong applied middleware %v, want %v", test.method, test.path, applied, test.names)
+		}
+		if names := test.route.MiddlewareNames(); !reflect.DeepEqual(names, test.names) {
+			t.Errorf("wrong middleware names of %s %s: %v, want %v", test.method, test.path, names, test.names)
+		}
+	}
+
+	if _, err := router.Add(RouteSpec{
+		Method:          http.MethodGet,
+		Path:            "/unknown",
+		Handle:          handle,
+		MiddlewareNames: []string{"nope"},
+	}); err == nil {
+		t.Error("no error for unknown middleware name")
+	}
+	if recv := catchPanic(func() { router.Middleware("nope") }); recv == nil {
+		t.Error("no panic for unknown middleware name")
+	}
+	if recv := catchPanic(func() { router.RegisterMiddleware("auth", tag("auth")) }); recv == nil {
+		t.Error("no panic for duplicate middleware name")
+	}
+}
+
+func TestRouterMiddlewareMatchedRoute(t *testing.T) {
+	var global, matched []string
+	router := New()
+	router.UseGlobal(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			next.ServeHTTP(w, req)
+			if rt := ContextMatchedRoute(req.Context()); rt != nil {
+				global = append(global, rt.Method()+" "+rt.Path())
+			} else {
+				global = append(global, "unmatched "+req.URL.Path)
+			}
+		})
+	})
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			rt := ContextMatchedRoute(req.Context())
+			matched = append(matched, rt.Method()+" "+rt.Path())
+			next.ServeHTTP(w, req)
+		})
+	})
+	router.GET("/users/@id", fakeHandler("/users/@id"))
+	router.Any("/rpc", fakeHandler("/rpc"))
+	billing := New()
+	billing.POST("/invoices/@id", fakeHandler("/invoices/@id"))
+	router.Mount("/billing", billing)
+
+	for _, req := range []struct{ method, path string }{
+		{http.MethodGet, "/users/1"},
+		{http.MethodPut, "/rpc"},
+		{http.MethodGet, "/nothing"},
+		{http.MethodPost, "/billing/invoices/2"},
+	} {
+		r, _ := http.NewRequest(req.method, req.path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	wantGlobal := []string{"GET /users/@id", "* /rpc", "unmatched /nothing", "POST /invoices/@id"}
+	if !reflect.DeepEqual(global, wantGlobal) {
+		t.Errorf("wrong routes for global middleware:\n got %v\nwant %v", global, wantGlobal)
+	}
+	wantMatched := []string{"GET /users/@id", "* /rpc", "* /billing/*mountpath"}
+	if !reflect.DeepEqual(matched, wantMatched) {
+		t.Errorf("wrong routes for middleware:\n got %v\nwant %v", matched, wantMatched)
+	}
+}
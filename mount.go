@@ -0,0 +1,85 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// mountParam is the name of the catch-all parameter of mount routes.
+const mountParam = "mountpath"
+
+// Mount registers handler for all requests with any method to the given
+// prefix and paths below it. The prefix is stripped from the path of the
+// request before it is passed to the handler, e.g. a request to /billing/items
+// is passed to the handler of the prefix /billing with the path /items, and a
+// request to /billing with the path /. The prefix must begin with '/' and must
+// not end with '/'; it may contain named parameters:
+//
+//	router.Mount("/billing", billing.NewRouter())
+//	router.Mount("/tenants/@tenant/files", http.FileServer(root))
+//
+// The params of the prefix are available in the request context, see
+// ParamsFromContext. If the handler is a *Router, its routes also get them
+// in addition to their own params, see Route.IsolateParams.
+// Mount returns the route of the prefix.
+func (r *Router) Mount(prefix string, handler http.Handler) *Route {
+	checkPrefix(prefix)
+	if sub, ok := handler.(*Router); ok {
+		if sub == r {
+			panic("a router can't be mounted on itself")
+		}
+		sub.nested = true
+	}
+
+	return r.Handle(MethodAny, prefix+"/*"+mountParam, func(w http.ResponseWriter, req *http.Request, ps Params) {
+		path := ps.ByName(mountParam)
+		if path == "" {
+			path = "/"
+		}
+
+		outer := make(Params, 0, len(ps)-1)
+		for _, p := range ps {
+			if p.Key != mountParam {
+				outer = append(outer, p)
+			}
+		}
+
+		ctx := WithParams(req.Context(), outer)
+		ctx = context.WithValue(ctx, outerParamsContextKey, outer)
+		req = req.WithContext(ctx)
+
+		u := new(url.URL)
+		*u = *req.URL
+		u.Path = path
+		u.RawPath = rawSuffix(u.RawPath, path)
+		req.URL = u
+		handler.ServeHTTP(w, req)
+	}).MatchBarePrefixValue("/")
+}
+
+// rawSuffix returns the suffix of the escaped path raw which is an encoding
+// of path, i.e. raw without the escaped mount prefix, like http.StripPrefix.
+// It returns "" if there is no such suffix, so that the path is escaped anew.
+func rawSuffix(raw, path string) string {
+	for i := 0; i+len(path) <= len(raw); i++ {
+		if raw[i] != '/' {
+			continue
+		}
+		if p, err := pathUnescape(raw[i:]); err == nil && p == path {
+			return raw[i:]
+		}
+	}
+	return ""
+}
+
+// pathUnescape is like url.PathUnescape, which requires Go 1.8: unlike
+// url.QueryUnescape it keeps '+' as is.
+func pathUnescape(s string) (string, error) {
+	return url.QueryUnescape(strings.Replace(s, "+", "%2B", -1))
+}
@@ -0,0 +1,77 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRouterMount(t *testing.T) {
+	var got string
+	var params Params
+	billing := New()
+	billing.GET("/", func(_ http.ResponseWriter, req *http.Request, ps Params) {
+		got, params = "billing index "+req.URL.Path, ps
+	})
+	billing.POST("/invoices/@id", func(_ http.ResponseWriter, req *http.Request, ps Params) {
+		got, params = "invoice "+req.URL.Path, ps
+	})
+
+	router := New()
+	router.Mount("/billing", billing)
+	router.Mount("/tenants/@tenant/billing", billing)
+	router.Mount("/static", http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		got, params = "static "+req.URL.EscapedPath(), ParamsFromContext(req.Context())
+	}))
+	router.GET("/billing-info", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		got, params = "info", ps
+	})
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		got    string
+		params Params
+	}{
+		{http.MethodGet, "/billing", http.StatusOK, "billing index /", nil},
+		{http.MethodGet, "/billing/", http.StatusOK, "billing index /", nil},
+		{http.MethodPost, "/billing/invoices/7", http.StatusOK, "invoice /invoices/7", Params{{"id", "7"}}},
+		{http.MethodPost, "/tenants/acme/billing/invoices/7", http.StatusOK, "invoice /invoices/7", Params{{"id", "7"}, {"tenant", "acme"}}},
+		{http.MethodGet, "/billing/invoices/7", http.StatusMethodNotAllowed, "", nil},
+		{http.MethodGet, "/billing/nothing", http.StatusNotFound, "", nil},
+		{http.MethodGet, "/static/css/site.css", http.StatusOK, "static /css/site.css", Params{}},
+		{http.MethodGet, "/static/a%2Fb/c%3Fd.txt", http.StatusOK, "static /a%2Fb/c%3Fd.txt", Params{}},
+		{http.MethodGet, "/static/a+b%2Fc.txt", http.StatusOK, "static /a+b%2Fc.txt", Params{}},
+		{http.MethodGet, "/billing-info", http.StatusOK, "info", nil},
+	}
+	for _, test := range tests {
+		got, params = "", nil
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code || got != test.got {
+			t.Errorf("routing %s %s failed: Code=%d, got=%q", test.method, test.path, w.Code, got)
+		}
+		if len(params) != len(test.params) || (len(params) > 0 && !reflect.DeepEqual(params, test.params)) {
+			t.Errorf("routing %s %s: wrong params %v, want %v", test.method, test.path, params, test.params)
+		}
+	}
+}
+
+func TestRouterMountInvalid(t *testing.T) {
+	router := New()
+	for _, prefix := range []string{"", "billing", "/billing/"} {
+		if recv := catchPanic(func() { router.Mount(prefix, http.NotFoundHandler()) }); recv == nil {
+			t.Errorf("no panic for invalid prefix %q", prefix)
+		}
+	}
+	if recv := catchPanic(func() { router.Mount("/self", router) }); recv == nil {
+		t.Error("no panic for mounting a router on itself")
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// OpenAPIInfo is the info object of the document served by the
+// OpenAPIHandler.
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type openAPIDocument struct {
+	OpenAPI string                                 `json:"openapi"`
+	Info    OpenAPIInfo                            `json:"info"`
+	Paths   map[string]map[string]openAPIOperation `json:"paths"`
+}
+
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId,omitempty"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name     string        `json:"name"`
+	In       string        `json:"in"`
+	Required bool          `json:"required"`
+	Schema   openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Type    string   `json:"type"`
+	Format  string   `json:"format,omitempty"`
+	Pattern string   `json:"pattern,omitempty"`
+	Minimum *int     `json:"minimum,omitempty"`
+	Enum    []string `json:"enum,omitempty"`
+}
+
+type openAPIResponse struct {
+	Description string `json:"description"`
+}
+
+// openAPIMethods are the methods with an operation in OpenAPI path items.
+var openAPIMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodPut:     true,
+	http.MethodPost:    true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+	http.MethodHead:    true,
+	http.MethodPatch:   true,
+	http.MethodTrace:   true,
+}
+
+// OpenAPIHandler returns a handler replying with a minimal OpenAPI 3 JSON
+// document of the registered routes. Each path is listed in OpenAPI form,
+// e.g. /users/{id} for /users/@id, with an operation for each method
+// registered for it. The params of the path and the query params promoted by
+// Route.QueryParam are listed as required parameters. Params are strings,
+// except for params of the built-in types int and uint, which are integers;
+// params with an enum list its values, params with a regular expression have
+// it as pattern, and the other built-in types are described by a format or a
+// pattern. The name of a named route is its operation id.
+// Routes with an optional parameter are listed with and without it.
+// Routes for methods OpenAPI has no operation for, like MethodAny, are left
+// out.
+// Like registering routes, the handler is not concurrency-safe with the
+// registration of routes.
+func (r *Router) OpenAPIHandler(info OpenAPIInfo) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := json.MarshalIndent(r.openAPIDocument(info), "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+}
+
+func (r *Router) openAPIDocument(info OpenAPIInfo) openAPIDocument {
+	doc := openAPIDocument{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Paths:   make(map[string]map[string]openAPIOperation),
+	}
+	for _, rt := range r.routes {
+		if !openAPIMethods[rt.method] {
+			continue
+		}
+		paths := []string{rt.path}
+		if base, opt, ok := splitOptionalParam(rt.path); ok {
+			wildcard, _, _ := parseOptionalParam(opt)
+			paths = []string{base, base + "/" + wildcard}
+			if base == "" {
+				paths[0] = "/"
+			}
+		}
+		for _, path := range paths {
+			openAPIPath, params := openAPIPath(path)
+			for _, name := range rt.queryParams {
+				params = append(params, openAPIParameter{
+					Name:     name,
+					In:       "query",
+					Required: true,
+					Schema:   openAPISchema{Type: "string"},
+				})
+			}
+
+			item := doc.Paths[openAPIPath]
+			if item == nil {
+				item = make(map[string]openAPIOperation)
+				doc.Paths[openAPIPath] = item
+			}
+			method := strings.ToLower(rt.method)
+			if _, ok := item[method]; ok {
+				// a route registered for the same method and path
+				continue
+			}
+			item[method] = openAPIOperation{
+				OperationID: rt.name,
+				Parameters:  params,
+				Responses: map[string]openAPIResponse{
+					"default": {Description: "default response"},
+				},
+			}
+		}
+	}
+	return doc
+}
+
+// openAPIPath converts a route path to the form of OpenAPI, e.g. /users/{id}
+// for /users/@id, and returns its path parameters.
+func openAPIPath(fullPath string) (string, []openAPIParameter) {
+	path := fullPath
+	var buf []byte
+	var params []openAPIParameter
+	for {
+		wildcard, i, _ := findWildcard(path)
+		if i < 0 {
+			break
+		}
+		buf = append(buf, path[:i]...)
+		path = path[i+len(wildcard):]
+
+		_, constraint := splitConstraint(wildcard)
+		wildcard, enum := parseEnum(wildcard, fullPath)
+		name := wildcard[1:]
+		buf = append(buf, '{')
+		buf = append(buf, name...)
+		buf = append(buf, '}')
+
+		param := openAPIParameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   openAPIConstraintSchema(constraint),
+		}
+		for value := range enum {
+			param.Schema.Enum = append(param.Schema.Enum, value)
+		}
+		sort.Strings(param.Schema.Enum)
+		params = append(params, param)
+	}
+	buf = append(buf, path...)
+	return string(buf), params
+}
+
+// openAPIConstraintSchema returns the schema of the values of a param with the
+// given constraint, e.g. ([0-9]+) or <int>, see openAPIPath.
+func openAPIConstraintSchema(constraint string) openAPISchema {
+	switch {
+	case constraint == "" || constraint[0] == '{':
+		// the values of an enum are added by openAPIPath
+		return openAPISchema{Type: "string"}
+	case constraint[0] == '(':
+		return openAPISchema{Type: "string", Pattern: "^(?:" + constraint[1:len(constraint)-1] + ")$"}
+	}
+
+	switch constraint[1 : len(constraint)-1] {
+	case "int":
+		return openAPISchema{Type: "integer"}
+	case "uint":
+		min := 0
+		return openAPISchema{Type: "integer", Minimum: &min}
+	case "alpha":
+		return openAPISchema{Type: "string", Pattern: "^[A-Za-z]+$"}
+	case "alnum":
+		return openAPISchema{Type: "string", Pattern: "^[A-Za-z0-9]+$"}
+	case "hex":
+		return openAPISchema{Type: "string", Pattern: "^[0-9A-Fa-f]+$"}
+	case "uuid":
+		return openAPISchema{Type: "string", Format: "uuid"}
+	}
+	// registered types, see Router.RegisterConstraint
+	return openAPISchema{Type: "string"}
+}
@@ -0,0 +1,131 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRouterOpenAPIHandler(t *testing.T) {
+	router := New()
+	router.GET("/users", fakeHandler("/users"))
+	router.POST("/users", fakeHandler("/users"))
+	router.GET("/users/@id", fakeHandler("/users/@id")).Named("user")
+	router.DELETE("/users/@id", fakeHandler("/users/@id"))
+	router.GET("/issues/@state{open|closed}", fakeHandler("/issues/@state"))
+	router.GET("/files/*path", fakeHandler("/files/*path"))
+	router.GET("/search", fakeHandler("/search")).QueryParam("q")
+	router.GET("/posts/@page?", fakeHandler("/posts/@page?"))
+	router.Any("/rpc", fakeHandler("/rpc"))
+	router.Handler(http.MethodGet, "/openapi.json", router.OpenAPIHandler(OpenAPIInfo{
+		Title:   "Test",
+		Version: "1.0",
+	}))
+
+	r, _ := http.NewRequest(http.MethodGet, "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("OpenAPI handler failed: Code=%d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("wrong Content-Type: %q", ct)
+	}
+
+	var doc openAPIDocument
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" || doc.Info.Title != "Test" || doc.Info.Version != "1.0" {
+		t.Errorf("wrong document header: %q, %+v", doc.OpenAPI, doc.Info)
+	}
+
+	methods := make(map[string][]string)
+	for path, item := range doc.Paths {
+		for _, method := range []string{"get", "post", "delete"} {
+			if _, ok := item[method]; ok {
+				methods[path] = append(methods[path], method)
+			}
+		}
+	}
+	want := map[string][]string{
+		"/users":          {"get", "post"},
+		"/users/{id}":     {"get", "delete"},
+		"/issues/{state}": {"get"},
+		"/files/{path}":   {"get"},
+		"/search":         {"get"},
+		"/posts":          {"get"},
+		"/posts/{page}":   {"get"},
+		"/openapi.json":   {"get"},
+	}
+	if !reflect.DeepEqual(methods, want) {
+		t.Errorf("wrong paths:\n got %v\nwant %v", methods, want)
+	}
+
+	if op := doc.Paths["/users/{id}"]["get"]; op.OperationID != "user" ||
+		!reflect.DeepEqual(op.Parameters, []openAPIParameter{
+			{Name: "id", In: "path", Required: true, Schema: openAPISchema{Type: "string"}},
+		}) {
+		t.Errorf("wrong operation of named route: %+v", op)
+	}
+	if op := doc.Paths["/issues/{state}"]["get"]; len(op.Parameters) != 1 ||
+		!reflect.DeepEqual(op.Parameters[0].Schema.Enum, []string{"closed", "open"}) {
+		t.Errorf("wrong enum parameter: %+v", op.Parameters)
+	}
+	if op := doc.Paths["/search"]["get"]; len(op.Parameters) != 1 ||
+		op.Parameters[0].Name != "q" || op.Parameters[0].In != "query" {
+		t.Errorf("wrong query parameter: %+v", op.Parameters)
+	}
+	if _, ok := doc.Paths["/rpc"]; ok {
+		t.Error("route for any method listed")
+	}
+	if op := doc.Paths["/posts"]["get"]; len(op.Parameters) != 0 {
+		t.Errorf("unexpected parameters without optional param: %+v", op.Parameters)
+	}
+}
+
+func TestOpenAPIParamSchemas(t *testing.T) {
+	router := New()
+	router.RegisterConstraint("slug", func(string) bool { return true })
+	min := 0
+	tests := []struct {
+		path   string
+		schema openAPISchema
+	}{
+		{"/a/@id", openAPISchema{Type: "string"}},
+		{"/b/@id<int>", openAPISchema{Type: "integer"}},
+		{"/c/@id<uint>", openAPISchema{Type: "integer", Minimum: &min}},
+		{"/d/@id<uuid>", openAPISchema{Type: "string", Format: "uuid"}},
+		{"/e/@id<hex>", openAPISchema{Type: "string", Pattern: "^[0-9A-Fa-f]+$"}},
+		{"/f/@id<alpha>", openAPISchema{Type: "string", Pattern: "^[A-Za-z]+$"}},
+		{"/g/@id<alnum>", openAPISchema{Type: "string", Pattern: "^[A-Za-z0-9]+$"}},
+		{"/h/@id([0-9]{4})", openAPISchema{Type: "string", Pattern: "^(?:[0-9]{4})$"}},
+		{"/i/@id{a|b}", openAPISchema{Type: "string", Enum: []string{"a", "b"}}},
+		{"/j/@id<slug>", openAPISchema{Type: "string"}},
+		{"/k/*path", openAPISchema{Type: "string"}},
+	}
+	for _, test := range tests {
+		router.GET(test.path, fakeHandler(test.path))
+	}
+
+	doc := router.openAPIDocument(OpenAPIInfo{})
+	for _, test := range tests {
+		path, _ := openAPIPath(test.path)
+		op := doc.Paths[path]["get"]
+		if len(op.Parameters) != 1 || !reflect.DeepEqual(op.Parameters[0].Schema, test.schema) {
+			t.Errorf("wrong parameters of %s: %+v", test.path, op.Parameters)
+		}
+	}
+
+	// the schemas are encoded in the OpenAPI form
+	body, _ := json.Marshal(doc.Paths["/c/{id}"]["get"].Parameters[0].Schema)
+	if want := `{"type":"integer","minimum":0}`; string(body) != want {
+		t.Errorf("wrong JSON of schema: %s, want %s", body, want)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"time"
+)
+
+// Option configures a Router created by New.
+type Option func(*Router)
+
+// WithRedirectTrailingSlash sets Router.RedirectTrailingSlash.
+func WithRedirectTrailingSlash(enabled bool) Option {
+	return func(r *Router) {
+		r.RedirectTrailingSlash = enabled
+	}
+}
+
+// WithRedirectFixedPath sets Router.RedirectFixedPath.
+func WithRedirectFixedPath(enabled bool) Option {
+	return func(r *Router) {
+		r.RedirectFixedPath = enabled
+	}
+}
+
+// WithRedirectFixedCase sets Router.RedirectFixedCase.
+func WithRedirectFixedCase(enabled bool) Option {
+	return func(r *Router) {
+		r.RedirectFixedCase = enabled
+	}
+}
+
+// WithHandleMethodNotAllowed sets Router.HandleMethodNotAllowed.
+func WithHandleMethodNotAllowed(enabled bool) Option {
+	return func(r *Router) {
+		r.HandleMethodNotAllowed = enabled
+	}
+}
+
+// WithHandleOPTIONS sets Router.HandleOPTIONS.
+func WithHandleOPTIONS(enabled bool) Option {
+	return func(r *Router) {
+		r.HandleOPTIONS = enabled
+	}
+}
+
+// WithSaveMatchedRoutePath sets Router.SaveMatchedRoutePath.
+func WithSaveMatchedRoutePath(enabled bool) Option {
+	return func(r *Router) {
+		r.SaveMatchedRoutePath = enabled
+	}
+}
+
+// WithNotFound sets the Router.NotFound handler.
+func WithNotFound(handler http.Handler) Option {
+	return func(r *Router) {
+		r.NotFound = handler
+	}
+}
+
+// WithMethodNotAllowed sets the Router.MethodNotAllowed handler.
+func WithMethodNotAllowed(handler http.Handler) Option {
+	return func(r *Router) {
+		r.MethodNotAllowed = handler
+	}
+}
+
+// WithFallback sets the Router.Fallback handler.
+func WithFallback(handler http.Handler) Option {
+	return func(r *Router) {
+		r.Fallback = handler
+	}
+}
+
+// WithPanicHandler sets Router.PanicHandler.
+func WithPanicHandler(handler func(http.ResponseWriter, *http.Request, interface{})) Option {
+	return func(r *Router) {
+		r.PanicHandler = handler
+	}
+}
+
+// WithLimits sets Router.DefaultMaxBody and Router.DefaultTimeout.
+func WithLimits(maxBody int64, timeout time.Duration) Option {
+	return func(r *Router) {
+		r.DefaultMaxBody = maxBody
+		r.DefaultTimeout = timeout
+	}
+}
+
+// WithMuxPatterns sets Router.MuxPatterns.
+func WithMuxPatterns(enabled bool) Option {
+	return func(r *Router) {
+		r.MuxPatterns = enabled
+	}
+}
+
+// WithServeMuxPatterns sets Router.ServeMuxPatterns.
+func WithServeMuxPatterns(enabled bool) Option {
+	return func(r *Router) {
+		r.ServeMuxPatterns = enabled
+	}
+}
+
+// WithMiddleware adds middleware to the router, see Router.Use.
+func WithMiddleware(middleware ...func(http.Handler) http.Handler) Option {
+	return func(r *Router) {
+		r.Use(middleware...)
+	}
+}
+
+// WithGlobalMiddleware adds global middleware to the router, see
+// Router.UseGlobal.
+func WithGlobalMiddleware(middleware ...func(http.Handler) http.Handler) Option {
+	return func(r *Router) {
+		r.UseGlobal(middleware...)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewDefaults(t *testing.T) {
+	router := New()
+	if !router.RedirectTrailingSlash || !router.RedirectFixedPath ||
+		!router.HandleMethodNotAllowed || !router.HandleOPTIONS {
+		t.Errorf("wrong defaults: %+v", router)
+	}
+}
+
+func TestNewWithOptions(t *testing.T) {
+	notFound := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	panicked := false
+	router := New(
+		WithRedirectTrailingSlash(false),
+		WithRedirectFixedPath(false),
+		WithRedirectFixedCase(true),
+		WithHandleMethodNotAllowed(false),
+		WithHandleOPTIONS(false),
+		WithSaveMatchedRoutePath(true),
+		WithNotFound(notFound),
+		WithPanicHandler(func(http.ResponseWriter, *http.Request, interface{}) {
+			panicked = true
+		}),
+		WithLimits(1024, time.Second),
+	)
+
+	if router.RedirectTrailingSlash || router.RedirectFixedPath || !router.RedirectFixedCase ||
+		router.HandleMethodNotAllowed || router.HandleOPTIONS || !router.SaveMatchedRoutePath ||
+		router.DefaultMaxBody != 1024 || router.DefaultTimeout != time.Second {
+		t.Errorf("options not applied: %+v", router)
+	}
+
+	router.GET("/path", fakeHandler("/path"))
+	router.GET("/panic", func(http.ResponseWriter, *http.Request, Params) {
+		panic("oops")
+	})
+
+	for _, route := range []string{"/path/", "/nope"} {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodGet, route, nil)
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusTeapot {
+			t.Errorf("routing %s failed: Code=%d", route, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodGet, "/panic", nil)
+	router.ServeHTTP(w, r)
+	if !panicked {
+		t.Error("panic handler not called")
+	}
+
+	// later options override earlier ones
+	router = New(WithRedirectFixedPath(false), WithRedirectFixedPath(true))
+	if !router.RedirectFixedPath {
+		t.Error("later option not applied")
+	}
+}
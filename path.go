@@ -5,6 +5,11 @@
 
 package httprouter
 
+import (
+	"net/url"
+	"strings"
+)
+
 // CleanPath is the URL version of path.Clean, it returns a canonical URL path
 // for p, eliminating . and .. elements.
 //
@@ -148,3 +153,77 @@ func bufApp(buf *[]byte, s string, w int, c byte) {
 	}
 	b[w] = c
 }
+
+// preservedSlashPath returns the decoded path of the URL, except for encoded
+// slashes, which are kept as %2F, and percent signs, which are kept as %25,
+// so that the path can be split into segments without losing the encoded
+// slashes, see Router.PreserveEncodedSlash.
+func preservedSlashPath(u *url.URL) string {
+	raw := u.EscapedPath()
+	if strings.IndexByte(raw, '%') < 0 {
+		return u.Path
+	}
+
+	buf := make([]byte, 0, len(raw))
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '%' || i+2 >= len(raw) {
+			buf = append(buf, raw[i])
+			continue
+		}
+		c := unhex(raw[i+1])<<4 | unhex(raw[i+2])
+		if c == '/' || c == '%' {
+			buf = append(buf, '%', raw[i+1], raw[i+2])
+		} else {
+			buf = append(buf, c)
+		}
+		i += 2
+	}
+	return string(buf)
+}
+
+// unhex returns the value of the hex digit c.
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10
+	}
+	return 0
+}
+
+var slashUnescaper = strings.NewReplacer("%2F", "/", "%2f", "/", "%25", "%")
+
+// unescapeSlashes decodes the encoded slashes and percent signs kept in the
+// values of params matched against a path returned by preservedSlashPath.
+func unescapeSlashes(ps Params) {
+	for i := range ps {
+		if strings.IndexByte(ps[i].Value, '%') >= 0 {
+			ps[i].Value = slashUnescaper.Replace(ps[i].Value)
+		}
+	}
+}
+
+// setPreservedSlashPath sets the path of the URL to a path in the form
+// returned by preservedSlashPath, so that the encoded slashes and percent
+// signs stay encoded in the escaped path of the URL.
+func setPreservedSlashPath(u *url.URL, path string) {
+	u.Path = slashUnescaper.Replace(path)
+	if strings.IndexByte(path, '%') < 0 {
+		u.RawPath = ""
+		return
+	}
+
+	parts := strings.Split(path, "%")
+	raw := (&url.URL{Path: parts[0]}).EscapedPath()
+	for _, part := range parts[1:] {
+		if len(part) < 2 {
+			raw += "%25" + (&url.URL{Path: part}).EscapedPath()
+			continue
+		}
+		raw += "%" + part[:2] + (&url.URL{Path: part[2:]}).EscapedPath()
+	}
+	u.RawPath = raw
+}
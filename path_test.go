@@ -6,6 +6,8 @@
 package httprouter
 
 import (
+	"net/url"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -148,3 +150,35 @@ func BenchmarkPathCleanLong(b *testing.B) {
 		}
 	}
 }
+
+func TestPreservedSlashPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		result string
+	}{
+		{"/plain/path", "/plain/path"},
+		{"/a%2Fb", "/a%2Fb"},
+		{"/a%2fb/c", "/a%2fb/c"},
+		{"/100%25", "/100%25"},
+		{"/caf%C3%A9%20au%20lait", "/café au lait"},
+		{"/a%2Fb/caf%c3%a9", "/a%2Fb/café"},
+	}
+	for _, test := range tests {
+		u, err := url.Parse(test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := preservedSlashPath(u); s != test.result {
+			t.Errorf("preservedSlashPath(%q) = %q, want %q", test.path, s, test.result)
+		}
+	}
+}
+
+func TestUnescapeSlashes(t *testing.T) {
+	ps := Params{{"a", "x%2Fy"}, {"b", "100%25"}, {"c", "plain"}, {"d", "%252F"}}
+	unescapeSlashes(ps)
+	want := Params{{"a", "x/y"}, {"b", "100%"}, {"c", "plain"}, {"d", "%2F"}}
+	if !reflect.DeepEqual(ps, want) {
+		t.Errorf("unescapeSlashes: got %v, want %v", ps, want)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import "strings"
+
+// serveMuxPath converts the placeholders of the patterns of http.ServeMux
+// (since Go 1.22) in path to the native syntax: {name} to @name, {name...}
+// to *name, and a trailing {$} is dropped, since routes match exactly anyway.
+// E.g. /files/{id}/{path...} becomes /files/@id/*path.
+// Placeholders must span a whole path segment, a {name...} or {$} must be the
+// last one. Enums like @state{open|closed} are not affected.
+func serveMuxPath(path string) string {
+	masked := maskConstraints(path)
+	if !strings.Contains(masked, "/{") {
+		return path
+	}
+
+	buf := make([]byte, 0, len(path))
+	for i := 0; i < len(path); i++ {
+		if masked[i] != '{' || i == 0 || path[i-1] != '/' {
+			buf = append(buf, path[i])
+			continue
+		}
+
+		end := strings.IndexByte(path[i:], '}')
+		if end < 0 {
+			panic("unterminated placeholder in path '" + path + "'")
+		}
+		end += i
+		if end+1 < len(path) && path[end+1] != '/' {
+			panic("placeholders must span a whole path segment in path '" + path + "'")
+		}
+		name := path[i+1 : end]
+		last := end+1 == len(path)
+
+		switch {
+		case name == "$":
+			if !last {
+				panic("{$} must be at the end of the path in path '" + path + "'")
+			}
+		case strings.HasSuffix(name, "..."):
+			if !last {
+				panic("placeholder '{" + name + "}' must be at the end of the path in path '" + path + "'")
+			}
+			buf = append(buf, '*')
+			buf = append(buf, name[:len(name)-3]...)
+		default:
+			buf = append(buf, '@')
+			buf = append(buf, name...)
+		}
+		i = end
+	}
+	return string(buf)
+}
+
+// hasMuxPlaceholder reports whether path contains a placeholder of
+// gorilla/mux with a regular expression, like {id:[0-9]+}, outside of the
+// constraints of native wildcards. Such placeholders are only understood with
+// Router.MuxPatterns enabled.
+func hasMuxPlaceholder(path string) bool {
+	masked := maskConstraints(path)
+	for i := 0; i < len(masked); i++ {
+		if masked[i] != '{' {
+			continue
+		}
+		if end := strings.IndexAny(masked[i+1:], ":}/"); end >= 0 && masked[i+1+end] == ':' {
+			return true
+		}
+	}
+	return false
+}
+
+// muxPath converts a pattern of gorilla/mux to the native syntax: {name} to
+// @name and {name:regexp} to @name(regexp), or to the catch-all *name if the
+// regular expression is .* and spans the last path segment.
+// E.g. /articles/{category}/{id:[0-9]+} becomes
+// /articles/@category/@id([0-9]+).
+// It panics if path contains one of the native wildcards '@' and '*' outside
+// of a placeholder.
+func muxPath(path string) string {
+	buf := make([]byte, 0, len(path)+2)
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '{':
+		case '}':
+			panic("unbalanced braces in gorilla/mux pattern '" + path + "'")
+		case '@', '*':
+			panic("native wildcard '" + path[i:i+1] + "' in gorilla/mux pattern '" + path + "'")
+		default:
+			buf = append(buf, path[i])
+			continue
+		}
+
+		// The regular expression may contain braces as well
+		end, level := i, 0
+		for ; end < len(path); end++ {
+			if path[end] == '{' {
+				level++
+			} else if path[end] == '}' {
+				level--
+			}
+			if level == 0 {
+				break
+			}
+		}
+		if end == len(path) {
+			panic("unterminated placeholder in gorilla/mux pattern '" + path + "'")
+		}
+
+		name, re := path[i+1:end], ""
+		if colon := strings.IndexByte(name, ':'); colon >= 0 {
+			name, re = name[:colon], name[colon+1:]
+		}
+		switch {
+		case re == "":
+			buf = append(buf, '@')
+			buf = append(buf, name...)
+		case re == ".*" && i > 0 && path[i-1] == '/' && end+1 == len(path):
+			buf = append(buf, '*')
+			buf = append(buf, name...)
+		default:
+			buf = append(buf, '@')
+			buf = append(buf, name...)
+			buf = append(buf, '(')
+			buf = append(buf, re...)
+			buf = append(buf, ')')
+		}
+		i = end
+	}
+	return string(buf)
+}
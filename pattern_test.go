@@ -0,0 +1,181 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestServeMuxPath(t *testing.T) {
+	tests := []struct {
+		path, native string
+	}{
+		{"/", "/"},
+		{"/users/@id", "/users/@id"},
+		{"/users/{id}", "/users/@id"},
+		{"/users/{id}/posts/{post}", "/users/@id/posts/@post"},
+		{"/files/{path...}", "/files/*path"},
+		{"/users/{$}", "/users/"},
+		{"/{$}", "/"},
+		{"/status/@state{open|closed}", "/status/@state{open|closed}"},
+		{"/dates/@date([0-9]{4})/{id}", "/dates/@date([0-9]{4})/@id"},
+	}
+	for _, test := range tests {
+		if native := serveMuxPath(test.path); native != test.native {
+			t.Errorf("serveMuxPath(%q) = %q, want %q", test.path, native, test.native)
+		}
+	}
+
+	for _, path := range []string{
+		"/users/{id",
+		"/users/{id}x",
+		"/files/{path...}/x",
+		"/users/{$}/x",
+	} {
+		if recv := catchPanic(func() { serveMuxPath(path) }); recv == nil {
+			t.Errorf("no panic for invalid path '%s'", path)
+		}
+	}
+}
+
+func TestRouterServeMuxPatterns(t *testing.T) {
+	var params Params
+	handle := func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		params = ps
+	}
+
+	router := New(WithServeMuxPatterns(true))
+	user := router.GET("/users/{id}", handle)
+	router.GET("/files/{path...}", handle)
+	router.GET("/posts/{$}", handle)
+
+	tests := []struct {
+		route  string
+		code   int
+		params Params
+	}{
+		{"/users/42", http.StatusOK, Params{{"id", "42"}}},
+		{"/files/a/b.txt", http.StatusOK, Params{{"path", "/a/b.txt"}}},
+		{"/posts/", http.StatusOK, nil},
+		{"/posts/1", http.StatusNotFound, nil},
+	}
+	for _, test := range tests {
+		params = nil
+		r, _ := http.NewRequest(http.MethodGet, test.route, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code || !reflect.DeepEqual(params, test.params) {
+			t.Errorf("routing %s failed: Code=%d, Params=%v", test.route, w.Code, params)
+		}
+	}
+
+	if path := user.Path(); path != "/users/@id" {
+		t.Errorf("wrong path of the route: %q", path)
+	}
+
+	// the converted path conflicts like the native one
+	if recv := catchPanic(func() { router.GET("/users/@id", handle) }); recv == nil {
+		t.Error("no panic for a route with the same path in the native syntax")
+	}
+
+	// without ServeMuxPatterns, braces are part of static segments
+	router = New()
+	router.GET("/templates/{id}", handle)
+	staticTests := []struct {
+		route string
+		code  int
+	}{
+		{"/templates/%7Bid%7D", http.StatusOK},
+		{"/templates/42", http.StatusNotFound},
+	}
+	for _, test := range staticTests {
+		params = nil
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.route, nil))
+		if w.Code != test.code || params != nil {
+			t.Errorf("routing %s without ServeMuxPatterns failed: Code=%d, Params=%v", test.route, w.Code, params)
+		}
+	}
+}
+
+func TestMuxPath(t *testing.T) {
+	tests := []struct {
+		path, native string
+	}{
+		{"/", "/"},
+		{"/users/{id}", "/users/@id"},
+		{"/articles/{category}/{id:[0-9]+}", "/articles/@category/@id([0-9]+)"},
+		{"/dates/{date:[0-9]{4}-[0-9]{2}}", "/dates/@date([0-9]{4}-[0-9]{2})"},
+		{"/files/{name}.{ext:json|xml}", "/files/@name.@ext(json|xml)"},
+		{"/static/{path:.*}", "/static/*path"},
+		{"/static/{path:.*}/raw", "/static/@path(.*)/raw"},
+	}
+	for _, test := range tests {
+		if native := muxPath(test.path); native != test.native {
+			t.Errorf("muxPath(%q) = %q, want %q", test.path, native, test.native)
+		}
+	}
+
+	for _, path := range []string{
+		"/users/@id",
+		"/files/*path",
+		"/users/{id",
+		"/users/id}",
+		"/dates/{date:[0-9]{4}",
+	} {
+		if recv := catchPanic(func() { muxPath(path) }); recv == nil {
+			t.Errorf("no panic for invalid gorilla/mux pattern '%s'", path)
+		}
+	}
+}
+
+func TestRouterMuxPatterns(t *testing.T) {
+	var params Params
+	handle := func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		params = ps
+	}
+
+	router := New(WithMuxPatterns(true))
+	router.GET("/articles/{category}/{id:[0-9]+}", handle)
+	router.GET("/static/{path:.*}", handle)
+
+	tests := []struct {
+		route  string
+		code   int
+		params Params
+	}{
+		{"/articles/go/42", http.StatusOK, Params{{"category", "go"}, {"id", "42"}}},
+		{"/articles/go/latest", http.StatusNotFound, nil},
+		// unlike with gorilla/mux, the catch-all value starts with a '/'
+		{"/static/css/site.css", http.StatusOK, Params{{"path", "/css/site.css"}}},
+	}
+	for _, test := range tests {
+		params = nil
+		r, _ := http.NewRequest(http.MethodGet, test.route, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code || !reflect.DeepEqual(params, test.params) {
+			t.Errorf("routing %s failed: Code=%d, Params=%v", test.route, w.Code, params)
+		}
+	}
+
+	if recv := catchPanic(func() { router.GET("/users/@id", handle) }); recv == nil {
+		t.Error("no panic for a native wildcard in a gorilla/mux pattern")
+	}
+
+	// placeholders with regular expressions require MuxPatterns
+	for _, options := range [][]Option{nil, {WithServeMuxPatterns(true)}} {
+		router = New(options...)
+		if recv := catchPanic(func() { router.GET("/a/{id:[0-9]+}", handle) }); recv == nil {
+			t.Error("no panic for a gorilla/mux placeholder without MuxPatterns")
+		}
+	}
+	router = New()
+	router.GET("/dates/@date([0-9]{4})", handle)
+	router.GET("/status/@state{open|closed}", handle)
+}
@@ -0,0 +1,120 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit limits the requests to this route to n per the given period for
+// each client, e.g. RateLimit(5, time.Minute). Requests exceeding the limit
+// are answered with 429 Too Many Requests and a Retry-After header.
+// The limit is enforced by a token bucket per client, which holds up to n
+// tokens and is refilled continuously. Clients are identified by their key,
+// see Router.ClientKeyFunc. By default the key is the IP address of the
+// client; Router.ClientIPHeader must only be set behind trusted proxies, or
+// clients can evade the limit by sending the header themselves.
+// The state of the limiter is kept in memory, buckets of inactive clients
+// are dropped.
+// It panics if n or the period is not positive.
+func (rt *Route) RateLimit(n int, per time.Duration) *Route {
+	if n <= 0 || per <= 0 {
+		panic("rate limit must be positive")
+	}
+	rt.limiter = &rateLimiter{
+		burst:   float64(n),
+		rate:    float64(n) / float64(per),
+		per:     per,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+	return rt
+}
+
+// rateLimiter is a token bucket rate limiter keyed by client.
+type rateLimiter struct {
+	burst float64 // capacity of a bucket
+	rate  float64 // tokens per nanosecond
+	per   time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// clone returns a limiter with the same limit and no buckets, i.e. with a
+// budget of its own.
+func (l *rateLimiter) clone() *rateLimiter {
+	return &rateLimiter{
+		burst:   l.burst,
+		rate:    l.rate,
+		per:     l.per,
+		buckets: make(map[string]*bucket),
+		now:     l.now,
+	}
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token from the bucket of the given client. If the bucket is
+// empty, it returns false and the time until the next token is available.
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b := l.buckets[key]
+	if b == nil {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	} else {
+		b.tokens += float64(now.Sub(b.last)) * l.rate
+		if b.tokens > l.burst {
+			b.tokens = l.burst
+		}
+		b.last = now
+	}
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate)
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep drops the buckets which are full again, at most once per period.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.per {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.tokens+float64(now.Sub(b.last))*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// tooManyRequests answers a request exceeding a rate limit.
+func tooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int64((retryAfter + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	http.Error(w,
+		http.StatusText(http.StatusTooManyRequests),
+		http.StatusTooManyRequests,
+	)
+}
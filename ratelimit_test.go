@@ -0,0 +1,108 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRouteRateLimit(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+
+	router := New()
+	rt := router.POST("/login", fakeHandler("/login")).RateLimit(3, time.Minute)
+	rt.limiter.now = func() time.Time { return now }
+	router.POST("/logout", fakeHandler("/logout"))
+
+	serve := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(http.MethodPost, path, nil)
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		if w := serve("/login", "192.0.2.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("request %d within limit failed: Code=%d", i, w.Code)
+		}
+	}
+
+	w := serve("/login", "192.0.2.1:5678")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request over limit not rejected: Code=%d", w.Code)
+	}
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "20" {
+		t.Errorf("wrong Retry-After header: %q", retryAfter)
+	}
+
+	// other clients and routes are not affected
+	if w := serve("/login", "192.0.2.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("request of other client rejected: Code=%d", w.Code)
+	}
+	if w := serve("/logout", "192.0.2.1:1234"); w.Code != http.StatusOK {
+		t.Errorf("request to other route rejected: Code=%d", w.Code)
+	}
+
+	// tokens are refilled over time
+	now = now.Add(20 * time.Second)
+	if w := serve("/login", "192.0.2.1:1234"); w.Code != http.StatusOK {
+		t.Errorf("request after refill rejected: Code=%d", w.Code)
+	}
+	if w := serve("/login", "192.0.2.1:1234"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("request over limit not rejected: Code=%d", w.Code)
+	}
+
+	// buckets of inactive clients are dropped
+	now = now.Add(2 * time.Minute)
+	serve("/login", "192.0.2.3:1234")
+	if n := len(rt.limiter.buckets); n != 1 {
+		t.Errorf("inactive buckets not dropped: %d buckets", n)
+	}
+}
+
+func TestRouteRateLimitClientIPHeader(t *testing.T) {
+	router := New()
+	router.ClientIPHeader = "X-Forwarded-For"
+	router.GET("/search", fakeHandler("/search")).RateLimit(1, time.Hour)
+
+	serve := func(forwardedFor string) int {
+		r, _ := http.NewRequest(http.MethodGet, "/search", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		if forwardedFor != "" {
+			r.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := serve("192.0.2.1"); code != http.StatusOK {
+		t.Errorf("first request rejected: Code=%d", code)
+	}
+	if code := serve("192.0.2.1, 192.0.2.2"); code != http.StatusOK {
+		t.Errorf("request of other client rejected: Code=%d", code)
+	}
+	if code := serve("203.0.113.9, 192.0.2.1"); code != http.StatusTooManyRequests {
+		t.Errorf("request over limit with spoofed address not rejected: Code=%d", code)
+	}
+	if code := serve(""); code != http.StatusOK {
+		t.Errorf("request without header rejected: Code=%d", code)
+	}
+}
+
+func TestRouteRateLimitInvalid(t *testing.T) {
+	router := New()
+	rt := router.GET("/", fakeHandler("/"))
+	if recv := catchPanic(func() { rt.RateLimit(0, time.Minute) }); recv == nil {
+		t.Error("no panic for zero rate limit")
+	}
+	if recv := catchPanic(func() { rt.RateLimit(1, 0) }); recv == nil {
+		t.Error("no panic for zero period")
+	}
+}
@@ -0,0 +1,883 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RouteSpec describes a route to be registered with Router.Add.
+type RouteSpec struct {
+	// The request method and path, as passed to Router.Handle
+	Method string
+	Path   string
+
+	// An optional name of the route
+	Name string
+
+	// The handle of the route
+	Handle Handle
+
+	// Middleware wrapped around the handle. The first middleware is the
+	// outermost one. The params are available in the request context under
+	// ParamsKey.
+	Middleware []func(http.Handler) http.Handler
+
+	// Names of middleware registered by Router.RegisterMiddleware, which is
+	// wrapped around the handle inside the Middleware.
+	MiddlewareNames []string
+
+	// Constraints for the values of the params of the route, by param name.
+	// If the value of a param does not satisfy its constraint, the request is
+	// answered like an unmatched request by the NotFound handler.
+	Constraints map[string]func(value string) bool
+}
+
+// Add registers the route described by spec. Unlike Handle, it returns an
+// error instead of panicking if the spec is invalid or the route conflicts
+// with an already registered route.
+func (r *Router) Add(spec RouteSpec) (rt *Route, err error) {
+	defer func() {
+		if rcv := recover(); rcv != nil {
+			if spec.Method != "" {
+				// drop a partially inserted route
+				r.rebuild(spec.Method)
+			}
+			rt, err = nil, fmt.Errorf("invalid route %s %s: %v", spec.Method, spec.Path, rcv)
+		}
+	}()
+
+	middleware := spec.Middleware
+	if len(spec.MiddlewareNames) > 0 {
+		named, err := r.lookupMiddleware(spec.MiddlewareNames)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route %s %s: %v", spec.Method, spec.Path, err)
+		}
+		middleware = append(middleware[:len(middleware):len(middleware)], named...)
+	}
+
+	handle := spec.Handle
+	var names []string
+	if handle != nil && len(middleware) > 0 {
+		handle, names = wrapMiddleware(handle, middleware)
+	}
+
+	if spec.Name != "" && r.NamedRoute(spec.Name) != nil {
+		return nil, fmt.Errorf("invalid route %s %s: a route named '%s' is already registered", spec.Method, spec.Path, spec.Name)
+	}
+
+	rt = r.Handle(spec.Method, spec.Path, handle)
+	r.setName(rt, spec.Name)
+	rt.middleware = middleware
+	rt.middlewareNames = names
+	rt.constraints = spec.Constraints
+	return rt, nil
+}
+
+// NamedRoute returns the route with the given name, see Route.Named, or nil if
+// there is no such route.
+func (r *Router) NamedRoute(name string) *Route {
+	return r.named[name]
+}
+
+// indexNames rebuilds the index of the named routes from the registered
+// routes, after routes were removed or replaced. If several routes have the
+// same name, the first one is indexed.
+func (r *Router) indexNames() {
+	r.named = nil
+	for i := len(r.routes) - 1; i >= 0; i-- {
+		if rt := r.routes[i]; rt.name != "" {
+			if r.named == nil {
+				r.named = make(map[string]*Route)
+			}
+			r.named[rt.name] = rt
+		}
+	}
+}
+
+// setName sets the name of the route and indexes it, see Route.Named.
+func (r *Router) setName(rt *Route, name string) {
+	if r.named[rt.name] == rt {
+		delete(r.named, rt.name)
+	}
+	rt.name = name
+	if name == "" {
+		return
+	}
+	if r.named == nil {
+		r.named = make(map[string]*Route)
+	}
+	r.named[name] = rt
+}
+
+// StaticURLs returns the paths of all named routes without params by name,
+// e.g. to generate a sitemap. Named routes with params are left out.
+func (r *Router) StaticURLs() map[string]string {
+	urls := make(map[string]string)
+	for _, rt := range r.routes {
+		if rt.name != "" && countParams(rt.path) == 0 {
+			urls[rt.name] = rt.path
+		}
+	}
+	return urls
+}
+
+// Route is a route as registered with Router.Handle. Its methods can be used
+// to configure the route after registration, e.g.:
+//  router.POST("/upload", upload).MaxBody(10 << 20).Timeout(time.Minute)
+// The trees of the router can always be rebuilt from the registered routes.
+type Route struct {
+	router *Router
+	method string
+	path   string
+	handle Handle
+	name   string
+
+	middleware      []func(http.Handler) http.Handler
+	middlewareNames []string
+	constraints     map[string]func(value string) bool
+
+	// Checks of the values of the params with an enum, a regular expression
+	// or a type in the path by name, compiled once for URL
+	checks map[string]func(value string) bool
+
+	validators      map[string]func(value string) error
+	queryParams     []string
+
+	// Whether the path is passed as MatchedRoutePathParam, see
+	// Router.SaveMatchedRoutePath
+	saveMatchedPath bool
+
+	// Whether a trailing catch-all also matches the bare path before it, see
+	// Router.MatchBareCatchAll
+	bareCatchAll bool
+	bareValue    string
+
+	maxBody    int64
+	hasMaxBody bool
+	timeout    time.Duration
+	hasTimeout bool
+
+	compress bool
+
+	// Relations of the route, see Link
+	links []routeLink
+
+	// Conditions a request must satisfy to be served by the route, see
+	// RequireBody. Otherwise it is passed to the next route registered for
+	// the same method and path, if any.
+	guards []func(req *http.Request) bool
+	next   *Route
+
+	// Whether Router.RequireAccept is ignored for the route
+	anyAccept bool
+
+	// Whether the handle may skip requests, see ErrSkip
+	skippable bool
+
+	// Whether requests are never redirected to the route, see NoRedirect
+	noRedirect bool
+
+	// Whether HEAD requests are not served by the route, see NoAutoHEAD
+	noAutoHEAD bool
+
+	// Whether params of enclosing routers are hidden, see IsolateParams
+	isolateParams bool
+
+	// Whether the route is only served over HTTPS, see SecureOnly
+	secureOnly bool
+
+	// The handle decorated by Router.Decorator, created on first use
+	decoration *decoration
+
+	// The language of the responses of the route, see Language
+	language        string
+	defaultLanguage bool
+
+	// The rate limiter of the route, see RateLimit
+	limiter *rateLimiter
+}
+
+// routeLink is a relation of a route to a named route.
+type routeLink struct {
+	rel  string
+	name string
+}
+
+// Method returns the request method of the route.
+func (rt *Route) Method() string {
+	return rt.method
+}
+
+// Path returns the path of the route as it was registered, with placeholders
+// of the patterns of http.ServeMux converted, see Router.Handle.
+func (rt *Route) Path() string {
+	return rt.path
+}
+
+// Name returns the name of the route, if any.
+func (rt *Route) Name() string {
+	return rt.name
+}
+
+// Named sets the name of the route, by which it can be retrieved with
+// Router.NamedRoute. It panics if another route with the name is registered.
+func (rt *Route) Named(name string) *Route {
+	if other := rt.router.NamedRoute(name); other != nil && other != rt {
+		panic("a route named '" + name + "' is already registered")
+	}
+	rt.router.setName(rt, name)
+	return rt
+}
+
+// URL returns the path of the route with its params replaced by the values in
+// ps, e.g. /users/42 for the route /users/@id and the param id=42.
+// Values are escaped. The value of a named parameter must not contain a '/'.
+// An optional parameter without a value is left out. It returns an error if
+// the value of a parameter is missing or not valid.
+func (rt *Route) URL(ps Params) (string, error) {
+	path, suffix := rt.path, ""
+	if base, opt, ok := splitOptionalParam(path); ok {
+		wildcard, _, _ := parseOptionalParam(opt)
+		name := paramName(wildcard)
+		path = base
+		if value := ps.ByName(name); value != "" {
+			if check := rt.checks[name]; strings.IndexByte(value, '/') >= 0 || (check != nil && !check(value)) {
+				return "", fmt.Errorf("invalid value of param %s for route %s: %q", name, rt.path, value)
+			}
+			suffix = "/" + escapePath(value)
+		}
+	}
+
+	buf := make([]byte, 0, len(path)+len(suffix))
+	for {
+		wildcard, i, _ := findWildcard(path)
+		if i < 0 {
+			break
+		}
+		buf = append(buf, path[:i]...)
+		path = path[i+len(wildcard):]
+
+		name := paramName(wildcard)
+		value := ps.ByName(name)
+		if wildcard[0] == '*' {
+			// the value of a catch-all starts with the '/' before it
+			if len(value) > 0 && value[0] == '/' && len(buf) > 0 && buf[len(buf)-1] == '/' {
+				value = value[1:]
+			}
+			buf = append(buf, escapePath(value)...)
+			continue
+		}
+
+		if value == "" {
+			return "", fmt.Errorf("missing value of param %s for route %s", name, rt.path)
+		}
+		if check := rt.checks[name]; strings.IndexByte(value, '/') >= 0 || (check != nil && !check(value)) {
+			return "", fmt.Errorf("invalid value of param %s for route %s: %q", name, rt.path, value)
+		}
+		buf = append(buf, escapePath(value)...)
+	}
+	buf = append(buf, path...)
+	buf = append(buf, suffix...)
+	return string(buf), nil
+}
+
+// pathChecks returns the checks of the values of the params with an enum, a
+// regular expression or a type in the path by name, see Route.URL.
+// Types not built in are looked up in types, see Router.RegisterConstraint.
+func pathChecks(path string, types map[string]func(value string) bool) map[string]func(value string) bool {
+	if base, opt, ok := splitOptionalParam(path); ok {
+		wildcard, _, _ := parseOptionalParam(opt)
+		path = base + "/" + wildcard
+	}
+
+	var checks map[string]func(value string) bool
+	for rest := path; ; {
+		wildcard, i, _ := findWildcard(rest)
+		if i < 0 {
+			return checks
+		}
+		rest = rest[i+len(wildcard):]
+		if wildcard[0] != '@' {
+			continue
+		}
+
+		check := parseCheck(wildcard, path, types)
+		wildcard, enum := parseEnum(wildcard, path)
+		if check == nil && enum == nil {
+			continue
+		}
+		if checks == nil {
+			checks = make(map[string]func(value string) bool)
+		}
+		checks[wildcard[1:]] = func(value string) bool {
+			return (enum == nil || enum[value]) && (check == nil || check(value))
+		}
+	}
+}
+
+// escapePath escapes a path or a part of it for use in a URL.
+func escapePath(path string) string {
+	u := url.URL{Path: path}
+	return u.EscapedPath()
+}
+
+// Link adds a relation of the given type to the route with the given name to
+// the responses of this route. For each relation a Link header is added with
+// the URL of the named route, built with the params of the request:
+//  router.GET("/users/@id/posts", posts).Named("user-posts")
+//  router.GET("/users/@id", user).Link("posts", "user-posts")
+//
+//  GET /users/42  ->  Link: </users/42/posts>; rel="posts"
+// Relations to routes which are not registered or whose URL can't be built
+// with the params of the request are left out.
+func (rt *Route) Link(rel, name string) *Route {
+	rt.links = append(rt.links, routeLink{rel: rel, name: name})
+	return rt
+}
+
+// MiddlewareNames returns the names of the middleware wrapped around the handle
+// of the route, the outermost first: the middleware of the router, see
+// Router.Use, followed by the middleware of the route, see
+// RouteSpec.Middleware, With and Router.ApplyMiddleware. The name of a
+// middleware is the name it is registered under, see
+// Router.RegisterMiddleware, or the name of its function otherwise.
+func (rt *Route) MiddlewareNames() []string {
+	names := make([]string, 0, len(rt.router.chainNames)+len(rt.middlewareNames))
+	names = append(names, rt.router.chainNames...)
+	return append(names, rt.middlewareNames...)
+}
+
+// NoRedirect prevents the router from redirecting requests to this route, e.g.
+// requests to the path with a trailing slash by RedirectTrailingSlash. Such
+// requests are answered like unmatched requests instead.
+func (rt *Route) NoRedirect() *Route {
+	rt.noRedirect = true
+	return rt
+}
+
+// NoAutoHEAD prevents the router from serving HEAD requests by this GET route,
+// see Router.AutoHEAD. This is useful for routes streaming their response,
+// e.g. server-sent events, which would not end for HEAD requests.
+func (rt *Route) NoAutoHEAD() *Route {
+	rt.noAutoHEAD = true
+	return rt
+}
+
+// IsolateParams passes only the params matched by the route itself to its
+// handle. By default, routes of a host router also get the params of the host
+// pattern, see Router.Host, routes of a router mounted by Router.Mount get the
+// params of the enclosing route, and routes of a router mounted as the handler
+// of a route of another router see the params of the enclosing route in the
+// request context until they are replaced, e.g. by the params of a Handler
+// route. Isolated routes neither get nor see the params of host patterns or
+// enclosing routes, so that a param name can be reused for a different
+// purpose.
+func (rt *Route) IsolateParams() *Route {
+	rt.isolateParams = true
+	return rt
+}
+
+// SecureOnly makes the route only serve requests over HTTPS, e.g. for login
+// forms. Requests over plain HTTP are answered with 403 Forbidden, or
+// redirected to HTTPS if Router.SecureRedirect is enabled. Whether a request
+// was made over HTTPS is taken from the X-Forwarded-Proto header if
+// Router.TrustForwardedProto is enabled.
+func (rt *Route) SecureOnly() *Route {
+	rt.secureOnly = true
+	return rt
+}
+
+// AnyAccept exempts the route from Router.RequireAccept, e.g. for a route
+// serving files of any type.
+func (rt *Route) AnyAccept() *Route {
+	rt.anyAccept = true
+	return rt
+}
+
+// acceptsMediaType reports whether the Accept header of the request allows a
+// response of the given media type, e.g. "application/json". Requests without
+// an Accept header accept any media type.
+// The most specific matching element decides, so that e.g.
+// "application/json;q=0, */*" excludes application/json.
+func acceptsMediaType(req *http.Request, mediaType string) bool {
+	header := req.Header["Accept"]
+	if len(header) == 0 {
+		return true
+	}
+	typ := mediaType
+	if i := strings.IndexByte(mediaType, '/'); i >= 0 {
+		typ = mediaType[:i]
+	}
+
+	specificity, accepts := 0, false
+	for _, h := range header {
+		for _, accepted := range strings.Split(h, ",") {
+			accepted = strings.TrimSpace(accepted)
+			params := ""
+			if i := strings.IndexByte(accepted, ';'); i >= 0 {
+				accepted, params = strings.TrimSpace(accepted[:i]), accepted[i+1:]
+			}
+
+			var s int
+			switch {
+			case strings.EqualFold(accepted, mediaType):
+				s = 3
+			case strings.EqualFold(accepted, typ+"/*"):
+				s = 2
+			case accepted == "*/*":
+				s = 1
+			default:
+				continue
+			}
+			if s > specificity {
+				specificity, accepts = s, !zeroQuality(params)
+			} else if s == specificity && !zeroQuality(params) {
+				accepts = true
+			}
+		}
+	}
+	return accepts
+}
+
+// zeroQuality reports whether the parameters of an element of an Accept
+// header like "q=0; level=1" contain a quality of 0.
+func zeroQuality(params string) bool {
+	for _, param := range strings.Split(params, ";") {
+		param = strings.Replace(param, " ", "", -1)
+		if param == "q=0" || strings.HasPrefix(param, "q=0.") && strings.Trim(param[4:], "0") == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireBody makes the route only serve requests with a body. Other requests
+// are passed to the next route registered for the same method and path, or
+// answered like unmatched requests if there is none.
+// Multiple routes can be registered for the same method and path, as long as
+// all but the last one have such a condition, and are tried in the order of
+// registration:
+//  router.POST("/hooks", create).RequireBody()
+//  router.POST("/hooks", trigger)
+func (rt *Route) RequireBody() *Route {
+	return rt.Guard(hasBody)
+}
+
+// NoBody makes the route only serve requests without a body, see RequireBody.
+func (rt *Route) NoBody() *Route {
+	return rt.Guard(func(req *http.Request) bool {
+		return !hasBody(req)
+	})
+}
+
+// RequireHeader makes the route only serve requests with the given header
+// and value, or with the header at all if the value is empty. Other requests
+// are passed to the next route registered for the same method and path, see
+// RequireBody. Multiple required headers must all be present.
+func (rt *Route) RequireHeader(key, value string) *Route {
+	key = http.CanonicalHeaderKey(key)
+	return rt.Guard(func(req *http.Request) bool {
+		values, ok := req.Header[key]
+		if !ok || value == "" {
+			return ok
+		}
+		for _, v := range values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Guard makes the route only serve requests for which f returns true. Other
+// requests are passed to the next route registered for the same method and
+// path, see RequireBody. Multiple guards must all be satisfied.
+// Guarded routes can also share a catch-all pattern, e.g. to route the
+// requests of a gateway by the value of a header:
+//  router.Handler(http.MethodGet, "/*path", legacy).RequireHeader("X-Backend", "legacy")
+//  router.Handler(http.MethodGet, "/*path", canary).Guard(isCanary)
+//  router.Handler(http.MethodGet, "/*path", proxy)
+// Since a catch-all conflicts with all other routes below its prefix, such
+// routes only compete with each other. Requests rejected by the guards of all
+// of them are answered like unmatched requests.
+func (rt *Route) Guard(f func(req *http.Request) bool) *Route {
+	rt.guards = append(rt.guards, f)
+	return rt
+}
+
+// hasBody reports whether the request has a body. A body of unknown length is
+// assumed to be non-empty.
+func hasBody(req *http.Request) bool {
+	return req.Body != nil && req.ContentLength != 0
+}
+
+// matches reports whether the request satisfies the guards and the param
+// constraints of the route.
+func (rt *Route) matches(req *http.Request, ps Params) bool {
+	for _, guard := range rt.guards {
+		if !guard(req) {
+			return false
+		}
+	}
+	for name, valid := range rt.constraints {
+		if !valid(ps.ByName(name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Remove removes the route from its router.
+// Like registering routes, removing them is not concurrency-safe.
+func (rt *Route) Remove() {
+	r := rt.router
+	for i, other := range r.routes {
+		if other == rt {
+			copy(r.routes[i:], r.routes[i+1:])
+			r.routes[len(r.routes)-1] = nil
+			r.routes = r.routes[:len(r.routes)-1]
+			r.rebuild(rt.method)
+			if rt.name != "" {
+				r.indexNames()
+			}
+			return
+		}
+	}
+}
+
+// Replace replaces the handle of the route. The middleware of the route, if
+// any, is wrapped around the new handle.
+// Like registering routes, replacing handles is not concurrency-safe.
+func (rt *Route) Replace(handle Handle) *Route {
+	if handle == nil {
+		panic("handle must not be nil")
+	}
+	if len(rt.middleware) > 0 {
+		handle, _ = wrapMiddleware(handle, rt.middleware)
+	}
+	rt.handle = handle
+	rt.decoration = new(decoration)
+	return rt
+}
+
+// QueryParam promotes the query parameter with the given name to the params
+// of the route, so that its (first) value can be retrieved by Params.ByName
+// like the value of a path parameter. The query parameter is required:
+// requests without it are answered with the Router.MissingQueryParam handler.
+func (rt *Route) QueryParam(name string) *Route {
+	rt.queryParams = append(rt.queryParams, name)
+	rt.router.updateMaxParams(rt)
+	return rt
+}
+
+// Validate validates the values of the params of the route with the given
+// functions, keyed by the names of the params. Unlike constraints, which let
+// requests with invalid values fall through to other routes, see
+// RouteSpec.Constraints, a failed validation is answered with the
+// Router.ValidationErrorHandler. Params promoted by QueryParam are validated
+// as well, if QueryParam was called before.
+// It panics if a name is not the name of a param of the route.
+func (rt *Route) Validate(validators map[string]func(value string) error) *Route {
+	for name := range validators {
+		if !rt.hasParam(name) {
+			panic("validator of '" + name + "', which is not a param of the route " + rt.method + " " + rt.path)
+		}
+	}
+	if rt.validators == nil {
+		rt.validators = make(map[string]func(value string) error, len(validators))
+	}
+	for name, validate := range validators {
+		rt.validators[name] = validate
+	}
+	return rt
+}
+
+// hasParam reports whether the route has a param with the given name, in its
+// path or promoted by QueryParam.
+func (rt *Route) hasParam(name string) bool {
+	for _, query := range rt.queryParams {
+		if query == name {
+			return true
+		}
+	}
+
+	path := rt.path
+	if base, opt, ok := splitOptionalParam(path); ok {
+		wildcard, _, _ := parseOptionalParam(opt)
+		path = base + "/" + wildcard
+	}
+	for {
+		wildcard, i, _ := findWildcard(path)
+		if i < 0 {
+			return false
+		}
+		if paramName(wildcard) == name {
+			return true
+		}
+		path = path[i+len(wildcard):]
+	}
+}
+
+// MatchBarePrefix makes a route ending in a catch-all parameter also match the
+// path without the catch-all segment, with an empty value of the parameter,
+// like Router.MatchBareCatchAll does for all routes. For example /assets/*path
+// then matches:
+//  /assets                   path=""
+//  /assets/                  path="/"
+//  /assets/x/y               path="/x/y"
+// It panics if the route does not end in a catch-all parameter or if a
+// handle is already registered for the bare path.
+func (rt *Route) MatchBarePrefix() *Route {
+	return rt.MatchBarePrefixValue("")
+}
+
+// MatchBarePrefixValue is like MatchBarePrefix, but passes the given value as
+// the value of the catch-all parameter for the bare path, e.g. "/".
+func (rt *Route) MatchBarePrefixValue(value string) *Route {
+	if _, _, ok := splitCatchAll(rt.path); !ok {
+		panic("route '" + rt.path + "' does not end in a catch-all parameter")
+	}
+
+	oldBare, oldValue := rt.bareCatchAll, rt.bareValue
+	rt.bareCatchAll, rt.bareValue = true, value
+	defer func() {
+		if rcv := recover(); rcv != nil {
+			rt.bareCatchAll, rt.bareValue = oldBare, oldValue
+			rt.router.rebuild(rt.method)
+			panic(rcv)
+		}
+	}()
+	rt.router.rebuild(rt.method)
+	return rt
+}
+
+// MaxBody limits the size of request bodies of this route to n bytes,
+// overriding Router.DefaultMaxBody. A value <= 0 disables the limit.
+func (rt *Route) MaxBody(n int64) *Route {
+	rt.maxBody = n
+	rt.hasMaxBody = true
+	return rt
+}
+
+// Timeout cancels the request context of this route after d, overriding
+// Router.DefaultTimeout. A value <= 0 disables the timeout.
+func (rt *Route) Timeout(d time.Duration) *Route {
+	rt.timeout = d
+	rt.hasTimeout = true
+	return rt
+}
+
+// serve is the handle stored in the tree. It applies the route options before
+// calling the registered handle.
+func (rt *Route) serve(w http.ResponseWriter, req *http.Request, ps Params) {
+	if rt.isolateParams {
+		if ParamsFromContext(req.Context()) != nil {
+			req = NewRequestWithParams(req, nil)
+		}
+	} else if rt.router.nested {
+		ps = append(ps, outerParams(req.Context())...)
+	}
+
+	for {
+		if rt.language != "" {
+			w.Header().Add("Vary", "Accept-Language")
+			if rt = rt.negotiateLanguage(req, ps); rt == nil {
+				http.Error(w,
+					http.StatusText(http.StatusNotAcceptable),
+					http.StatusNotAcceptable,
+				)
+				return
+			}
+		}
+
+		for !rt.matches(req, ps) {
+			if rt.next == nil {
+				rt.router.handleNotFound(w, req)
+				return
+			}
+			rt = rt.next
+		}
+
+		if rt.router.globalChain != nil || rt.router.nested {
+			rt.setMatched(req)
+		}
+		if !rt.dispatch(w, req, ps) {
+			return
+		}
+
+		// The handle skipped the request, see ErrSkip
+		if rt.next == nil {
+			rt.router.handleNotFound(w, req)
+			return
+		}
+		rt = rt.next
+	}
+}
+
+// setMatched passes the route to the global middleware of the router serving
+// the request, see ContextMatchedRoute.
+func (rt *Route) setMatched(req *http.Request) {
+	if slot, ok := req.Context().Value(matchedRouteContextKey).(*routeSlot); ok {
+		slot.route = rt
+	}
+}
+
+// dispatch applies the options of the route and calls its handle. It reports
+// whether the handle skipped the request, see ErrSkip.
+func (rt *Route) dispatch(w http.ResponseWriter, req *http.Request, ps Params) bool {
+	if rt.secureOnly && rt.router.scheme(req) != "https" {
+		rt.router.insecureRequest(w, req)
+		return false
+	}
+
+	if accept := rt.router.RequireAccept; accept != "" && !rt.anyAccept && !acceptsMediaType(req, accept) {
+		http.Error(w,
+			http.StatusText(http.StatusNotAcceptable),
+			http.StatusNotAcceptable,
+		)
+		return false
+	}
+
+	if len(rt.queryParams) > 0 {
+		query := req.URL.Query()
+		for _, name := range rt.queryParams {
+			values, ok := query[name]
+			if !ok {
+				rt.router.handleMissingQueryParam(w, req, name)
+				return false
+			}
+			ps = append(ps, Param{Key: name, Value: values[0]})
+		}
+	}
+
+	if len(rt.validators) > 0 {
+		for _, p := range ps {
+			validate := rt.validators[p.Key]
+			if validate == nil {
+				continue
+			}
+			if err := validate(p.Value); err != nil {
+				rt.router.handleValidationError(w, req, p.Key, err)
+				return false
+			}
+		}
+	}
+
+	var clientKey string
+	if rt.router.ClientKeyFunc != nil || rt.limiter != nil {
+		clientKey = rt.router.clientKey(req)
+		req = req.WithContext(context.WithValue(req.Context(), clientKeyContextKey, clientKey))
+	}
+
+	if rt.limiter != nil {
+		if ok, retryAfter := rt.limiter.allow(clientKey); !ok {
+			tooManyRequests(w, retryAfter)
+			return false
+		}
+	}
+
+	maxBody := rt.router.DefaultMaxBody
+	if rt.hasMaxBody {
+		maxBody = rt.maxBody
+	}
+	if maxBody > 0 && req.Body != nil {
+		req.Body = http.MaxBytesReader(w, req.Body, maxBody)
+	}
+
+	timeout := rt.router.DefaultTimeout
+	if rt.hasTimeout {
+		timeout = rt.timeout
+	}
+	if timeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+
+	for _, link := range rt.links {
+		target := rt.router.NamedRoute(link.name)
+		if target == nil {
+			continue
+		}
+		if u, err := target.URL(ps); err == nil {
+			w.Header().Add("Link", "<"+u+`>; rel="`+link.rel+`"`)
+		}
+	}
+
+	if rt.compress {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if acceptsGzip(req) {
+			gw := &gzipResponseWriter{ResponseWriter: w, req: req}
+			defer gw.close()
+			w = gw
+		}
+	}
+
+	var skipped *bool
+	if rt.skippable {
+		skipped = new(bool)
+		req = req.WithContext(context.WithValue(req.Context(), skipContextKey, skipped))
+	}
+	rt.call(w, req, ps)
+	return skipped != nil && *skipped
+}
+
+// call calls the handle of the route, wrapped by the middleware of the router.
+func (rt *Route) call(w http.ResponseWriter, req *http.Request, ps Params) {
+	r := rt.router
+	if r.chain == nil && len(rt.middleware) == 0 {
+		rt.decorated()(w, req, ps)
+		return
+	}
+
+	ctx := context.WithValue(req.Context(), appliedMiddlewareContextKey, new([]string))
+	ctx = context.WithValue(ctx, routeContextKey, rt)
+	if r.chain == nil {
+		rt.decorated()(w, req.WithContext(ctx), ps)
+		return
+	}
+	if len(ps) > 0 {
+		ctx = WithParams(ctx, ps)
+	}
+	r.chain.ServeHTTP(w, req.WithContext(ctx))
+}
+
+// decoration memoizes the handle of a route decorated by Router.Decorator.
+type decoration struct {
+	once   sync.Once
+	handle Handle
+}
+
+// decorated returns the handle of the route, decorated by Router.Decorator if
+// set.
+func (rt *Route) decorated() Handle {
+	decorator := rt.router.Decorator
+	if decorator == nil {
+		return rt.handle
+	}
+
+	d := rt.decoration
+	d.once.Do(func() {
+		handle := rt.handle
+		h := decorator(rt.path, func(w http.ResponseWriter, req *http.Request) {
+			handle(w, req, ParamsFromContext(req.Context()))
+		})
+		d.handle = func(w http.ResponseWriter, req *http.Request, ps Params) {
+			if len(ps) > 0 {
+				req = NewRequestWithParams(req, ps)
+			}
+			h(w, req)
+		}
+	})
+	return d.handle
+}
@@ -77,17 +77,34 @@
 package httprouter
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"runtime/debug"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// MethodAny is the method of routes matching requests with any method, see
+// Router.Handle.
+const MethodAny = "*"
+
 // Handle is a function that can be registered to a route to handle HTTP
 // requests. Like http.HandlerFunc, but has a third parameter for the values of
 // wildcards (path variables).
 type Handle func(http.ResponseWriter, *http.Request, Params)
 
+// PatternHandle is like Handle, but has a fourth parameter for the pattern of
+// the matched route, e.g. "/user/@name".
+type PatternHandle func(http.ResponseWriter, *http.Request, Params, string)
+
 // Param is a single URL parameter, consisting of a key and a value.
 type Param struct {
 	Key   string
@@ -110,18 +127,6 @@ func (ps Params) ByName(name string) string {
 	return ""
 }
 
-type paramsKey struct{}
-
-// ParamsKey is the request context key under which URL params are stored.
-var ParamsKey = paramsKey{}
-
-// ParamsFromContext pulls the URL parameters from a request context,
-// or returns nil if none are present.
-func ParamsFromContext(ctx context.Context) Params {
-	p, _ := ctx.Value(ParamsKey).(Params)
-	return p
-}
-
 // MatchedRoutePathParam is the Param name under which the path of the matched
 // route is stored, if Router.SaveMatchedRoutePath is set.
 var MatchedRoutePathParam = "$matchedRoutePath"
@@ -133,20 +138,91 @@ func (ps Params) MatchedRoutePath() string {
 	return ps.ByName(MatchedRoutePathParam)
 }
 
+// CacheKey returns a deterministic key for the match of a request by the route
+// with the given pattern and the params, e.g. for use in an ETag or as the
+// cache key of a CDN:
+//  /users/@id?id=42
+// The key consists of the pattern and the params in their order, with
+// reserved characters escaped, so that different patterns or param values
+// always result in different keys. The MatchedRoutePathParam is left out.
+func (ps Params) CacheKey(pattern string) string {
+	var buf bytes.Buffer
+	buf.WriteString(escapePath(pattern))
+	sep := byte('?')
+	for _, p := range ps {
+		if p.Key == MatchedRoutePathParam {
+			continue
+		}
+		buf.WriteByte(sep)
+		buf.WriteString(url.QueryEscape(p.Key))
+		buf.WriteByte('=')
+		buf.WriteString(url.QueryEscape(p.Value))
+		sep = '&'
+	}
+	return buf.String()
+}
+
 // Router is a http.Handler which can be used to dispatch requests to different
 // handler functions via configurable routes
 type Router struct {
 	trees map[string]*node
 
+	// All registered routes in the order of registration
+	routes []*Route
+
 	paramsPool sync.Pool
 	maxParams  uint16
 
+	// Routers for requests to specific hosts, see Host
+	hosts []*hostRoute
+
+	// Groups of the router with a prefix by prefix, in the order of creation,
+	// which may have their own NotFound and MethodNotAllowed handlers, see
+	// Group
+	groups map[string][]*Group
+
+	// Whether the router is a host router or mounted, see Host and Mount.
+	// Its routes get the params of the enclosing router.
+	nested bool
+
+	// Middleware wrapped around the handles of all matched routes, see Use
+	middleware []func(http.Handler) http.Handler
+	chain      http.Handler
+	chainNames []string
+
+	// Middleware wrapped around the router, see UseGlobal
+	globalMiddleware []func(http.Handler) http.Handler
+	globalChain      http.Handler
+
+	// Middleware by name, see RegisterMiddleware
+	namedMiddleware map[string]func(http.Handler) http.Handler
+
+	// Named routes by name, see Route.Named
+	named map[string]*Route
+
+	// Param types by name, see RegisterConstraint
+	constraints map[string]func(value string) bool
+
 	// If enabled, adds the matched route path onto the http.Request context
 	// before invoking the handler.
 	// The matched route path is only added to handlers of routes that were
 	// registered when this option was enabled.
 	SaveMatchedRoutePath bool
 
+	// A named parameter always matches an empty path segment within the
+	// path, e.g. /a/@x/b matches /a//b with x="".
+	// If enabled, a named parameter also matches an empty last path segment,
+	// e.g. /c/@x matches /c/ with x="". Otherwise such requests are answered
+	// like any other unmatched request, e.g. by a trailing slash redirect.
+	AllowEmptySegments bool
+
+	// If enabled, routes ending in a catch-all parameter also match the path
+	// without the catch-all segment, with an empty value of the parameter.
+	// For example /api/*rest then also matches /api with rest="", instead of
+	// redirecting it to /api/.
+	// The option only applies to routes registered while it is enabled.
+	MatchBareCatchAll bool
+
 	// Enables automatic redirection if the current route can't be matched but a
 	// handler for the path with (without) the trailing slash exists.
 	// For example if /foo/ is requested but a route only exists for /foo, the
@@ -154,6 +230,17 @@ type Router struct {
 	// and 308 for all other request methods.
 	RedirectTrailingSlash bool
 
+	// The request methods for which RedirectTrailingSlash applies, e.g.
+	// []string{http.MethodGet, http.MethodHead} to not redirect requests with
+	// non-idempotent methods. If nil, it applies to all methods.
+	RedirectTrailingSlashMethods []string
+
+	// If enabled, requests with methods not listed in
+	// RedirectTrailingSlashMethods are served in-place by the handle of the
+	// path with (without) the trailing slash instead of being redirected.
+	// Otherwise they are answered like unmatched requests.
+	ServeTrailingSlashInPlace bool
+
 	// If enabled, the router tries to fix the current request path, if no
 	// handle is registered for it.
 	// First superfluous path elements like ../ or // are removed.
@@ -165,6 +252,192 @@ type Router struct {
 	// RedirectTrailingSlash is independent of this option.
 	RedirectFixedPath bool
 
+	// A narrower variant of RedirectFixedPath. If enabled, the router does a
+	// case-insensitive lookup of the request path, if no handle is registered
+	// for it, and redirects to the path in the casing of the registered route,
+	// e.g. /Blog/Go to /blog/go. Unlike RedirectFixedPath, the path is not
+	// cleaned. Missing or superfluous trailing slashes are only fixed if
+	// RedirectTrailingSlash is enabled.
+	// This option has no effect if RedirectFixedPath is enabled.
+	RedirectFixedCase bool
+
+	// The status codes of the redirects of RedirectTrailingSlash and of
+	// RedirectFixedPath, RedirectFixedCase and CanonicalRedirect. Zero means
+	// 301 Moved Permanently.
+	// Requests with methods other than GET are redirected with a code
+	// preserving the method instead of 301 (308) and 302 (307), e.g.
+	//  router.RedirectTrailingSlashCode = http.StatusPermanentRedirect
+	// redirects trailing slashes of all requests with 308, which unlike 301 is
+	// usually not cached by browsers without explicit caching headers.
+	RedirectTrailingSlashCode int
+	RedirectFixedPathCode     int
+
+	// If enabled, the case-insensitive lookups of RedirectFixedPath and
+	// RedirectFixedCase compare paths by Unicode case folding, which also
+	// matches case variants encoded with a different number of bytes,
+	// e.g. /STRAẞE to /straße. The default lookup is faster, but only matches
+	// case variants of the same length.
+	UnicodeCaseFold bool
+
+	// If enabled, redirects issued by the router carry a X-Redirect-Reason
+	// header with the option which caused the redirect, i.e.
+	// "trailing-slash" (RedirectTrailingSlash), "fixed-path"
+	// (RedirectFixedPath), "fixed-case" (RedirectFixedCase) or "canonical"
+	// (CanonicalRedirect).
+	// Meant for debugging.
+	DebugRedirects bool
+
+	// If enabled, the router redirects requests, if no handle is registered
+	// for the path, in one hop to the fully canonical path, i.e. the cleaned
+	// path in the casing of the registered route and with (without) the
+	// trailing slash. For example /FOO/ is redirected to /foo directly.
+	// It takes precedence over, and works independently of,
+	// RedirectTrailingSlash, RedirectFixedPath and RedirectFixedCase.
+	// Trailing slashes are only fixed for the RedirectTrailingSlashMethods.
+	CanonicalRedirect bool
+
+	// An optional function called for every redirect issued by the router with
+	// the method of the request, the path redirected to and the status code,
+	// e.g. to count redirects of non-GET requests, which some clients don't
+	// follow properly.
+	OnRedirect func(method, target string, code int)
+
+	// An optional function called if a request is not redirected to the path
+	// with (without) the trailing slash because its method is not listed in
+	// RedirectTrailingSlashMethods, with the method of the request and the
+	// path it would have been redirected to. The request is then served in
+	// place (ServeTrailingSlashInPlace) or answered like unmatched requests.
+	OnRedirectSuppressed func(method, target string)
+
+	// If enabled, the Location header of redirects issued by the router is an
+	// absolute URL with the scheme and host of the request, e.g.
+	// https://example.com/foo instead of /foo.
+	AbsoluteRedirects bool
+
+	// If enabled, the scheme of absolute redirects and of requests to routes
+	// only served over HTTPS is taken from the X-Forwarded-Proto header, if
+	// present. Only enable this behind a trusted proxy setting the header, see
+	// AbsoluteRedirects and Route.SecureOnly.
+	TrustForwardedProto bool
+
+	// If enabled, requests over plain HTTP to routes only served over HTTPS
+	// are redirected to the same URL with the https scheme instead of being
+	// answered with 403 Forbidden, see Route.SecureOnly.
+	SecureRedirect bool
+
+	// An optional function deciding whether the router may redirect a request
+	// to the given path, e.g. because of RedirectTrailingSlash. If it returns
+	// false, the request is not redirected and answered like an unmatched
+	// request instead, unless another redirect option applies.
+	// See also Route.NoRedirect.
+	RedirectPolicy func(req *http.Request, target string) bool
+
+	// If enabled, the router never redirects or serves requests by a path
+	// other than the request path, regardless of the redirect options.
+	// Instead, a request which would have been redirected to a path with (or
+	// without) a trailing slash or in another casing is answered with
+	// 404 Not Found and a JSON body suggesting the path of the route:
+	//  {"error":"not_found","suggestion":"/foo"}
+	// Other unmatched requests are answered as usual.
+	StrictNoRedirect bool
+
+	// If enabled, encoded slashes (%2F) in the request path don't separate
+	// path segments, so that a param value may contain slashes, e.g.
+	// /files/a%2Fb matches /files/@name with the param name "a/b".
+	// The path is matched with the encoded slashes, and the values of the
+	// params are decoded afterwards. Paths of static routes must not contain
+	// percent signs in this mode.
+	PreserveEncodedSlash bool
+
+	// If enabled, the paths of routes registered afterwards are read as
+	// patterns of gorilla/mux, with placeholders like {name} and
+	// {name:regexp}, instead of the native syntax, e.g.
+	// /articles/{id:[0-9]+} as /articles/@id([0-9]+).
+	// A {name:.*} spanning the last path segment is a catch-all parameter.
+	// Like the value of any catch-all parameter, its value starts with a '/',
+	// unlike with gorilla/mux, e.g. /static/{path:.*} matches /static/a/b with
+	// the param path "/a/b" instead of "a/b".
+	// The native wildcards '@' and '*' are not allowed outside of placeholders
+	// in this mode.
+	MuxPatterns bool
+
+	// If enabled, the paths of routes registered afterwards may contain the
+	// placeholders of the patterns of http.ServeMux, which are converted to
+	// the native syntax, see Handle. Otherwise segments like {id} are static.
+	ServeMuxPatterns bool
+
+	// An optional function decorating the handler of every route, e.g. to
+	// start a tracing span named after the pattern of the route, without
+	// wrapping every registration. It is called with the pattern and the
+	// handler of a route the first time the route serves a request, and the
+	// returned handler is used for all further requests. The decorated handler
+	// runs inside the middleware of the router and includes the middleware of
+	// the route, see RouteSpec. The params are in the request context.
+	// Since the result is memoized, the decorator must be set before requests
+	// are served.
+	Decorator func(pattern string, h http.HandlerFunc) http.HandlerFunc
+
+	// If enabled, unmatched requests are answered with up to three registered
+	// routes closest to the request path by edit distance, e.g. GET /users
+	// for /usres, in the X-Route-Suggestions header and, unless a NotFound or
+	// Fallback handler is set, in the body. Segments matched by params of a
+	// route don't count towards the distance.
+	// Meant for development, since all routes are compared for every
+	// unmatched request.
+	SuggestRoutes bool
+
+	// If enabled, the number of tree nodes visited to match the route of a
+	// request is stored in the request context, see ContextLookupDepth.
+	// Deep lookups point to route patterns which are expensive to match.
+	// Lookups are not measured if disabled.
+	MeasureLookupDepth bool
+
+	// If enabled, the router routes requests with an empty URL path by the
+	// path portion of the raw RequestURI instead.
+	// Some proxies rewrite requests in a way that leaves req.URL.Path empty
+	// while the RequestURI still carries the original path.
+	UseRequestURIFallback bool
+
+	// If set, requests to routes whose Accept header does not allow this media
+	// type, e.g. "application/json", are answered with 406 Not Acceptable
+	// before the handle of the route is called. Accept headers with */* or a
+	// matching wildcard like application/* and requests without an Accept
+	// header are allowed. Routes can opt out with Route.AnyAccept.
+	RequireAccept string
+
+	// The request header carrying the IP address of the client, e.g.
+	// "X-Real-IP" or "X-Forwarded-For". If it is not set or the header is
+	// missing, the address is taken from the RemoteAddr of the request.
+	// It must only be set if all requests reach the router through trusted
+	// proxies setting the header, since clients can send any value.
+	// Addresses are appended to a list like X-Forwarded-For by each proxy, so
+	// the client address is taken from the right, see TrustedProxies.
+	// Client IP addresses are used to identify clients, see ClientKeyFunc.
+	ClientIPHeader string
+
+	// The number of trusted proxies appending to the ClientIPHeader in front
+	// of the router. The client address is the one appended by the first of
+	// them, i.e. the TrustedProxies-th address from the right. Addresses
+	// further to the left were sent by the client and are ignored.
+	// If it is 0, a single proxy is assumed, i.e. the rightmost address is
+	// used.
+	TrustedProxies int
+
+	// An optional function returning the key identifying the client of a
+	// request for keyed features like Route.RateLimit, e.g. an API key or the
+	// address parsed from X-Forwarded-For behind a chain of trusted proxies.
+	// If not set, clients are identified by their IP address, see
+	// ClientIPHeader.
+	// If set, the key is available to the handles of all routes, else only to
+	// the handles of rate limited routes, see ContextClientKey.
+	ClientKeyFunc func(req *http.Request) string
+
+	// If enabled, HEAD requests to paths without a matching HEAD route are
+	// served by the matching GET route, if any. The body of the response is
+	// discarded by the server. Routes can opt out with Route.NoAutoHEAD, e.g.
+	// for streaming responses.
+	AutoHEAD bool
+
 	// If enabled, the router checks if another method is allowed for the
 	// current route, if the current request can not be routed.
 	// If this is the case, the request is answered with 'Method Not Allowed'
@@ -175,6 +448,9 @@ type Router struct {
 
 	// If enabled, the router automatically replies to OPTIONS requests.
 	// Custom OPTIONS handlers take priority over automatic replies.
+	// If disabled, OPTIONS requests are treated like requests with any other
+	// method, i.e. they are answered with 405 if HandleMethodNotAllowed is
+	// enabled and the path is registered for other methods.
 	HandleOPTIONS bool
 
 	// An optional http.Handler that is called on automatic OPTIONS requests.
@@ -183,6 +459,34 @@ type Router struct {
 	// The "Allowed" header is set before calling the handler.
 	GlobalOPTIONS http.Handler
 
+	// An optional function deciding which registered methods are listed in the
+	// Allow header of automatic OPTIONS replies and 405 responses, e.g. to not
+	// advertise internal methods like PURGE. Requests with methods filtered
+	// out are still served as usual.
+	OptionsMethodFilter func(method string) bool
+
+	// If enabled, the router automatically replies to TRACE requests to paths
+	// registered for any method, if no TRACE handle is registered for the
+	// path. The reply reflects the request line and header as a message/http
+	// body, except for credentials like the Authorization and Cookie headers.
+	// Since TRACE can be abused to read headers otherwise hidden from scripts,
+	// it is disabled by default and can be disabled for paths below a prefix
+	// by DisableAutoTRACE.
+	HandleTRACE bool
+
+	// Handlers for methods without any registered routes,
+	// see EmptyMethodHandler
+	emptyMethodHandlers map[string]http.Handler
+
+	// Path prefixes without automatic OPTIONS replies, see DisableAutoOptions
+	noAutoOptions []string
+
+	// Path prefixes without automatic TRACE replies, see DisableAutoTRACE
+	noAutoTRACE []string
+
+	// Canonical path segments by alias, see PathAliases
+	pathAliases map[string]string
+
 	// Cached value of global (*) allowed methods
 	globalAllowed string
 
@@ -190,6 +494,13 @@ type Router struct {
 	// found. If it is not set, http.NotFound is used.
 	NotFound http.Handler
 
+	// An optional http.Handler to which requests are forwarded if no matching
+	// route is found, e.g. a legacy router or mux during a migration.
+	// Unlike NotFound, it is meant to serve the request instead of an error
+	// page. If both are set, Fallback takes precedence.
+	// Requests answered with 405 Method Not Allowed are not forwarded.
+	Fallback http.Handler
+
 	// Configurable http.Handler which is called when a request
 	// cannot be routed and HandleMethodNotAllowed is true.
 	// If it is not set, http.Error with http.StatusMethodNotAllowed is used.
@@ -197,12 +508,83 @@ type Router struct {
 	// is called.
 	MethodNotAllowed http.Handler
 
+	// If set, requests with headers exceeding the given number of bytes,
+	// counted as the total length of all header keys and values, are answered
+	// with 431 Request Header Fields Too Large before routing.
+	// The limit of the server, see http.Server.MaxHeaderBytes, still applies.
+	MaxHeaderBytes int
+
+	// If set, limits the size of request bodies to the given number of bytes
+	// for all routes without a per-route limit, see Route.MaxBody.
+	// Reading beyond the limit fails, see http.MaxBytesReader.
+	DefaultMaxBody int64
+
+	// If set, the request context of all routes without a per-route timeout
+	// is canceled after the given duration, see Route.Timeout.
+	DefaultTimeout time.Duration
+
+	// Configurable http.Handler which is called when a request lacks a
+	// required query parameter, see Route.QueryParam.
+	// If it is not set, http.Error with http.StatusBadRequest is used.
+	MissingQueryParam http.Handler
+
+	// Configurable function which is called when the value of a param fails
+	// validation, see Route.Validate, with the name of the param and the
+	// error of the validation.
+	// If it is not set, http.Error with http.StatusBadRequest is used.
+	ValidationErrorHandler func(w http.ResponseWriter, req *http.Request, name string, err error)
+
+	// If set, the name of a request header with a timeout chosen by the
+	// client, e.g. "X-Request-Timeout". If the header holds a valid duration,
+	// like "5s", the request context is canceled after this duration.
+	// Invalid values are ignored. Timeouts of the route still apply, the
+	// earlier deadline wins.
+	HeaderTimeout string
+
+	// Configurable http.Handler which is called for all requests while the
+	// router is loading, see SetLoading. If it is not set, http.Error with
+	// http.StatusServiceUnavailable is used.
+	LoadingHandler http.Handler
+
+	// Whether the router is loading, 1 if it is, see SetLoading
+	loading int32
+
 	// Function to handle panics recovered from http handlers.
 	// It should be used to generate a error page and return the http error code
 	// 500 (Internal Server Error).
 	// The handler can be used to keep your server from crashing because of
 	// unrecovered panics.
 	PanicHandler func(http.ResponseWriter, *http.Request, interface{})
+
+	// If enabled, the stack trace of a recovered panic is captured and
+	// available to the PanicHandler in the request context, see
+	// ContextPanicStack.
+	CapturePanicStack bool
+}
+
+// SetLoading switches whether all requests are answered by the
+// LoadingHandler instead of being routed, e.g. while the services the routes
+// depend on start up. It is safe to call while requests are served:
+//  router.SetLoading(true)
+//  registerRoutes(router)
+//  go func() {
+//      connectDatabase()
+//      router.SetLoading(false)
+//  }()
+//  http.ListenAndServe(":8080", router)
+// Registering routes is still not concurrency-safe, so the routes must be
+// registered before requests are served, even while the router is loading.
+func (r *Router) SetLoading(loading bool) {
+	var state int32
+	if loading {
+		state = 1
+	}
+	atomic.StoreInt32(&r.loading, state)
+}
+
+// isLoading reports whether the router is loading, see SetLoading.
+func (r *Router) isLoading() bool {
+	return atomic.LoadInt32(&r.loading) == 1
 }
 
 // Make sure the Router conforms with the http.Handler interface
@@ -210,13 +592,19 @@ var _ http.Handler = New()
 
 // New returns a new initialized Router.
 // Path auto-correction, including trailing slashes, is enabled by default.
-func New() *Router {
-	return &Router{
+// The defaults can be changed by the given options, e.g.:
+//  router := httprouter.New(httprouter.WithRedirectFixedPath(false))
+func New(opts ...Option) *Router {
+	r := &Router{
 		RedirectTrailingSlash:  true,
 		RedirectFixedPath:      true,
 		HandleMethodNotAllowed: true,
 		HandleOPTIONS:          true,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
 func (r *Router) getParams() *Params {
@@ -231,54 +619,79 @@ func (r *Router) putParams(ps *Params) {
 	}
 }
 
+// handle calls the handle with the matched params and puts them back to the
+// pool afterwards.
+func (r *Router) handle(w http.ResponseWriter, req *http.Request, handle Handle, ps *Params) {
+	if ps == nil {
+		handle(w, req, nil)
+		return
+	}
+	if r.PreserveEncodedSlash {
+		unescapeSlashes(*ps)
+	}
+	handle(w, req, *ps)
+	r.putParams(ps)
+}
+
 func (r *Router) saveMatchedRoutePath(path string, handle Handle) Handle {
+	return r.withParam(MatchedRoutePathParam, path, handle)
+}
+
+// withParam returns a handle which appends a fixed Param to the matched
+// params before calling the given handle.
+func (r *Router) withParam(key, value string, handle Handle) Handle {
 	return func(w http.ResponseWriter, req *http.Request, ps Params) {
 		if ps == nil {
 			psp := r.getParams()
 			ps = (*psp)[0:1]
-			ps[0] = Param{Key: MatchedRoutePathParam, Value: path}
+			ps[0] = Param{Key: key, Value: value}
 			handle(w, req, ps)
 			r.putParams(psp)
 		} else {
-			ps = append(ps, Param{Key: MatchedRoutePathParam, Value: path})
+			ps = append(ps, Param{Key: key, Value: value})
 			handle(w, req, ps)
 		}
 	}
 }
 
 // GET is a shortcut for router.Handle(http.MethodGet, path, handle)
-func (r *Router) GET(path string, handle Handle) {
-	r.Handle(http.MethodGet, path, handle)
+func (r *Router) GET(path string, handle Handle) *Route {
+	return r.Handle(http.MethodGet, path, handle)
+}
+
+// Any is a shortcut for router.Handle(MethodAny, path, handle)
+func (r *Router) Any(path string, handle Handle) *Route {
+	return r.Handle(MethodAny, path, handle)
 }
 
 // HEAD is a shortcut for router.Handle(http.MethodHead, path, handle)
-func (r *Router) HEAD(path string, handle Handle) {
-	r.Handle(http.MethodHead, path, handle)
+func (r *Router) HEAD(path string, handle Handle) *Route {
+	return r.Handle(http.MethodHead, path, handle)
 }
 
 // OPTIONS is a shortcut for router.Handle(http.MethodOptions, path, handle)
-func (r *Router) OPTIONS(path string, handle Handle) {
-	r.Handle(http.MethodOptions, path, handle)
+func (r *Router) OPTIONS(path string, handle Handle) *Route {
+	return r.Handle(http.MethodOptions, path, handle)
 }
 
 // POST is a shortcut for router.Handle(http.MethodPost, path, handle)
-func (r *Router) POST(path string, handle Handle) {
-	r.Handle(http.MethodPost, path, handle)
+func (r *Router) POST(path string, handle Handle) *Route {
+	return r.Handle(http.MethodPost, path, handle)
 }
 
 // PUT is a shortcut for router.Handle(http.MethodPut, path, handle)
-func (r *Router) PUT(path string, handle Handle) {
-	r.Handle(http.MethodPut, path, handle)
+func (r *Router) PUT(path string, handle Handle) *Route {
+	return r.Handle(http.MethodPut, path, handle)
 }
 
 // PATCH is a shortcut for router.Handle(http.MethodPatch, path, handle)
-func (r *Router) PATCH(path string, handle Handle) {
-	r.Handle(http.MethodPatch, path, handle)
+func (r *Router) PATCH(path string, handle Handle) *Route {
+	return r.Handle(http.MethodPatch, path, handle)
 }
 
 // DELETE is a shortcut for router.Handle(http.MethodDelete, path, handle)
-func (r *Router) DELETE(path string, handle Handle) {
-	r.Handle(http.MethodDelete, path, handle)
+func (r *Router) DELETE(path string, handle Handle) *Route {
+	return r.Handle(http.MethodDelete, path, handle)
 }
 
 // Handle registers a new request handle with the given path and method.
@@ -289,9 +702,29 @@ func (r *Router) DELETE(path string, handle Handle) {
 // This function is intended for bulk loading and to allow the usage of less
 // frequently used, non-standardized or custom methods (e.g. for internal
 // communication with a proxy).
-func (r *Router) Handle(method, path string, handle Handle) {
-	varsCount := uint16(0)
-
+//
+// The last path segment may be an optional named parameter, written as
+// @name? or, with a default value, as @name?=value. The route then also
+// matches the path without this segment. If the segment is absent, the
+// default value (if any) is passed as the value of the parameter:
+//  Path: /items/@page?=1
+//
+//  Requests:
+//   /items/3                            match: page="3"
+//   /items                              match: page="1"
+//
+// If ServeMuxPatterns is enabled, the placeholders of the patterns of
+// http.ServeMux can be used as well, so that paths can be shared with it.
+// They are converted to the native syntax:
+//  Path: /files/{id}/{path...}             same as /files/@id/*path
+//  Path: /users/{$}                        same as /users/
+// Like the value of any catch-all parameter, the value of {name...} starts
+// with a '/', unlike with http.ServeMux.
+//
+// Routes registered with the method MethodAny match requests with any method.
+// A route registered with the method of the request takes precedence over a
+// MethodAny route for the same path, regardless of the order of registration.
+func (r *Router) Handle(method, path string, handle Handle) *Route {
 	if method == "" {
 		panic("method must not be empty")
 	}
@@ -301,12 +734,268 @@ func (r *Router) Handle(method, path string, handle Handle) {
 	if handle == nil {
 		panic("handle must not be nil")
 	}
+	if r.MuxPatterns {
+		path = muxPath(path)
+	} else {
+		if hasMuxPlaceholder(path) {
+			panic("gorilla/mux placeholder in path '" + path + "' requires MuxPatterns")
+		}
+		if r.ServeMuxPatterns {
+			path = serveMuxPath(path)
+		}
+	}
+
+	rt := &Route{
+		router:          r,
+		method:          method,
+		path:            path,
+		handle:          handle,
+		saveMatchedPath: r.SaveMatchedRoutePath,
+		bareCatchAll:    r.MatchBareCatchAll,
+		decoration:      new(decoration),
+	}
+	r.insert(rt)
+	rt.checks = pathChecks(path, r.constraints)
+	r.routes = append(r.routes, rt)
+	r.updateMaxParams(rt)
+
+	return rt
+}
 
-	if r.SaveMatchedRoutePath {
+// updateMaxParams makes sure the params pool can hold the params of the given
+// route.
+func (r *Router) updateMaxParams(rt *Route) {
+	varsCount := uint16(len(rt.queryParams))
+	if rt.saveMatchedPath {
 		varsCount++
+	}
+	if paramsCount := countParams(rt.path); paramsCount+varsCount > r.maxParams {
+		r.maxParams = paramsCount + varsCount
+	}
+
+	// Lazy-init paramsPool alloc func
+	if r.paramsPool.New == nil && r.maxParams > 0 {
+		r.paramsPool.New = func() interface{} {
+			ps := make(Params, 0, r.maxParams)
+			return &ps
+		}
+	}
+}
+
+// insert adds the given route to the tree of its method.
+func (r *Router) insert(rt *Route) {
+	// A route for the same method and path as routes with guards or a
+	// language is tried after them, see Route.RequireBody and Route.Language
+	rt.next = nil
+	leaves := r.leaves(rt)
+	if prev := r.sameRoute(rt, leaves[0].path); prev != nil {
+		for len(prev.guards) > 0 || prev.skippable || prev.language != "" {
+			if prev.next == nil {
+				prev.next = rt
+				return
+			}
+			prev = prev.next
+		}
+	}
+
+	for _, l := range leaves {
+		r.addRoute(rt.method, l.path, l.handle)
+		r.trees[rt.method].patternNode(l.path).route = rt
+	}
+}
+
+// leaf is a path in a tree with its handle.
+type leaf struct {
+	path   string
+	handle Handle
+}
+
+// leaves returns the paths and handles to add to the tree of the method of
+// the route.
+func (r *Router) leaves(rt *Route) []leaf {
+	path, handle := rt.path, Handle(rt.serve)
+	if rt.saveMatchedPath {
 		handle = r.saveMatchedRoutePath(path, handle)
 	}
 
+	if base, opt, ok := splitOptionalParam(path); ok {
+		// Register the path with and without the optional segment
+		wildcard, value, hasDefault := parseOptionalParam(opt)
+
+		bareHandle := handle
+		if hasDefault {
+			bareHandle = r.withParam(paramName(wildcard), value, handle)
+		}
+		if base == "" {
+			return []leaf{{"/" + wildcard, handle}, {"/", bareHandle}}
+		}
+		return []leaf{{base + "/" + wildcard, handle}, {base, bareHandle}}
+	} else if base, name, ok := splitCatchAll(path); ok && rt.bareCatchAll {
+		// Register the path with and without the catch-all segment
+		return []leaf{{path, handle}, {base, r.withParam(name, rt.bareValue, handle)}}
+	}
+	return []leaf{{path, handle}}
+}
+
+// Merge adds all routes of other to the router. The configuration of other,
+// e.g. its NotFound handler, is ignored.
+// If a route of other conflicts with a route of the router, an error naming
+// the method and path of the conflicting route is returned and the router is
+// left unchanged.
+func (r *Router) Merge(other *Router) error {
+	n := len(r.routes)
+	touched := make(map[string]bool)
+	for _, ort := range other.routes {
+		rt := new(Route)
+		*rt = *ort
+		rt.router = r
+		rt.decoration = new(decoration)
+		if ort.limiter != nil {
+			rt.limiter = ort.limiter.clone()
+		}
+
+		touched[rt.method] = true
+		if err := r.tryInsert(rt); err != nil {
+			for i := n; i < len(r.routes); i++ {
+				r.routes[i] = nil
+			}
+			r.routes = r.routes[:n]
+			for method := range touched {
+				r.rebuild(method)
+			}
+			return err
+		}
+		r.routes = append(r.routes, rt)
+	}
+
+	for _, rt := range r.routes[n:] {
+		r.updateMaxParams(rt)
+	}
+	r.indexNames()
+	r.globalAllowed = r.allowed("*", "")
+	return nil
+}
+
+// sameRoute returns the first route registered before rt with the same method
+// and path, if any. It is the route of the node of the first leaf of rt in
+// the tree, see leaves.
+func (r *Router) sameRoute(rt *Route, leafPath string) *Route {
+	root := r.trees[rt.method]
+	if root == nil {
+		return nil
+	}
+	n := root.patternNode(leafPath)
+	if n == nil || n.route == nil || n.route == rt || n.route.path != rt.path {
+		return nil
+	}
+	return n.route
+}
+
+// tryInsert is like insert, but returns an error instead of panicking if the
+// route conflicts with an existing one.
+func (r *Router) tryInsert(rt *Route) (err error) {
+	defer func() {
+		if rcv := recover(); rcv != nil {
+			err = fmt.Errorf("conflicting route %s %s: %v", rt.method, rt.path, rcv)
+		}
+	}()
+	r.insert(rt)
+	return nil
+}
+
+// rebuild recreates the tree of the given method from the registered routes.
+func (r *Router) rebuild(method string) {
+	delete(r.trees, method)
+	for _, rt := range r.routes {
+		if rt.method == method {
+			r.insert(rt)
+		}
+	}
+	r.globalAllowed = r.allowed("*", "")
+}
+
+// RouterState is a snapshot of the routes of a router, see Router.Save.
+type RouterState struct {
+	routes []*Route
+	values []Route
+}
+
+// Save returns a snapshot of the registered routes, including their options,
+// which can be restored by Restore, e.g. to undo the registrations of a test.
+// The configuration of the router is not part of the snapshot.
+func (r *Router) Save() RouterState {
+	state := RouterState{
+		routes: make([]*Route, len(r.routes)),
+		values: make([]Route, len(r.routes)),
+	}
+	copy(state.routes, r.routes)
+	for i, rt := range r.routes {
+		state.values[i] = *rt
+	}
+	return state
+}
+
+// Restore restores the routes of the router to the given snapshot, which must
+// have been taken by Save of the same router. Routes registered since then
+// are removed, removed routes are registered again and changed options of
+// routes are reset.
+// Like registering routes, restoring them is not concurrency-safe.
+func (r *Router) Restore(state RouterState) {
+	r.routes = make([]*Route, len(state.routes))
+	copy(r.routes, state.routes)
+	for i, rt := range r.routes {
+		*rt = state.values[i]
+	}
+
+	r.trees = nil
+	for _, rt := range r.routes {
+		r.insert(rt)
+	}
+	r.indexNames()
+	r.globalAllowed = r.allowed("*", "")
+}
+
+// RemovePrefix removes all routes of the given method whose path starts with
+// the given prefix and returns the number of removed routes.
+// The prefix only matches whole path segments, e.g. the prefix /plugins/foo
+// removes /plugins/foo and /plugins/foo/@id, but not /plugins/foobar.
+// The empty prefix matches all paths.
+// Like registering routes, removing them is not concurrency-safe.
+func (r *Router) RemovePrefix(method, prefix string) int {
+	kept := r.routes[:0]
+	removed := 0
+	for _, rt := range r.routes {
+		if rt.method == method && hasPathPrefix(rt.path, prefix) {
+			removed++
+			continue
+		}
+		kept = append(kept, rt)
+	}
+	for i := len(kept); i < len(r.routes); i++ {
+		r.routes[i] = nil // allow the removed routes to be garbage collected
+	}
+	r.routes = kept
+
+	if removed > 0 {
+		r.rebuild(method)
+		r.indexNames()
+	}
+	return removed
+}
+
+// hasPathPrefix reports whether path starts with prefix, where prefix must
+// end at a segment boundary of path. The empty prefix matches every path.
+func hasPathPrefix(path, prefix string) bool {
+	if prefix == "" {
+		return true
+	}
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || prefix[len(prefix)-1] == '/' || path[len(prefix)] == '/'
+}
+
+func (r *Router) addRoute(method, path string, handle Handle) {
 	if r.trees == nil {
 		r.trees = make(map[string]*node)
 	}
@@ -319,42 +1008,317 @@ func (r *Router) Handle(method, path string, handle Handle) {
 		r.globalAllowed = r.allowed("*", "")
 	}
 
-	root.addRoute(path, handle)
+	root.addTypedRoute(path, handle, r.constraints)
+}
 
-	// Update maxParams
-	if paramsCount := countParams(path); paramsCount+varsCount > r.maxParams {
-		r.maxParams = paramsCount + varsCount
+// splitOptionalParam splits a path ending with an optional parameter segment
+// into the path before that segment and the segment itself, e.g.
+// "/items/@page?=1" into "/items" and "@page?=1".
+// The '?' must directly follow a named parameter spanning the whole segment.
+func splitOptionalParam(path string) (base, opt string, ok bool) {
+	masked := maskConstraints(path)
+	q := strings.IndexByte(masked, '?')
+	if q < 0 {
+		return "", "", false
 	}
 
-	// Lazy-init paramsPool alloc func
-	if r.paramsPool.New == nil && r.maxParams > 0 {
-		r.paramsPool.New = func() interface{} {
-			ps := make(Params, 0, r.maxParams)
-			return &ps
+	i := strings.LastIndexByte(masked[:q], '/')
+	if masked[i+1] != '@' || q == i+2 || strings.ContainsAny(masked[i+2:q], "@*:.") {
+		panic("'?' must directly follow a named parameter like @name? in path '" + path + "'")
+	}
+	if strings.IndexByte(masked[q:], '/') >= 0 {
+		panic("optional parameters are only allowed as the last path segment in path '" + path + "'")
+	}
+	return path[:i], path[i+1:], true
+}
+
+// splitCatchAll splits a path ending in a catch-all parameter into the path
+// before the catch-all segment and the name of the catch-all parameter.
+// The path of a catch-all at the root is not split.
+func splitCatchAll(path string) (base, name string, ok bool) {
+	masked := maskConstraints(path)
+	i := strings.LastIndexByte(masked, '*')
+	if i < 2 || strings.IndexByte(masked[i:], '/') >= 0 {
+		return "", "", false
+	}
+	return path[:i-1], path[i+1:], true
+}
+
+// parseOptionalParam parses an optional parameter segment like @name? or
+// @name?=value into the wildcard, e.g. @name, and the default value.
+func parseOptionalParam(opt string) (wildcard, value string, hasDefault bool) {
+	q := strings.IndexByte(maskConstraints(opt), '?')
+	wildcard = opt[:q]
+	switch rest := opt[q+1:]; {
+	case rest == "":
+	case rest[0] == '=':
+		value, hasDefault = rest[1:], true
+	default:
+		panic("invalid optional parameter '" + opt + "', expected @name? or @name?=value")
+	}
+	return
+}
+
+// ErrSkip can be returned by an ErrorHandle to skip the request, i.e. to make
+// the router handle the request as if the route did not match. The request is
+// passed to the next route registered for the same method and path, if any,
+// or answered with the NotFound handler otherwise.
+// The handle must not write to the response before returning ErrSkip. The
+// middleware of the router runs again for the next route.
+var ErrSkip = errors.New("httprouter: skip route")
+
+// isSkip reports whether err is ErrSkip or wraps it, i.e. whether ErrSkip is
+// found in the chain of errors returned by their Unwrap methods.
+func isSkip(err error) bool {
+	for err != nil {
+		if err == ErrSkip {
+			return true
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return false
 		}
+		err = u.Unwrap()
 	}
+	return false
 }
 
-// Handler is an adapter which allows the usage of an http.Handler as a
-// request handle.
-// The Params are available in the request context under ParamsKey.
-func (r *Router) Handler(method, path string, handler http.Handler) {
-	r.Handle(method, path,
-		func(w http.ResponseWriter, req *http.Request, p Params) {
-			if len(p) > 0 {
-				ctx := req.Context()
-				ctx = context.WithValue(ctx, ParamsKey, p)
-				req = req.WithContext(ctx)
+// ErrorHandle is like Handle, but returns an error. Returning ErrSkip, or an
+// error wrapping it, skips the request, other errors are answered with 500
+// Internal Server Error.
+type ErrorHandle func(http.ResponseWriter, *http.Request, Params) error
+
+// HandleError registers a new request handle returning an error with the
+// given path and method, see ErrorHandle and ErrSkip. Routes registered for
+// the same method and path afterwards are tried if the handle skips a request:
+//  router.HandleError(http.MethodGet, "/api/*rest", proxy)
+//  router.GET("/api/*rest", local)
+func (r *Router) HandleError(method, path string, handle ErrorHandle) *Route {
+	if handle == nil {
+		panic("handle must not be nil")
+	}
+	rt := r.Handle(method, path, func(w http.ResponseWriter, req *http.Request, ps Params) {
+		err := handle(w, req, ps)
+		if err == nil {
+			return
+		}
+		if isSkip(err) {
+			if skipped, _ := req.Context().Value(skipContextKey).(*bool); skipped != nil {
+				*skipped = true
+				return
 			}
-			handler.ServeHTTP(w, req)
+			r.handleNotFound(w, req)
+			return
+		}
+		http.Error(w,
+			http.StatusText(http.StatusInternalServerError),
+			http.StatusInternalServerError,
+		)
+	})
+	rt.skippable = true
+	return rt
+}
+
+// GETPattern is a shortcut for router.HandlePattern(http.MethodGet, path, handle)
+func (r *Router) GETPattern(path string, handle PatternHandle) *Route {
+	return r.HandlePattern(http.MethodGet, path, handle)
+}
+
+// HandlePattern is an adapter which allows the usage of a PatternHandle as a
+// request handle. The registered path is passed to the handle as pattern.
+func (r *Router) HandlePattern(method, path string, handle PatternHandle) *Route {
+	if handle == nil {
+		panic("handle must not be nil")
+	}
+	return r.Handle(method, path,
+		func(w http.ResponseWriter, req *http.Request, ps Params) {
+			handle(w, req, ps, path)
 		},
 	)
 }
 
+// Handler is an adapter which allows the usage of an http.Handler as a
+// request handle.
+// The Params are available in the request context under ParamsKey.
+func (r *Router) Handler(method, path string, handler http.Handler) *Route {
+	return r.Handle(method, path, handlerHandle(handler))
+}
+
+// handlerHandle adapts a http.Handler to a Handle, which passes the params in
+// the request context.
+func handlerHandle(handler http.Handler) Handle {
+	return func(w http.ResponseWriter, req *http.Request, p Params) {
+		if len(p) > 0 {
+			ctx := req.Context()
+			ctx = WithParams(ctx, p)
+			req = req.WithContext(ctx)
+		}
+		handler.ServeHTTP(w, req)
+	}
+}
+
 // HandlerFunc is an adapter which allows the usage of an http.HandlerFunc as a
 // request handle.
-func (r *Router) HandlerFunc(method, path string, handler http.HandlerFunc) {
-	r.Handler(method, path, handler)
+func (r *Router) HandlerFunc(method, path string, handler http.HandlerFunc) *Route {
+	return r.Handler(method, path, handler)
+}
+
+// DisableAutoOptions disables automatic replies to OPTIONS requests for all
+// paths below the given prefix, e.g. /internal for /internal and
+// /internal/status. Such OPTIONS requests without a registered OPTIONS handle
+// are answered like unmatched requests, i.e. by the NotFound handler.
+// The prefix only matches whole path segments, like in RemovePrefix.
+func (r *Router) DisableAutoOptions(prefix string) {
+	r.noAutoOptions = append(r.noAutoOptions, prefix)
+}
+
+// autoOptionsDisabled reports whether automatic OPTIONS replies are disabled
+// for the given path, see DisableAutoOptions.
+func (r *Router) autoOptionsDisabled(path string) bool {
+	for _, prefix := range r.noAutoOptions {
+		if hasPathPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// PathAliases sets a table of alternative spellings of path segments, e.g.
+//     router.PathAliases(map[string]string{"colour": "color"})
+// Before a request is routed, every segment of its path which is a key of the
+// table is replaced by its value, so that /colour/red is routed like
+// /color/red. This also applies to segments matched by params. The request
+// itself is not changed.
+// The table replaces the table set by earlier calls.
+func (r *Router) PathAliases(aliases map[string]string) {
+	r.pathAliases = make(map[string]string, len(aliases))
+	for alias, canonical := range aliases {
+		r.pathAliases[alias] = canonical
+	}
+}
+
+// aliasPath returns the path with every segment which is an alias replaced by
+// its canonical spelling, see PathAliases.
+func (r *Router) aliasPath(path string) string {
+	segments := strings.Split(path, "/")
+	aliased := false
+	for i, segment := range segments {
+		if canonical, ok := r.pathAliases[segment]; ok {
+			segments[i] = canonical
+			aliased = true
+		}
+	}
+	if !aliased {
+		return path
+	}
+	return strings.Join(segments, "/")
+}
+
+// DisableAutoTRACE disables automatic replies to TRACE requests for all paths
+// below the given prefix, see HandleTRACE. Such TRACE requests without a
+// registered TRACE handle are answered like requests with any other method.
+// The prefix only matches whole path segments, like in RemovePrefix.
+func (r *Router) DisableAutoTRACE(prefix string) {
+	r.noAutoTRACE = append(r.noAutoTRACE, prefix)
+}
+
+// autoTRACEDisabled reports whether automatic TRACE replies are disabled for
+// the given path, see DisableAutoTRACE.
+func (r *Router) autoTRACEDisabled(path string) bool {
+	for _, prefix := range r.noAutoTRACE {
+		if hasPathPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Request headers left out of automatic TRACE replies, see HandleTRACE.
+var traceExcludedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Cookie":              true,
+	"Proxy-Authorization": true,
+}
+
+// reflectTrace replies to a TRACE request with the request line and header of
+// the request as a message/http body, see HandleTRACE.
+func reflectTrace(w http.ResponseWriter, req *http.Request) {
+	var buf bytes.Buffer
+	buf.WriteString(req.Method + " " + req.URL.RequestURI() + " " + req.Proto + "\r\n")
+	buf.WriteString("Host: " + req.Host + "\r\n")
+	req.Header.WriteSubset(&buf, traceExcludedHeaders)
+	buf.WriteString("\r\n")
+
+	w.Header().Set("Content-Type", "message/http")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// Gone registers a handle which answers all requests to the given path with
+// 410 Gone, e.g. for retired endpoints of an API. If a message is given, it is
+// used as the response body instead of the status text.
+// Unlike unregistered paths, the path is listed in the Allow header of
+// OPTIONS requests and 405 responses and by Routes.
+func (r *Router) Gone(method, path string, message ...string) *Route {
+	body := http.StatusText(http.StatusGone)
+	if len(message) > 0 {
+		body = strings.Join(message, " ")
+	}
+	return r.Handle(method, path, func(w http.ResponseWriter, _ *http.Request, _ Params) {
+		http.Error(w, body, http.StatusGone)
+	})
+}
+
+// Status registers a handle which answers all requests to the given path with
+// the given status code and body, e.g. for health checks:
+//     router.Status(http.MethodGet, "/healthz", http.StatusOK, "ok")
+// A non-empty body is sent as text/plain.
+func (r *Router) Status(method, path string, code int, body string) *Route {
+	return r.Handle(method, path, func(w http.ResponseWriter, _ *http.Request, _ Params) {
+		if body != "" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+		w.WriteHeader(code)
+		io.WriteString(w, body)
+	})
+}
+
+// Routes returns all registered routes in the order of registration.
+func (r *Router) Routes() []*Route {
+	routes := make([]*Route, len(r.routes))
+	copy(routes, r.routes)
+	return routes
+}
+
+// EchoParams is an http.HandlerFunc meant for debugging the param extraction
+// of routes. It writes every param in the request context as a
+// "X-Param-<name>" header and as a "name=value" line of the response body:
+//     router.HandlerFunc(http.MethodGet, "/user/@name", httprouter.EchoParams)
+func EchoParams(w http.ResponseWriter, req *http.Request) {
+	ps := ParamsFromContext(req.Context())
+	for _, p := range ps {
+		w.Header().Set("X-Param-"+p.Key, p.Value)
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, p := range ps {
+		fmt.Fprintf(w, "%s=%s\n", p.Key, p.Value)
+	}
+}
+
+// EmptyMethodHandler registers a handler which is called for all requests with
+// the given method as long as no route is registered for this method at all,
+// e.g. to answer all DELETE requests of a read-only API uniformly.
+// The handler takes priority over automatic OPTIONS replies and the
+// MethodNotAllowed and NotFound handlers. Passing a nil handler removes it.
+func (r *Router) EmptyMethodHandler(method string, handler http.Handler) {
+	if handler == nil {
+		delete(r.emptyMethodHandlers, method)
+		return
+	}
+	if r.emptyMethodHandlers == nil {
+		r.emptyMethodHandlers = make(map[string]http.Handler)
+	}
+	r.emptyMethodHandlers[method] = handler
 }
 
 // ServeFiles serves files from the given file system root.
@@ -368,9 +1332,7 @@ func (r *Router) HandlerFunc(method, path string, handler http.HandlerFunc) {
 // use http.Dir:
 //     router.ServeFiles("/src/*filepath", http.Dir("/var/www"))
 func (r *Router) ServeFiles(path string, root http.FileSystem) {
-	if len(path) < 10 || path[len(path)-10:] != "/*filepath" {
-		panic("path must end with /*filepath in path '" + path + "'")
-	}
+	checkFilepath(path)
 
 	fileServer := http.FileServer(root)
 
@@ -380,8 +1342,239 @@ func (r *Router) ServeFiles(path string, root http.FileSystem) {
 	})
 }
 
+// ServeFilesDev is like ServeFiles, but meant for local development.
+// Every response carries a "Cache-Control: no-store" header and conditional
+// request headers (If-Modified-Since, If-None-Match, ...) are ignored, so
+// edited files are always served in full instead of with 304 Not Modified.
+//     router.ServeFilesDev("/src/*filepath", http.Dir("./public"))
+func (r *Router) ServeFilesDev(path string, root http.FileSystem) {
+	checkFilepath(path)
+
+	fileServer := http.FileServer(root)
+
+	r.GET(path, func(w http.ResponseWriter, req *http.Request, ps Params) {
+		req.URL.Path = ps.ByName("filepath")
+		for _, h := range conditionalHeaders {
+			req.Header.Del(h)
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		fileServer.ServeHTTP(w, req)
+	})
+}
+
+// ServeFilesIndex is like ServeFiles, but directories are never listed.
+// Instead, requests to a directory are answered with the file of the given
+// name in the directory, e.g. "index.html", or by the NotFound handler of the
+// router if the directory has no such file. Missing files are answered by the
+// NotFound handler as well.
+//     router.ServeFilesIndex("/src/*filepath", http.Dir("/var/www"), "index.html")
+func (r *Router) ServeFilesIndex(path string, root http.FileSystem, indexName string) {
+	checkFilepath(path)
+
+	r.GET(path, func(w http.ResponseWriter, req *http.Request, ps Params) {
+		name := ps.ByName("filepath")
+		f, err := root.Open(name)
+		if err != nil {
+			r.handleNotFound(w, req)
+			return
+		}
+		stat, err := f.Stat()
+		if err == nil && stat.IsDir() {
+			f.Close()
+			f, err = root.Open(strings.TrimSuffix(name, "/") + "/" + indexName)
+			if err != nil {
+				r.handleNotFound(w, req)
+				return
+			}
+			stat, err = f.Stat()
+		}
+		defer f.Close()
+		if err != nil || stat.IsDir() {
+			r.handleNotFound(w, req)
+			return
+		}
+		http.ServeContent(w, req, stat.Name(), stat.ModTime(), f)
+	})
+}
+
+// ServeSPA serves a single-page application from the given file system root.
+// The path must end with a catch-all parameter. Files existing in root are
+// served like by ServeFiles, all other paths, including directories, are
+// answered with the index file, e.g. "index.html", so that the application can
+// route them on the client side. If the index file does not exist either,
+// http.NotFound is used.
+//     router.ServeSPA("/*filepath", http.Dir("./dist"), "index.html")
+func (r *Router) ServeSPA(path string, root http.FileSystem, index string) {
+	i := strings.LastIndex(path, "/*")
+	if i < 0 || strings.IndexByte(path[i+1:], '/') >= 0 {
+		panic("path must end with a catch-all parameter in path '" + path + "'")
+	}
+	name := path[i+2:]
+	index = "/" + strings.TrimPrefix(index, "/")
+
+	fileServer := http.FileServer(root)
+
+	r.GET(path, func(w http.ResponseWriter, req *http.Request, ps Params) {
+		filepath := ps.ByName(name)
+		if !strings.HasPrefix(filepath, "/") {
+			filepath = "/" + filepath
+		}
+		if f, err := root.Open(filepath); err == nil {
+			stat, err := f.Stat()
+			f.Close()
+			if err == nil && !stat.IsDir() {
+				req.URL.Path = filepath
+				fileServer.ServeHTTP(w, req)
+				return
+			}
+		}
+
+		f, err := root.Open(index)
+		if err != nil {
+			http.NotFound(w, req)
+			return
+		}
+		defer f.Close()
+		stat, err := f.Stat()
+		if err != nil || stat.IsDir() {
+			http.NotFound(w, req)
+			return
+		}
+		http.ServeContent(w, req, stat.Name(), stat.ModTime(), f)
+	})
+}
+
+// Request headers which could make the file server reply with a
+// 304 Not Modified or 412 Precondition Failed instead of the file.
+var conditionalHeaders = [...]string{
+	"If-Match",
+	"If-None-Match",
+	"If-Modified-Since",
+	"If-Unmodified-Since",
+	"If-Range",
+}
+
+func checkFilepath(path string) {
+	if len(path) < 10 || path[len(path)-10:] != "/*filepath" {
+		panic("path must end with /*filepath in path '" + path + "'")
+	}
+}
+
+// findCaseInsensitivePath makes a case-insensitive lookup of path in the tree
+// root, as configured by UnicodeCaseFold.
+func (r *Router) findCaseInsensitivePath(root *node, path string, fixTrailingSlash bool) (string, bool) {
+	if r.UnicodeCaseFold {
+		return root.findCaseFoldPath(path, fixTrailingSlash)
+	}
+	return root.findCaseInsensitivePath(path, fixTrailingSlash)
+}
+
+// redirectsTrailingSlash reports whether trailing slashes of requests with the
+// given method are redirected, see RedirectTrailingSlashMethods.
+func (r *Router) redirectsTrailingSlash(method string) bool {
+	if r.RedirectTrailingSlashMethods == nil {
+		return true
+	}
+	for _, m := range r.RedirectTrailingSlashMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// redirectCode returns the status code of a redirect of a request with the
+// given method, if redirects are configured with the given code, see
+// RedirectTrailingSlashCode.
+func redirectCode(method string, code int) int {
+	if code == 0 {
+		code = http.StatusMovedPermanently
+	}
+	if method != http.MethodGet {
+		// Preserve the method of the request
+		switch code {
+		case http.StatusMovedPermanently:
+			return http.StatusPermanentRedirect
+		case http.StatusFound:
+			return http.StatusTemporaryRedirect
+		}
+	}
+	return code
+}
+
+// redirect redirects the request to the given path of the tree root. The
+// reason is exposed in the X-Redirect-Reason header if DebugRedirects is
+// enabled. It reports whether the request was redirected, i.e. whether the
+// redirect was not vetoed by the RedirectPolicy or the target route.
+func (r *Router) redirect(w http.ResponseWriter, req *http.Request, root *node, path string, code int, reason string) bool {
+	if r.RedirectPolicy != nil && !r.RedirectPolicy(req, path) {
+		return false
+	}
+	if leaf, _, _ := root.getLeaf(path, nil, r.AllowEmptySegments); leaf != nil {
+		for rt := leaf.route; rt != nil; rt = rt.next {
+			if rt.noRedirect {
+				return false
+			}
+		}
+	}
+
+	if r.DebugRedirects {
+		w.Header().Set("X-Redirect-Reason", reason)
+	}
+	if r.OnRedirect != nil {
+		r.OnRedirect(req.Method, path, code)
+	}
+	if r.PreserveEncodedSlash {
+		setPreservedSlashPath(req.URL, path)
+	} else {
+		req.URL.Path = path
+	}
+	if !r.AbsoluteRedirects {
+		http.Redirect(w, req, req.URL.String(), code)
+		return true
+	}
+
+	u := *req.URL
+	u.Scheme, u.Host = r.scheme(req), req.Host
+	http.Redirect(w, req, u.String(), code)
+	return true
+}
+
+// insecureRequest answers a request over plain HTTP to a route only served
+// over HTTPS, see Route.SecureOnly.
+func (r *Router) insecureRequest(w http.ResponseWriter, req *http.Request) {
+	if !r.SecureRedirect {
+		http.Error(w,
+			http.StatusText(http.StatusForbidden),
+			http.StatusForbidden,
+		)
+		return
+	}
+
+	u := *req.URL
+	u.Scheme, u.Host = "https", req.Host
+	http.Redirect(w, req, u.String(), redirectCode(req.Method, http.StatusMovedPermanently))
+}
+
+// scheme returns the scheme of the request, "http" or "https", see
+// TrustForwardedProto.
+func (r *Router) scheme(req *http.Request) string {
+	if r.TrustForwardedProto {
+		if proto := req.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			return proto
+		}
+	}
+	if req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
 func (r *Router) recv(w http.ResponseWriter, req *http.Request) {
 	if rcv := recover(); rcv != nil {
+		if r.CapturePanicStack {
+			req = req.WithContext(context.WithValue(req.Context(), panicStackContextKey, debug.Stack()))
+		}
 		r.PanicHandler(w, req, rcv)
 	}
 }
@@ -393,9 +1586,14 @@ func (r *Router) recv(w http.ResponseWriter, req *http.Request) {
 // the same path with an extra / without the trailing slash should be performed.
 func (r *Router) Lookup(method, path string) (Handle, Params, bool) {
 	if root := r.trees[method]; root != nil {
-		handle, ps, tsr := root.getValue(path, r.getParams)
+		handle, ps, tsr := root.getValue(path, r.getParams, r.AllowEmptySegments)
 		if handle == nil {
 			r.putParams(ps)
+			if method != MethodAny {
+				if handle, ps, _ := r.Lookup(MethodAny, path); handle != nil {
+					return handle, ps, false
+				}
+			}
 			return nil, nil, tsr
 		}
 		if ps == nil {
@@ -403,17 +1601,38 @@ func (r *Router) Lookup(method, path string) (Handle, Params, bool) {
 		}
 		return handle, *ps, tsr
 	}
+	if method != MethodAny {
+		return r.Lookup(MethodAny, path)
+	}
 	return nil, nil, false
 }
 
+// Uncovered returns the sample paths for which no handle is registered with
+// the given method, i.e. the paths which Lookup can't find. Handles are not
+// called. It is meant to check in tests that all documented endpoints are
+// routed:
+//  if missing := router.Uncovered(http.MethodGet, documentedPaths); len(missing) > 0 {
+//      t.Errorf("unrouted paths: %v", missing)
+//  }
+func (r *Router) Uncovered(method string, samplePaths []string) []string {
+	var uncovered []string
+	for _, path := range samplePaths {
+		if handle, _, _ := r.Lookup(method, path); handle == nil {
+			uncovered = append(uncovered, path)
+		}
+	}
+	return uncovered
+}
+
 func (r *Router) allowed(path, reqMethod string) (allow string) {
 	allowed := make([]string, 0, 9)
 
 	if path == "*" { // server-wide
-		// empty method is used for internal calls to refresh the cache
-		if reqMethod == "" {
+		// empty method is used for internal calls to refresh the cache, which
+		// can't be used if methods are filtered
+		if reqMethod == "" || r.OptionsMethodFilter != nil {
 			for method := range r.trees {
-				if method == http.MethodOptions {
+				if method == http.MethodOptions || method == MethodAny || !r.advertised(reqMethod, method) {
 					continue
 				}
 				// Add request method to list of allowed methods
@@ -425,21 +1644,32 @@ func (r *Router) allowed(path, reqMethod string) (allow string) {
 	} else { // specific path
 		for method := range r.trees {
 			// Skip the requested method - we already tried this one
-			if method == reqMethod || method == http.MethodOptions {
+			if method == reqMethod || method == http.MethodOptions || method == MethodAny || !r.advertised(reqMethod, method) {
 				continue
 			}
 
-			handle, _, _ := r.trees[method].getValue(path, nil)
-			if handle != nil {
+			leaf, _, _ := r.trees[method].getLeaf(path, nil, r.AllowEmptySegments)
+			if leaf != nil {
 				// Add request method to list of allowed methods
 				allowed = append(allowed, method)
+
+				if method == http.MethodGet && reqMethod != http.MethodHead && r.autoHEAD(leaf) && r.advertised(reqMethod, http.MethodHead) {
+					if head := r.trees[http.MethodHead]; head == nil {
+						allowed = append(allowed, http.MethodHead)
+					} else if handle, _, _ := head.getValue(path, nil, r.AllowEmptySegments); handle == nil {
+						allowed = append(allowed, http.MethodHead)
+					}
+				}
 			}
 		}
 	}
 
 	if len(allowed) > 0 {
-		// Add request method to list of allowed methods
-		allowed = append(allowed, http.MethodOptions)
+		// OPTIONS is only allowed if it is answered automatically or by a
+		// registered handle
+		if path == "*" || r.HandleOPTIONS || r.hasHandle(http.MethodOptions, path) {
+			allowed = append(allowed, http.MethodOptions)
+		}
 
 		// Sort allowed methods.
 		// sort.Strings(allowed) unfortunately causes unnecessary allocations
@@ -457,59 +1687,229 @@ func (r *Router) allowed(path, reqMethod string) (allow string) {
 	return allow
 }
 
+// hasHandle reports whether a handle is registered for the method and path.
+func (r *Router) hasHandle(method, path string) bool {
+	root := r.trees[method]
+	if root == nil {
+		return false
+	}
+	handle, _, _ := root.getValue(path, nil, r.AllowEmptySegments)
+	return handle != nil
+}
+
+// advertised reports whether the method may be listed in the Allow header of a
+// response to a request with the given method, see OptionsMethodFilter.
+func (r *Router) advertised(reqMethod, method string) bool {
+	return reqMethod == "" || r.OptionsMethodFilter == nil || r.OptionsMethodFilter(method)
+}
+
+// AllowDebug reports for every method with registered routes whether a
+// handle for the given path exists, which is the raw probing result the
+// Allow header of 405 and automatic OPTIONS responses is built from.
+// It is meant as a debugging aid and does not modify the router.
+func (r *Router) AllowDebug(path string) map[string]bool {
+	methods := make(map[string]bool, len(r.trees))
+	for method, root := range r.trees {
+		handle, _, _ := root.getValue(path, nil, r.AllowEmptySegments)
+		methods[method] = handle != nil
+	}
+	return methods
+}
+
+// Capabilities returns the methods for which a route matches the given path,
+// each with the names of the params of the matched route, e.g.
+//  map[string][]string{"GET": {"id"}, "PUT": {"id", "version"}}
+// Routes for any method are listed under MethodAny.
+func (r *Router) Capabilities(path string) map[string][]string {
+	capabilities := make(map[string][]string)
+	for method, root := range r.trees {
+		handle, ps, _ := root.getValue(path, r.getParams, r.AllowEmptySegments)
+		if handle == nil {
+			r.putParams(ps)
+			continue
+		}
+		names := []string{}
+		if ps != nil {
+			for _, p := range *ps {
+				names = append(names, p.Key)
+			}
+			r.putParams(ps)
+		}
+		capabilities[method] = names
+	}
+	return capabilities
+}
+
 // ServeHTTP makes the router implement the http.Handler interface.
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if r.globalChain != nil {
+		req = req.WithContext(context.WithValue(req.Context(), matchedRouteContextKey, new(routeSlot)))
+		r.globalChain.ServeHTTP(w, req)
+		return
+	}
+	r.serveHTTP(w, req)
+}
+
+// serveHTTP routes the request, see ServeHTTP.
+func (r *Router) serveHTTP(w http.ResponseWriter, req *http.Request) {
 	if r.PanicHandler != nil {
 		defer r.recv(w, req)
 	}
 
+	if r.isLoading() {
+		if r.LoadingHandler != nil {
+			r.LoadingHandler.ServeHTTP(w, req)
+		} else {
+			http.Error(w,
+				http.StatusText(http.StatusServiceUnavailable),
+				http.StatusServiceUnavailable,
+			)
+		}
+		return
+	}
+
+	if r.MaxHeaderBytes > 0 && headerBytes(req.Header) > r.MaxHeaderBytes {
+		http.Error(w,
+			http.StatusText(http.StatusRequestHeaderFieldsTooLarge),
+			http.StatusRequestHeaderFieldsTooLarge,
+		)
+		return
+	}
+
+	if r.HeaderTimeout != "" {
+		if d, err := time.ParseDuration(req.Header.Get(r.HeaderTimeout)); err == nil && d > 0 {
+			ctx, cancel := context.WithTimeout(req.Context(), d)
+			defer cancel()
+			req = req.WithContext(ctx)
+		}
+	}
+
+	if len(r.hosts) > 0 && r.serveHost(w, req) {
+		return
+	}
+
 	path := req.URL.Path
+	if path == "" && r.UseRequestURIFallback && req.RequestURI != "" {
+		if u, err := url.ParseRequestURI(req.RequestURI); err == nil {
+			path = u.Path
+			req.URL.Path = u.Path
+			req.URL.RawPath = u.RawPath
+		}
+	}
+	if r.PreserveEncodedSlash {
+		path = preservedSlashPath(req.URL)
+	}
+	if len(r.pathAliases) > 0 {
+		path = r.aliasPath(path)
+	}
 
-	if root := r.trees[req.Method]; root != nil {
-		if handle, ps, tsr := root.getValue(path, r.getParams); handle != nil {
-			if ps != nil {
-				handle(w, req, *ps)
-				r.putParams(ps)
-			} else {
-				handle(w, req, nil)
+	// The server-wide request target * is only valid for OPTIONS requests
+	if path == "*" && req.Method != http.MethodOptions {
+		http.Error(w,
+			http.StatusText(http.StatusBadRequest),
+			http.StatusBadRequest,
+		)
+		return
+	}
+
+	if req.Method == http.MethodHead && r.AutoHEAD && r.serveAutoHEAD(w, req, path) {
+		return
+	}
+
+	root := r.trees[req.Method]
+	if root == nil {
+		if handler := r.emptyMethodHandlers[req.Method]; handler != nil {
+			handler.ServeHTTP(w, req)
+			return
+		}
+		root = r.trees[MethodAny]
+	}
+
+	if root != nil {
+		// The depth is only counted if it is measured, see MeasureLookupDepth
+		var depth *int
+		if r.MeasureLookupDepth {
+			depth = new(int)
+		}
+
+		if leaf, ps, tsr := root.getLeafDepth(path, r.getParams, r.AllowEmptySegments, depth); leaf != nil {
+			if depth != nil {
+				req = req.WithContext(context.WithValue(req.Context(), lookupDepthContextKey, *depth))
 			}
+			r.handle(w, req, leaf.handle, ps)
 			return
-		} else if req.Method != http.MethodConnect && path != "/" {
-			// Moved Permanently, request with GET method
-			code := http.StatusMovedPermanently
-			if req.Method != http.MethodGet {
-				// Permanent Redirect, request with same method
-				code = http.StatusPermanentRedirect
+		} else if anyRoot := r.trees[MethodAny]; anyRoot != nil && anyRoot != root && r.serveAny(anyRoot, w, req, path) {
+			return
+		} else if r.StrictNoRedirect {
+			if req.Method != http.MethodConnect && path != "/" && path != "*" && r.notFoundHint(w, root, path, tsr) {
+				return
+			}
+		} else if req.Method != http.MethodConnect && path != "/" && path != "*" {
+			// The server-wide request target * is never redirected
+			tsrCode := redirectCode(req.Method, r.RedirectTrailingSlashCode)
+			code := redirectCode(req.Method, r.RedirectFixedPathCode)
+
+			fixTrailingSlash := r.RedirectTrailingSlash && r.redirectsTrailingSlash(req.Method)
+
+			if r.CanonicalRedirect {
+				fixedPath, found := r.findCaseInsensitivePath(
+					root, CleanPath(path), r.redirectsTrailingSlash(req.Method),
+				)
+				if found && fixedPath != path && r.redirect(w, req, root, fixedPath, code, "canonical") {
+					return
+				}
 			}
 
 			if tsr && r.RedirectTrailingSlash {
+				tsrPath := path + "/"
 				if len(path) > 1 && path[len(path)-1] == '/' {
-					req.URL.Path = path[:len(path)-1]
+					tsrPath = path[:len(path)-1]
+				}
+				if fixTrailingSlash {
+					if r.redirect(w, req, root, tsrPath, tsrCode, "trailing-slash") {
+						return
+					}
 				} else {
-					req.URL.Path = path + "/"
+					if r.OnRedirectSuppressed != nil {
+						r.OnRedirectSuppressed(req.Method, tsrPath)
+					}
+					if r.ServeTrailingSlashInPlace {
+						handle, ps, _ := root.getValue(tsrPath, r.getParams, r.AllowEmptySegments)
+						if handle != nil {
+							r.handle(w, req, handle, ps)
+							return
+						}
+						r.putParams(ps)
+					}
 				}
-				http.Redirect(w, req, req.URL.String(), code)
-				return
 			}
 
 			// Try to fix the request path
 			if r.RedirectFixedPath {
-				fixedPath, found := root.findCaseInsensitivePath(
-					CleanPath(path),
-					r.RedirectTrailingSlash,
-				)
-				if found {
-					req.URL.Path = fixedPath
-					http.Redirect(w, req, req.URL.String(), code)
+				fixedPath, found := r.findCaseInsensitivePath(root, CleanPath(path), fixTrailingSlash)
+				if found && r.redirect(w, req, root, fixedPath, code, "fixed-path") {
+					return
+				}
+			} else if r.RedirectFixedCase {
+				fixedPath, found := r.findCaseInsensitivePath(root, path, fixTrailingSlash)
+				if found && r.redirect(w, req, root, fixedPath, code, "fixed-case") {
 					return
 				}
 			}
 		}
 	}
 
+	if req.Method == http.MethodTrace && r.HandleTRACE && !r.autoTRACEDisabled(path) {
+		// Handle TRACE requests
+		if allow := r.allowed(path, http.MethodTrace); allow != "" {
+			reflectTrace(w, req)
+			return
+		}
+	}
+
 	if req.Method == http.MethodOptions && r.HandleOPTIONS {
 		// Handle OPTIONS requests
-		if allow := r.allowed(path, http.MethodOptions); allow != "" {
+		if allow := r.allowed(path, http.MethodOptions); allow != "" && !r.autoOptionsDisabled(path) {
 			w.Header().Set("Allow", allow)
 			if r.GlobalOPTIONS != nil {
 				r.GlobalOPTIONS.ServeHTTP(w, req)
@@ -519,7 +1919,9 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	} else if r.HandleMethodNotAllowed { // Handle 405
 		if allow := r.allowed(path, req.Method); allow != "" {
 			w.Header().Set("Allow", allow)
-			if r.MethodNotAllowed != nil {
+			if h := r.groupHandler(path, groupMethodNotAllowed); h != nil {
+				h.ServeHTTP(w, req)
+			} else if r.MethodNotAllowed != nil {
 				r.MethodNotAllowed.ServeHTTP(w, req)
 			} else {
 				http.Error(w,
@@ -532,8 +1934,129 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// Handle 404
-	if r.NotFound != nil {
+	r.handleNotFound(w, req)
+}
+
+// notFoundHint answers a request which would have been redirected with 404
+// and the path it would have been redirected to, see StrictNoRedirect.
+// It reports whether the request was answered.
+func (r *Router) notFoundHint(w http.ResponseWriter, root *node, path string, tsr bool) bool {
+	var suggestion string
+	if tsr {
+		suggestion = path + "/"
+		if len(path) > 1 && path[len(path)-1] == '/' {
+			suggestion = path[:len(path)-1]
+		}
+	} else if fixedPath, found := r.findCaseInsensitivePath(root, CleanPath(path), true); found {
+		suggestion = fixedPath
+	} else {
+		return false
+	}
+
+	body, _ := json.Marshal(struct {
+		Error      string `json:"error"`
+		Suggestion string `json:"suggestion"`
+	}{"not_found", suggestion})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusNotFound)
+	w.Write(body)
+	return true
+}
+
+// autoHEAD reports whether HEAD requests are served by the GET route of the
+// leaf, see AutoHEAD.
+func (r *Router) autoHEAD(leaf *node) bool {
+	return r.AutoHEAD && leaf.route != nil && !leaf.route.noAutoHEAD
+}
+
+// serveAutoHEAD serves a HEAD request by the GET route matching the path, if
+// no HEAD route matches it, see AutoHEAD. It reports whether the request was
+// served.
+func (r *Router) serveAutoHEAD(w http.ResponseWriter, req *http.Request, path string) bool {
+	if head := r.trees[http.MethodHead]; head != nil {
+		if handle, _, _ := head.getValue(path, nil, r.AllowEmptySegments); handle != nil {
+			return false
+		}
+	}
+	get := r.trees[http.MethodGet]
+	if get == nil {
+		return false
+	}
+
+	leaf, ps, _ := get.getLeaf(path, r.getParams, r.AllowEmptySegments)
+	if leaf == nil || !r.autoHEAD(leaf) {
+		r.putParams(ps)
+		return false
+	}
+	r.handle(w, req, leaf.handle, ps)
+	return true
+}
+
+// serveAny serves the request by the MethodAny route matching the path, if
+// any. It reports whether the request was served.
+func (r *Router) serveAny(root *node, w http.ResponseWriter, req *http.Request, path string) bool {
+	handle, ps, _ := root.getValue(path, r.getParams, r.AllowEmptySegments)
+	if handle == nil {
+		r.putParams(ps)
+		return false
+	}
+	r.handle(w, req, handle, ps)
+	return true
+}
+
+// handleMissingQueryParam replies to a request without the given required
+// query parameter, see Route.QueryParam.
+func (r *Router) handleMissingQueryParam(w http.ResponseWriter, req *http.Request, name string) {
+	if r.MissingQueryParam != nil {
+		r.MissingQueryParam.ServeHTTP(w, req)
+	} else {
+		http.Error(w, "missing query parameter '"+name+"'", http.StatusBadRequest)
+	}
+}
+
+// handleValidationError replies to a request with an invalid value of the
+// param with the given name, see Route.Validate.
+func (r *Router) handleValidationError(w http.ResponseWriter, req *http.Request, name string, err error) {
+	if r.ValidationErrorHandler != nil {
+		r.ValidationErrorHandler(w, req, name, err)
+	} else {
+		http.Error(w, "invalid parameter '"+name+"': "+err.Error(), http.StatusBadRequest)
+	}
+}
+
+// headerBytes returns the total length of the keys and values of the header.
+func headerBytes(header http.Header) int {
+	n := 0
+	for key, values := range header {
+		for _, value := range values {
+			n += len(key) + len(value)
+		}
+	}
+	return n
+}
+
+// handleNotFound forwards the request to the Fallback handler, if set, or
+// replies with the NotFound handler or http.NotFound otherwise.
+func (r *Router) handleNotFound(w http.ResponseWriter, req *http.Request) {
+	var suggestions []string
+	if r.SuggestRoutes {
+		if suggestions = r.suggestRoutes(req.URL.Path); len(suggestions) > 0 {
+			w.Header().Set("X-Route-Suggestions", strings.Join(suggestions, ", "))
+		}
+	}
+
+	if r.Fallback != nil {
+		r.Fallback.ServeHTTP(w, req)
+	} else if h := r.groupHandler(req.URL.Path, groupNotFound); h != nil {
+		h.ServeHTTP(w, req)
+	} else if r.NotFound != nil {
 		r.NotFound.ServeHTTP(w, req)
+	} else if len(suggestions) > 0 {
+		http.Error(w,
+			"404 page not found\n\nDid you mean:\n  "+strings.Join(suggestions, "\n  "),
+			http.StatusNotFound,
+		)
 	} else {
 		http.NotFound(w, req)
 	}
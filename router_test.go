@@ -5,12 +5,19 @@
 package httprouter
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 type mockResponseWriter struct{}
@@ -499,6 +506,35 @@ func TestRouterPanicHandler(t *testing.T) {
 	}
 }
 
+func TestRouterCapturePanicStack(t *testing.T) {
+	var value interface{}
+	var stack []byte
+	router := New()
+	router.PanicHandler = func(w http.ResponseWriter, r *http.Request, p interface{}) {
+		value, stack = p, ContextPanicStack(r.Context())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	router.GET("/panic", func(_ http.ResponseWriter, _ *http.Request, _ Params) {
+		panic("oops!")
+	})
+
+	r, _ := http.NewRequest(http.MethodGet, "/panic", nil)
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	if value != "oops!" || stack != nil {
+		t.Errorf("unexpected panic value %v or stack without CapturePanicStack", value)
+	}
+
+	router.CapturePanicStack = true
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusInternalServerError || value != "oops!" {
+		t.Errorf("panic not handled: Code=%d, value=%v", w.Code, value)
+	}
+	if !strings.Contains(string(stack), "TestRouterCapturePanicStack") {
+		t.Errorf("stack trace lacks the panicking handle:\n%s", stack)
+	}
+}
+
 func TestRouterLookup(t *testing.T) {
 	routed := false
 	wantHandle := func(_ http.ResponseWriter, _ *http.Request, _ Params) {
@@ -756,3 +792,3109 @@ func TestRouterMatchedRoutePathWithVerbs(t *testing.T) {
 		t.Fatal("Routing failed!")
 	}
 }
+
+func TestRouterServeFilesDev(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httprouter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "app.js"), []byte("console.log(1)"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	router := New()
+
+	recv := catchPanic(func() {
+		router.ServeFilesDev("/noFilepath", http.Dir(dir))
+	})
+	if recv == nil {
+		t.Fatal("registering path not ending with '*filepath' did not panic")
+	}
+
+	router.ServeFilesDev("/static/*filepath", http.Dir(dir))
+
+	r, _ := http.NewRequest(http.MethodGet, "/static/app.js", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("serving file failed: Code=%d", w.Code)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "no-store" {
+		t.Errorf("unexpected Cache-Control header value: %q", cc)
+	}
+
+	// conditional requests must not be answered with 304 Not Modified
+	r, _ = http.NewRequest(http.MethodGet, "/static/app.js", nil)
+	r.Header.Set("If-Modified-Since", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	r.Header.Set("If-None-Match", "*")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("conditional request handling failed: Code=%d", w.Code)
+	}
+	if got := w.Body.String(); got != "console.log(1)" {
+		t.Errorf("unexpected body: %q", got)
+	}
+}
+
+func TestRouterServeFilesIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httprouter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, sub := range []string{"docs", "empty"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		"app.js":          "console.log(1)",
+		"docs/index.html": "<docs>",
+		"empty/.keep":     "",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	router := New()
+
+	recv := catchPanic(func() {
+		router.ServeFilesIndex("/noFilepath", http.Dir(dir), "index.html")
+	})
+	if recv == nil {
+		t.Fatal("registering path not ending with '*filepath' did not panic")
+	}
+
+	router.ServeFilesIndex("/static/*filepath", http.Dir(dir), "index.html")
+	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("custom 404"))
+	})
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/static/app.js", http.StatusOK, "console.log(1)"},
+		{"/static/docs/", http.StatusOK, "<docs>"},
+		{"/static/docs", http.StatusOK, "<docs>"},
+		{"/static/docs/index.html", http.StatusOK, "<docs>"},
+		{"/static/empty/", http.StatusNotFound, "custom 404"},
+		{"/static/", http.StatusNotFound, "custom 404"},
+		{"/static/missing.js", http.StatusNotFound, "custom 404"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(http.MethodGet, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("serving %s failed: Code=%d, body=%q, want %d, %q", test.path, w.Code, w.Body.String(), test.code, test.body)
+		}
+	}
+}
+
+func TestRouterServeSPA(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httprouter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "app.js"), []byte("console.log(1)"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte("<html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "assets"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	router := New()
+
+	recv := catchPanic(func() {
+		router.ServeSPA("/app", http.Dir(dir), "index.html")
+	})
+	if recv == nil {
+		t.Fatal("registering path not ending with a catch-all did not panic")
+	}
+
+	router.ServeSPA("/*path", http.Dir(dir), "index.html")
+	noIndex := New()
+	noIndex.ServeSPA("/app/*filepath", http.Dir(dir), "missing.html")
+
+	tests := []struct {
+		router *Router
+		path   string
+		code   int
+		body   string
+	}{
+		{router, "/app.js", http.StatusOK, "console.log(1)"},
+		{router, "/", http.StatusOK, "<html>"},
+		{router, "/users/42", http.StatusOK, "<html>"},
+		{router, "/missing.js", http.StatusOK, "<html>"},
+		{router, "/assets", http.StatusOK, "<html>"},
+		{noIndex, "/app/app.js", http.StatusOK, "console.log(1)"},
+		{noIndex, "/app/users/42", http.StatusNotFound, "404 page not found\n"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(http.MethodGet, test.path, nil)
+		w := httptest.NewRecorder()
+		test.router.ServeHTTP(w, r)
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("serving %s failed: Code=%d, body=%q, want %d, %q", test.path, w.Code, w.Body.String(), test.code, test.body)
+		}
+	}
+}
+
+func TestRouterAllowDebug(t *testing.T) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request, _ Params) {}
+
+	router := New()
+	if methods := router.AllowDebug("/path"); len(methods) != 0 {
+		t.Errorf("expected no methods for empty router, got %v", methods)
+	}
+
+	router.GET("/path", handlerFunc)
+	router.POST("/path", handlerFunc)
+	router.DELETE("/other", handlerFunc)
+
+	want := map[string]bool{
+		http.MethodGet:    true,
+		http.MethodPost:   true,
+		http.MethodDelete: false,
+	}
+	if methods := router.AllowDebug("/path"); !reflect.DeepEqual(methods, want) {
+		t.Errorf("wrong probe result: want %v, got %v", want, methods)
+	}
+
+	want = map[string]bool{
+		http.MethodGet:    false,
+		http.MethodPost:   false,
+		http.MethodDelete: false,
+	}
+	if methods := router.AllowDebug("/nope"); !reflect.DeepEqual(methods, want) {
+		t.Errorf("wrong probe result: want %v, got %v", want, methods)
+	}
+}
+
+func TestRouterUseRequestURIFallback(t *testing.T) {
+	routed := false
+	router := New()
+	router.GET("/user/@name", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		routed = ps.ByName("name") == "gopher"
+	})
+
+	newRequest := func() *http.Request {
+		r, _ := http.NewRequest(http.MethodGet, "/user/gopher?x=1", nil)
+		r.RequestURI = "/user/gopher?x=1"
+		r.URL.Path = "" // as left behind by a rewriting proxy
+		return r
+	}
+
+	// disabled: the empty path is not routed
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newRequest())
+	if routed {
+		t.Fatal("routed empty path without RequestURI fallback")
+	}
+
+	router.UseRequestURIFallback = true
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newRequest())
+	if !(w.Code == http.StatusOK && routed) {
+		t.Errorf("RequestURI fallback routing failed: Code=%d, routed=%t", w.Code, routed)
+	}
+
+	// an empty RequestURI keeps the default behavior
+	r := newRequest()
+	r.RequestURI = ""
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusMovedPermanently {
+		t.Errorf("unexpected response for empty RequestURI: Code=%d", w.Code)
+	}
+}
+
+func TestRouterOptionalParam(t *testing.T) {
+	var page string
+	var params Params
+	router := New()
+	router.GET("/items/@page?=1", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		page = ps.ByName("page")
+	})
+	article := router.GET("/articles/@id/@slug?", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		params = ps
+	})
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/items", "1"},   // default applied
+		{"/items/3", "3"}, // provided value overrides the default
+	}
+	for _, tt := range tests {
+		page = ""
+		r, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || page != tt.want {
+			t.Errorf("routing %s failed: Code=%d, page=%q, want %q", tt.path, w.Code, page, tt.want)
+		}
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/articles/42", nil)
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	if want := (Params{Param{"id", "42"}}); !reflect.DeepEqual(params, want) {
+		t.Errorf("wrong params: want %v, got %v", want, params)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/articles/42/my-title", nil)
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	if want := (Params{Param{"id", "42"}, Param{"slug", "my-title"}}); !reflect.DeepEqual(params, want) {
+		t.Errorf("wrong params: want %v, got %v", want, params)
+	}
+
+	// the URLs of both paths are built from the same route
+	if u, err := article.URL(Params{{"id", "42"}}); err != nil || u != "/articles/42" {
+		t.Errorf("wrong URL without the optional param: %q, %v", u, err)
+	}
+	if u, err := article.URL(Params{{"id", "42"}, {"slug", "my-title"}}); err != nil || u != "/articles/42/my-title" {
+		t.Errorf("wrong URL with the optional param: %q, %v", u, err)
+	}
+
+	handle := func(_ http.ResponseWriter, _ *http.Request, _ Params) {}
+	for _, path := range []string{
+		"/a/@b?/c", "/a/b?", "/a/@b?x", "/a/x@b?", "/a/*b?", "/a/@?", "/a/@b.@c?", "/a/@b:c?", "/a?/@b",
+	} {
+		if recv := catchPanic(func() { router.GET(path, handle) }); recv == nil {
+			t.Errorf("registering invalid optional parameter in '%s' did not panic", path)
+		}
+	}
+}
+
+func TestRouterHandlePattern(t *testing.T) {
+	var gotPattern string
+	var gotParams Params
+	handle := func(_ http.ResponseWriter, _ *http.Request, ps Params, pattern string) {
+		gotPattern = pattern
+		gotParams = ps
+	}
+
+	router := New()
+	router.GETPattern("/user/@name", handle)
+	router.HandlePattern(http.MethodPost, "/user/@name/files/*filepath", handle)
+
+	tests := []struct {
+		method  string
+		path    string
+		pattern string
+		params  Params
+	}{
+		{http.MethodGet, "/user/gopher", "/user/@name", Params{Param{"name", "gopher"}}},
+		{http.MethodPost, "/user/gopher/files/a/b.txt", "/user/@name/files/*filepath",
+			Params{Param{"name", "gopher"}, Param{"filepath", "/a/b.txt"}}},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(tt.method, tt.path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		if gotPattern != tt.pattern {
+			t.Errorf("wrong pattern for %s: want %s, got %s", tt.path, tt.pattern, gotPattern)
+		}
+		if !reflect.DeepEqual(gotParams, tt.params) {
+			t.Errorf("wrong params for %s: want %v, got %v", tt.path, tt.params, gotParams)
+		}
+	}
+
+	if recv := catchPanic(func() { router.GETPattern("/nil", nil) }); recv == nil {
+		t.Error("registering nil handle did not panic")
+	}
+}
+
+func TestRouterOPTIONSNotAllowed(t *testing.T) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request, _ Params) {}
+
+	router := New()
+	router.HandleOPTIONS = false
+	router.GET("/path", handlerFunc)
+	router.POST("/path", handlerFunc)
+
+	// existing path, OPTIONS is treated like any other method
+	r, _ := http.NewRequest(http.MethodOptions, "/path", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("OPTIONS handling failed: Code=%d, Header=%v", w.Code, w.Header())
+	} else if allow := w.Header().Get("Allow"); allow != "GET, POST" {
+		t.Error("unexpected Allow header value: " + allow)
+	}
+
+	// unknown path
+	r, _ = http.NewRequest(http.MethodOptions, "/doesnotexist", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("OPTIONS handling failed: Code=%d, Header=%v", w.Code, w.Header())
+	}
+
+	// without 405 handling, OPTIONS requests end up at NotFound
+	router.HandleMethodNotAllowed = false
+	r, _ = http.NewRequest(http.MethodOptions, "/path", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("OPTIONS handling failed: Code=%d, Header=%v", w.Code, w.Header())
+	}
+}
+
+func TestRouterExtensionParam(t *testing.T) {
+	var format string
+	router := New()
+	router.GET("/report.@format", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		format = ps.ByName("format")
+	})
+	router.GET("/files/@name.@ext", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		format = ps.ByName("name") + "|" + ps.ByName("ext")
+	})
+	router.GET("/reports/@id.json", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		format = ps.ByName("id") + "|json"
+	})
+	router.GET("/reports/@id.csv", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		format = ps.ByName("id") + "|csv"
+	})
+	router.GET("/x/@id/foo", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		format = ps.ByName("id") + "|foo"
+	})
+	router.GET("/x/@id.json", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		format = ps.ByName("id") + "|json"
+	})
+
+	tests := []struct {
+		path     string
+		code     int
+		format   string
+		location string
+	}{
+		{"/report.json", http.StatusOK, "json", ""},
+		{"/report.csv", http.StatusOK, "csv", ""},
+		{"/report", http.StatusNotFound, "", ""},
+		{"/files/a.b.txt", http.StatusOK, "a.b|txt", ""},
+		{"/files/noext", http.StatusNotFound, "", ""},
+		{"/FILES/a.txt", http.StatusMovedPermanently, "", "/files/a.txt"},
+		{"/reports/42.json", http.StatusOK, "42|json", ""},
+		{"/reports/42.csv", http.StatusOK, "42|csv", ""},
+		{"/reports/42.xml", http.StatusNotFound, "", ""},
+		{"/reports/42.JSON", http.StatusMovedPermanently, "", "/reports/42.json"},
+		{"/x/v1.json", http.StatusOK, "v1|json", ""},
+		{"/x/v1.json/foo", http.StatusOK, "v1.json|foo", ""},
+		{"/x/v1/foo", http.StatusOK, "v1|foo", ""},
+		{"/X/v1.json/FOO", http.StatusMovedPermanently, "", "/x/v1.json/foo"},
+	}
+	for _, tt := range tests {
+		format = ""
+		r, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != tt.code || format != tt.format || w.Header().Get("Location") != tt.location {
+			t.Errorf("routing %s failed: Code=%d, format=%q, Location=%q", tt.path, w.Code, format, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestRouterRedirectFixedCase(t *testing.T) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request, _ Params) {}
+
+	router := New()
+	router.RedirectFixedPath = false
+	router.RedirectFixedCase = true
+	router.GET("/blog/@post", handlerFunc)
+	router.GET("/about/", handlerFunc)
+
+	testRoutes := []struct {
+		route    string
+		code     int
+		location string
+	}{
+		{"/Blog/Hello-World", http.StatusMovedPermanently, "/blog/Hello-World"}, // param casing is kept
+		{"/BLOG/go", http.StatusMovedPermanently, "/blog/go"},
+		{"/About", http.StatusMovedPermanently, "/about/"}, // fixed case +/
+		{"/about", http.StatusMovedPermanently, "/about/"}, // TSR
+		{"/blog/../ABOUT/", http.StatusNotFound, ""},       // path is not cleaned
+		{"/nope", http.StatusNotFound, ""},
+	}
+	for _, tr := range testRoutes {
+		r, _ := http.NewRequest(http.MethodGet, tr.route, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if !(w.Code == tr.code && w.Header().Get("Location") == tr.location) {
+			t.Errorf("routing %s failed: Code=%d, Location=%q", tr.route, w.Code, w.Header().Get("Location"))
+		}
+	}
+
+	// without trailing slash redirects the slash is not fixed
+	router.RedirectTrailingSlash = false
+	r, _ := http.NewRequest(http.MethodGet, "/About", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unexpected response without RedirectTrailingSlash: Code=%d, Location=%q", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestRouterRemovePrefix(t *testing.T) {
+	router := New()
+	for _, path := range []string{
+		"/api",
+		"/api/users",
+		"/api/users/@id",
+		"/api/items/*filepath",
+		"/apix",
+		"/other",
+	} {
+		router.GET(path, fakeHandler(path))
+	}
+	router.POST("/api/users", fakeHandler("POST /api/users"))
+
+	if n := router.RemovePrefix(http.MethodGet, "/api"); n != 4 {
+		t.Errorf("RemovePrefix returned %d, expected 4", n)
+	}
+
+	for _, path := range []string{"/api", "/api/users", "/api/users/1", "/api/items/a"} {
+		if handle, _, _ := router.Lookup(http.MethodGet, path); handle != nil {
+			t.Errorf("route %s should have been removed", path)
+		}
+	}
+	for _, path := range []string{"/apix", "/other"} {
+		if handle, _, _ := router.Lookup(http.MethodGet, path); handle == nil {
+			t.Errorf("route %s should not have been removed", path)
+		}
+	}
+	if handle, _, _ := router.Lookup(http.MethodPost, "/api/users"); handle == nil {
+		t.Error("route POST /api/users should not have been removed")
+	}
+
+	// the removed paths can be registered again
+	router.GET("/api/users/@name", fakeHandler("/api/users/@name"))
+	if handle, ps, _ := router.Lookup(http.MethodGet, "/api/users/gopher"); handle == nil || ps.ByName("name") != "gopher" {
+		t.Errorf("re-registered route not found: Params=%v", ps)
+	}
+
+	// removing the last GET route deletes the tree
+	router.RemovePrefix(http.MethodGet, "/")
+	if _, ok := router.trees[http.MethodGet]; ok {
+		t.Error("expected the GET tree to be removed")
+	}
+	if n := router.RemovePrefix(http.MethodGet, "/"); n != 0 {
+		t.Errorf("RemovePrefix returned %d, expected 0", n)
+	}
+
+	// the empty prefix removes all routes of the method
+	if n := router.RemovePrefix(http.MethodPost, ""); n != 1 {
+		t.Errorf("RemovePrefix returned %d, expected 1", n)
+	}
+}
+
+func TestRouterDefaultLimits(t *testing.T) {
+	type result struct {
+		readErr     error
+		hasDeadline bool
+		timeout     time.Duration
+	}
+	var res result
+	handle := func(_ http.ResponseWriter, req *http.Request, _ Params) {
+		_, res.readErr = ioutil.ReadAll(req.Body)
+		var deadline time.Time
+		deadline, res.hasDeadline = req.Context().Deadline()
+		res.timeout = deadline.Sub(time.Now())
+	}
+
+	router := New()
+	router.DefaultMaxBody = 4
+	router.DefaultTimeout = time.Minute
+	router.POST("/default", handle)
+	router.POST("/override", handle).MaxBody(8).Timeout(time.Hour)
+	router.POST("/unlimited", handle).MaxBody(0).Timeout(0)
+
+	testRoutes := []struct {
+		route       string
+		body        string
+		readErr     bool
+		hasDeadline bool
+		timeout     time.Duration
+	}{
+		{"/default", "1234", false, true, time.Minute},
+		{"/default", "12345", true, true, time.Minute},
+		{"/override", "12345678", false, true, time.Hour},
+		{"/override", "123456789", true, true, time.Hour},
+		{"/unlimited", "123456789", false, false, 0},
+	}
+	for _, tr := range testRoutes {
+		res = result{}
+		r, _ := http.NewRequest(http.MethodPost, tr.route, strings.NewReader(tr.body))
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		if (res.readErr != nil) != tr.readErr {
+			t.Errorf("reading body of %d bytes from %s: unexpected error %v", len(tr.body), tr.route, res.readErr)
+		}
+		if res.hasDeadline != tr.hasDeadline {
+			t.Errorf("routing %s: unexpected deadline %v", tr.route, res.hasDeadline)
+		}
+		if tr.hasDeadline && (res.timeout > tr.timeout || res.timeout < tr.timeout-time.Second) {
+			t.Errorf("routing %s: wrong timeout %v, expected %v", tr.route, res.timeout, tr.timeout)
+		}
+	}
+}
+
+func TestRouterMerge(t *testing.T) {
+	users := New()
+	users.GET("/users", fakeHandler("/users"))
+	users.GET("/users/@id", fakeHandler("/users/@id"))
+	users.POST("/users", fakeHandler("POST /users"))
+	users.NotFound = http.NotFoundHandler()
+
+	router := New()
+	router.GET("/", fakeHandler("/"))
+	router.GET("/items/@id", fakeHandler("/items/@id"))
+
+	if err := router.Merge(users); err != nil {
+		t.Fatalf("unexpected merge error: %v", err)
+	}
+	if router.NotFound != nil {
+		t.Error("the configuration of the merged router must be ignored")
+	}
+	for _, path := range []string{"/", "/items/1", "/users", "/users/gopher"} {
+		if handle, _, _ := router.Lookup(http.MethodGet, path); handle == nil {
+			t.Errorf("route GET %s not found after merge", path)
+		}
+	}
+	if handle, _, _ := router.Lookup(http.MethodPost, "/users"); handle == nil {
+		t.Error("route POST /users not found after merge")
+	}
+	if handle, ps, _ := router.Lookup(http.MethodGet, "/users/gopher"); handle == nil || ps.ByName("id") != "gopher" {
+		t.Errorf("wrong params after merge: %v", ps)
+	}
+
+	// conflicts leave the router unchanged
+	conflicting := New()
+	conflicting.GET("/orders", fakeHandler("/orders"))
+	conflicting.DELETE("/orders", fakeHandler("DELETE /orders"))
+	conflicting.GET("/users/@name", fakeHandler("/users/@name"))
+
+	err := router.Merge(conflicting)
+	if err == nil {
+		t.Fatal("expected a merge conflict")
+	}
+	if msg := err.Error(); !strings.Contains(msg, "GET /users/@name") {
+		t.Errorf("error does not name the conflicting route: %s", msg)
+	}
+	if handle, _, _ := router.Lookup(http.MethodGet, "/orders"); handle != nil {
+		t.Error("routes of a failed merge must not be added")
+	}
+	if _, ok := router.trees[http.MethodDelete]; ok {
+		t.Error("routes of a failed merge must not be added")
+	}
+	if handle, _, _ := router.Lookup(http.MethodGet, "/users/gopher"); handle == nil {
+		t.Error("existing routes must remain after a failed merge")
+	}
+}
+
+func TestRouterMergeCopiesRoutes(t *testing.T) {
+	decorator := func(name string) func(string, http.HandlerFunc) http.HandlerFunc {
+		return func(pattern string, h http.HandlerFunc) http.HandlerFunc {
+			return func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Set("X-Decorator", name)
+				h(w, req)
+			}
+		}
+	}
+
+	other := New()
+	other.Decorator = decorator("other")
+	other.POST("/login", fakeHandler("/login")).RateLimit(1, time.Minute)
+
+	serve := func(router *Router) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(http.MethodPost, "/login", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+	if w := serve(other); w.Code != http.StatusOK || w.Header().Get("X-Decorator") != "other" {
+		t.Fatalf("unexpected response: Code=%d, X-Decorator=%q", w.Code, w.Header().Get("X-Decorator"))
+	}
+
+	router := New()
+	router.Decorator = decorator("router")
+	if err := router.Merge(other); err != nil {
+		t.Fatalf("unexpected merge error: %v", err)
+	}
+
+	// the merged route has a decoration and a rate limit budget of its own
+	w := serve(router)
+	if w.Code != http.StatusOK {
+		t.Errorf("request to merged route rejected: Code=%d", w.Code)
+	}
+	if got := w.Header().Get("X-Decorator"); got != "router" {
+		t.Errorf("merged route decorated by %q", got)
+	}
+	if w := serve(router); w.Code != http.StatusTooManyRequests {
+		t.Errorf("request over limit not rejected: Code=%d", w.Code)
+	}
+}
+
+func TestRouterEchoParams(t *testing.T) {
+	router := New()
+	router.HandlerFunc(http.MethodGet, "/a/@b/@c", EchoParams)
+
+	r, _ := http.NewRequest(http.MethodGet, "/a/foo/bar", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+	if b, c := w.Header().Get("X-Param-b"), w.Header().Get("X-Param-c"); b != "foo" || c != "bar" {
+		t.Errorf("wrong param headers: Header=%v", w.Header())
+	}
+	if body := w.Body.String(); body != "b=foo\nc=bar\n" {
+		t.Errorf("wrong body: %q", body)
+	}
+}
+
+func TestRouterEmptyMethodHandler(t *testing.T) {
+	router := New()
+	router.GET("/path", fakeHandler("/path"))
+	router.PUT("/path", fakeHandler("PUT /path"))
+	router.EmptyMethodHandler(http.MethodDelete, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "read-only", http.StatusMethodNotAllowed)
+	}))
+	router.EmptyMethodHandler(http.MethodPut, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	testRoutes := []struct {
+		method string
+		route  string
+		code   int
+		body   string
+	}{
+		{http.MethodDelete, "/path", http.StatusMethodNotAllowed, "read-only\n"},
+		{http.MethodDelete, "/unknown", http.StatusMethodNotAllowed, "read-only\n"},
+		{http.MethodPut, "/unknown", http.StatusNotFound, "404 page not found\n"}, // PUT has routes
+		{http.MethodPost, "/path", http.StatusMethodNotAllowed, "Method Not Allowed\n"},
+	}
+	for _, tr := range testRoutes {
+		r, _ := http.NewRequest(tr.method, tr.route, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if !(w.Code == tr.code && w.Body.String() == tr.body) {
+			t.Errorf("%s %s failed: Code=%d, Body=%q", tr.method, tr.route, w.Code, w.Body.String())
+		}
+	}
+
+	// once a route is registered, the handler is not used anymore
+	router.DELETE("/path", fakeHandler("DELETE /path"))
+	r, _ := http.NewRequest(http.MethodDelete, "/unknown", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unexpected response after registering a route: Code=%d", w.Code)
+	}
+}
+
+func TestRouterUnicodeCaseFold(t *testing.T) {
+	router := New()
+	router.GET("/café", fakeHandler("/café"))
+	router.GET("/straße/@nr", fakeHandler("/straße/@nr"))
+	router.GET("/Ωmega/", fakeHandler("/Ωmega/"))
+
+	testRoutes := []struct {
+		route    string
+		code     int
+		location string
+	}{
+		{"/Café", http.StatusMovedPermanently, "/caf%C3%A9"},
+		{"/CAFÉ", http.StatusMovedPermanently, "/caf%C3%A9"},
+		{"/x/../CAFÉ/", http.StatusMovedPermanently, "/caf%C3%A9"},
+		{"/ωMEGA", http.StatusMovedPermanently, "/%CE%A9mega/"},
+		{"/cafe", http.StatusNotFound, ""},
+	}
+	for _, tr := range testRoutes {
+		r, _ := http.NewRequest(http.MethodGet, tr.route, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if !(w.Code == tr.code && w.Header().Get("Location") == tr.location) {
+			t.Errorf("routing %s failed: Code=%d, Location=%q", tr.route, w.Code, w.Header().Get("Location"))
+		}
+	}
+
+	// only Unicode case folding matches variants with a different length
+	r, _ := http.NewRequest(http.MethodGet, "/STRAẞE/7", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unexpected response without UnicodeCaseFold: Code=%d", w.Code)
+	}
+
+	router.UnicodeCaseFold = true
+	testRoutes = append(testRoutes, struct {
+		route    string
+		code     int
+		location string
+	}{"/STRAẞE/7", http.StatusMovedPermanently, "/stra%C3%9Fe/7"})
+	for _, tr := range testRoutes {
+		r, _ := http.NewRequest(http.MethodGet, tr.route, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if !(w.Code == tr.code && w.Header().Get("Location") == tr.location) {
+			t.Errorf("routing %s with UnicodeCaseFold failed: Code=%d, Location=%q", tr.route, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestRouterDebugRedirects(t *testing.T) {
+	router := New()
+	router.DebugRedirects = true
+	router.GET("/path", fakeHandler("/path"))
+	router.GET("/dir/", fakeHandler("/dir/"))
+
+	caseRouter := New()
+	caseRouter.DebugRedirects = true
+	caseRouter.RedirectFixedPath = false
+	caseRouter.RedirectFixedCase = true
+	caseRouter.GET("/path", fakeHandler("/path"))
+
+	testRoutes := []struct {
+		router *Router
+		route  string
+		code   int
+		reason string
+	}{
+		{router, "/path/", http.StatusMovedPermanently, "trailing-slash"},
+		{router, "/dir", http.StatusMovedPermanently, "trailing-slash"},
+		{router, "/PATH", http.StatusMovedPermanently, "fixed-path"},
+		{router, "/../path", http.StatusMovedPermanently, "fixed-path"},
+		{router, "/path", http.StatusOK, ""},
+		{caseRouter, "/Path", http.StatusMovedPermanently, "fixed-case"},
+	}
+	for _, tr := range testRoutes {
+		r, _ := http.NewRequest(http.MethodGet, tr.route, nil)
+		w := httptest.NewRecorder()
+		tr.router.ServeHTTP(w, r)
+		if !(w.Code == tr.code && w.Header().Get("X-Redirect-Reason") == tr.reason) {
+			t.Errorf("routing %s failed: Code=%d, Header=%v", tr.route, w.Code, w.Header())
+		}
+	}
+
+	// without the debug flag the reason is not exposed
+	router.DebugRedirects = false
+	r, _ := http.NewRequest(http.MethodGet, "/path/", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if reason := w.Header().Get("X-Redirect-Reason"); reason != "" {
+		t.Errorf("unexpected X-Redirect-Reason header: %q", reason)
+	}
+}
+
+func TestRouterAdd(t *testing.T) {
+	router := New()
+
+	var order []string
+	mw := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, req)
+			})
+		}
+	}
+	echo := func(prefix string) Handle {
+		return func(w http.ResponseWriter, _ *http.Request, ps Params) {
+			fmt.Fprint(w, prefix+ps.ByName("id"))
+		}
+	}
+
+	rt, err := router.Add(RouteSpec{
+		Method:     http.MethodGet,
+		Path:       "/users/@id",
+		Name:       "user",
+		Handle:     echo("user "),
+		Middleware: []func(http.Handler) http.Handler{mw("first"), mw("second")},
+		Constraints: map[string]func(string) bool{
+			"id": func(v string) bool { return v != "root" },
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rt.Method() != http.MethodGet || rt.Path() != "/users/@id" || rt.Name() != "user" {
+		t.Errorf("wrong route: %s %s %s", rt.Method(), rt.Path(), rt.Name())
+	}
+
+	serve := func(path string) (int, string) {
+		r, _ := http.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Code, w.Body.String()
+	}
+
+	if code, body := serve("/users/gopher"); code != http.StatusOK || body != "user gopher" {
+		t.Errorf("serving added route failed: Code=%d, Body=%q", code, body)
+	}
+	if !reflect.DeepEqual(order, []string{"first", "second"}) {
+		t.Errorf("wrong middleware order: %v", order)
+	}
+	if code, _ := serve("/users/root"); code != http.StatusNotFound {
+		t.Errorf("constraint not applied: Code=%d", code)
+	}
+
+	// replace keeps the middleware
+	order = nil
+	rt.Replace(echo("replaced "))
+	if code, body := serve("/users/gopher"); code != http.StatusOK || body != "replaced gopher" {
+		t.Errorf("serving replaced route failed: Code=%d, Body=%q", code, body)
+	}
+	if len(order) != 2 {
+		t.Errorf("middleware not applied to replaced handle: %v", order)
+	}
+
+	// remove
+	rt.Remove()
+	if code, _ := serve("/users/gopher"); code != http.StatusNotFound {
+		t.Errorf("removed route still served: Code=%d", code)
+	}
+	rt.Remove() // no-op
+
+	// errors
+	router.GET("/items/@id", fakeHandler("/items/@id"))
+	for _, spec := range []RouteSpec{
+		{Method: http.MethodGet, Path: "/items/@name", Handle: echo("")},
+		{Method: http.MethodGet, Path: "items", Handle: echo("")},
+		{Method: "", Path: "/items", Handle: echo("")},
+		{Method: http.MethodGet, Path: "/items"},
+	} {
+		if rt, err := router.Add(spec); err == nil || rt != nil {
+			t.Errorf("expected an error adding %s %s", spec.Method, spec.Path)
+		}
+	}
+	if code, _ := serve("/items/1"); code != http.StatusOK {
+		t.Errorf("existing route affected by failed add: Code=%d", code)
+	}
+}
+
+func TestRouterUncovered(t *testing.T) {
+	router := New()
+	router.GET("/users", fakeHandler("/users"))
+	router.GET("/users/@id", fakeHandler("/users/@id"))
+	router.GET("/files/*filepath", fakeHandler("/files/*filepath"))
+	router.POST("/orders", fakeHandler("/orders"))
+
+	samples := []string{"/users", "/users/1", "/users/1/posts", "/files/a/b", "/orders", "/users/"}
+	uncovered := router.Uncovered(http.MethodGet, samples)
+	if expected := []string{"/users/1/posts", "/orders", "/users/"}; !reflect.DeepEqual(uncovered, expected) {
+		t.Errorf("wrong uncovered paths: %v, expected %v", uncovered, expected)
+	}
+
+	if uncovered := router.Uncovered(http.MethodPost, []string{"/orders"}); len(uncovered) != 0 {
+		t.Errorf("unexpected uncovered paths: %v", uncovered)
+	}
+	if uncovered := router.Uncovered(http.MethodDelete, []string{"/orders"}); len(uncovered) != 1 {
+		t.Errorf("expected all paths to be uncovered: %v", uncovered)
+	}
+}
+
+func TestRouterFallback(t *testing.T) {
+	legacy := http.NewServeMux()
+	legacy.HandleFunc("/legacy/", func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "legacy "+req.URL.Path)
+	})
+
+	router := New()
+	router.GET("/new", fakeHandler("/new"))
+	router.Fallback = legacy
+	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	testRoutes := []struct {
+		method string
+		route  string
+		code   int
+		body   string
+	}{
+		{http.MethodGet, "/new", http.StatusOK, ""},
+		{http.MethodGet, "/legacy/page", http.StatusOK, "legacy /legacy/page"},
+		{http.MethodPost, "/legacy/form", http.StatusOK, "legacy /legacy/form"},
+		{http.MethodGet, "/unknown", http.StatusNotFound, "404 page not found\n"}, // 404 of the fallback
+		{http.MethodPost, "/new", http.StatusMethodNotAllowed, "Method Not Allowed\n"},
+	}
+	for _, tr := range testRoutes {
+		r, _ := http.NewRequest(tr.method, tr.route, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if !(w.Code == tr.code && w.Body.String() == tr.body) {
+			t.Errorf("%s %s failed: Code=%d, Body=%q", tr.method, tr.route, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestRouterRedirectTrailingSlashMethods(t *testing.T) {
+	router := New()
+	router.RedirectTrailingSlashMethods = []string{http.MethodGet, http.MethodHead}
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		router.Handle(method, "/path", fakeHandler(method+" /path"))
+		router.Handle(method, "/dir/", fakeHandler(method+" /dir/"))
+	}
+
+	testRoutes := []struct {
+		method   string
+		route    string
+		code     int
+		location string
+	}{
+		{http.MethodGet, "/path/", http.StatusMovedPermanently, "/path"},
+		{http.MethodGet, "/dir", http.StatusMovedPermanently, "/dir/"},
+		{http.MethodPost, "/path/", http.StatusNotFound, ""},
+		{http.MethodPost, "/dir", http.StatusNotFound, ""},
+		{http.MethodPost, "/PATH/", http.StatusNotFound, ""}, // no slash fix by fixed path
+		{http.MethodPost, "/PATH", http.StatusPermanentRedirect, "/path"},
+	}
+	for _, tr := range testRoutes {
+		r, _ := http.NewRequest(tr.method, tr.route, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if !(w.Code == tr.code && w.Header().Get("Location") == tr.location) {
+			t.Errorf("%s %s failed: Code=%d, Location=%q", tr.method, tr.route, w.Code, w.Header().Get("Location"))
+		}
+	}
+
+	// serve in-place
+	router.ServeTrailingSlashInPlace = true
+	fakeHandlerValue = ""
+	r, _ := http.NewRequest(http.MethodPost, "/dir", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || fakeHandlerValue != "POST /dir/" {
+		t.Errorf("serving in-place failed: Code=%d, Handler=%q", w.Code, fakeHandlerValue)
+	}
+}
+
+func TestRouterMatchBareCatchAll(t *testing.T) {
+	router := New()
+	router.MatchBareCatchAll = true
+
+	var rest string
+	var routed bool
+	router.GET("/api/*rest", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		routed = true
+		rest = ps.ByName("rest")
+	})
+	router.GET("/res:*action", fakeHandler("/res:*action"))
+
+	router.MatchBareCatchAll = false
+	router.GET("/files/*filepath", fakeHandler("/files/*filepath"))
+
+	testRoutes := []struct {
+		route    string
+		code     int
+		location string
+		rest     string
+	}{
+		{"/api", http.StatusOK, "", ""},
+		{"/api/", http.StatusOK, "", "/"},
+		{"/api/x", http.StatusOK, "", "/x"},
+		{"/api/x/y", http.StatusOK, "", "/x/y"},
+		{"/res", http.StatusOK, "", ""},
+		{"/files", http.StatusMovedPermanently, "/files/", ""}, // registered without the option
+	}
+	for _, tr := range testRoutes {
+		routed, rest = false, ""
+		r, _ := http.NewRequest(http.MethodGet, tr.route, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if !(w.Code == tr.code && w.Header().Get("Location") == tr.location && rest == tr.rest) {
+			t.Errorf("routing %s failed: Code=%d, Location=%q, rest=%q, routed=%v", tr.route, w.Code, w.Header().Get("Location"), rest, routed)
+		}
+	}
+
+	// the bare path can't be registered separately
+	router.MatchBareCatchAll = true
+	recv := catchPanic(func() {
+		router.GET("/static", fakeHandler("/static"))
+		router.GET("/static/*filepath", fakeHandler("/static/*filepath"))
+	})
+	if recv == nil {
+		t.Error("no panic while registering a catch-all conflicting with its bare path")
+	}
+}
+
+func TestRouterMaxHeaderBytes(t *testing.T) {
+	router := New()
+	router.MaxHeaderBytes = 32
+	router.GET("/", fakeHandler("/"))
+
+	testHeaders := []struct {
+		header http.Header
+		code   int
+	}{
+		{http.Header{}, http.StatusOK},
+		{http.Header{"X-Small": {"value"}}, http.StatusOK},
+		{http.Header{"X-Exact": {strings.Repeat("v", 32-len("X-Exact"))}}, http.StatusOK},
+		{http.Header{"X-Large": {strings.Repeat("v", 32)}}, http.StatusRequestHeaderFieldsTooLarge},
+		{http.Header{"X-Multi": {"0123456789", "0123456789"}}, http.StatusRequestHeaderFieldsTooLarge},
+	}
+	for _, th := range testHeaders {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.Header = th.header
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != th.code {
+			t.Errorf("header %v: Code=%d, expected %d", th.header, w.Code, th.code)
+		}
+	}
+
+	// disabled by default
+	router.MaxHeaderBytes = 0
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Large", strings.Repeat("v", 1024))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected response without limit: Code=%d", w.Code)
+	}
+}
+
+func TestRouterQueryParam(t *testing.T) {
+	var params Params
+	router := New()
+	router.GET("/search/@index", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		params = append(Params(nil), ps...)
+	}).QueryParam("q").QueryParam("page")
+	router.GET("/all", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		params = append(Params(nil), ps...)
+	}).QueryParam("q")
+
+	testRoutes := []struct {
+		route  string
+		code   int
+		params Params
+	}{
+		{"/search/books?q=go&page=2", http.StatusOK, Params{{"index", "books"}, {"q", "go"}, {"page", "2"}}},
+		{"/search/books?page=1&q=go&q=rust", http.StatusOK, Params{{"index", "books"}, {"q", "go"}, {"page", "1"}}},
+		{"/search/books?q=&page=", http.StatusOK, Params{{"index", "books"}, {"q", ""}, {"page", ""}}},
+		{"/all?q=go", http.StatusOK, Params{{"q", "go"}}},
+		{"/search/books?q=go", http.StatusBadRequest, nil},
+		{"/all", http.StatusBadRequest, nil},
+	}
+	for _, tr := range testRoutes {
+		params = nil
+		r, _ := http.NewRequest(http.MethodGet, tr.route, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != tr.code || !reflect.DeepEqual(params, tr.params) {
+			t.Errorf("routing %s failed: Code=%d, Params=%v", tr.route, w.Code, params)
+		}
+	}
+
+	router.MissingQueryParam = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	})
+	r, _ := http.NewRequest(http.MethodGet, "/all", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("MissingQueryParam handler not called: Code=%d", w.Code)
+	}
+}
+
+func TestRouterValidate(t *testing.T) {
+	numeric := func(value string) error {
+		for _, c := range value {
+			if c < '0' || c > '9' {
+				return errors.New("not a number")
+			}
+		}
+		return nil
+	}
+	var called bool
+	router := New()
+	router.GET("/users/@id/posts/@slug", func(http.ResponseWriter, *http.Request, Params) {
+		called = true
+	}).QueryParam("page").Validate(map[string]func(string) error{
+		"id":   numeric,
+		"page": numeric,
+	})
+
+	testRoutes := []struct {
+		route string
+		code  int
+		body  string
+	}{
+		{"/users/42/posts/hello?page=1", http.StatusOK, ""},
+		{"/users/abc/posts/hello?page=1", http.StatusBadRequest, "invalid parameter 'id': not a number\n"},
+		{"/users/42/posts/hello?page=x", http.StatusBadRequest, "invalid parameter 'page': not a number\n"},
+	}
+	for _, tr := range testRoutes {
+		called = false
+		r, _ := http.NewRequest(http.MethodGet, tr.route, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != tr.code || w.Body.String() != tr.body || called != (tr.code == http.StatusOK) {
+			t.Errorf("routing %s failed: Code=%d, Body=%q, called=%v", tr.route, w.Code, w.Body.String(), called)
+		}
+	}
+
+	var name string
+	var err error
+	router.ValidationErrorHandler = func(w http.ResponseWriter, _ *http.Request, n string, e error) {
+		name, err = n, e
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	r, _ := http.NewRequest(http.MethodGet, "/users/abc/posts/hello?page=1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusUnprocessableEntity || name != "id" || err == nil {
+		t.Errorf("ValidationErrorHandler not called: Code=%d, name=%q, err=%v", w.Code, name, err)
+	}
+
+	// validators must belong to params of the route
+	rt := router.GET("/items/@id/@page?=1", fakeHandler("/items/@id/@page?=1"))
+	rt.Validate(map[string]func(string) error{"id": numeric, "page": numeric})
+	for _, n := range []string{"ID", "slug", "items"} {
+		recv := catchPanic(func() {
+			rt.Validate(map[string]func(string) error{n: numeric})
+		})
+		if recv == nil {
+			t.Errorf("no panic for validator of unknown param %q", n)
+		}
+	}
+}
+
+func TestRouterMidPathCatchAll(t *testing.T) {
+	var params Params
+	handle := func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		params = ps
+	}
+
+	router := New()
+	commits := router.GET("/repos/*path/commits", handle)
+	router.GET("/repos/*path/tree/@ref", handle)
+
+	tests := []struct {
+		route    string
+		code     int
+		params   Params
+		location string
+	}{
+		{"/repos/gopher/router/commits", http.StatusOK, Params{{"path", "/gopher/router"}}, ""},
+		{"/repos/gopher/router/tree/main", http.StatusOK, Params{{"path", "/gopher/router"}, {"ref", "main"}}, ""},
+		{"/repos/gopher/router", http.StatusNotFound, nil, ""},
+		{"/repos/gopher/router/commits/", http.StatusMovedPermanently, nil, "/repos/gopher/router/commits"},
+		{"/repos/gopher/router/COMMITS", http.StatusMovedPermanently, nil, "/repos/gopher/router/commits"},
+	}
+	for _, test := range tests {
+		params = nil
+		r, _ := http.NewRequest(http.MethodGet, test.route, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code || !reflect.DeepEqual(params, test.params) || w.Header().Get("Location") != test.location {
+			t.Errorf("routing %s failed: Code=%d, Params=%v, Location=%q", test.route, w.Code, params, w.Header().Get("Location"))
+		}
+	}
+
+	if u, err := commits.URL(Params{{"path", "gopher/router"}}); err != nil || u != "/repos/gopher/router/commits" {
+		t.Errorf("wrong URL: %q, %v", u, err)
+	}
+}
+
+func TestRouterParamAlternatives(t *testing.T) {
+	var route string
+	var params Params
+	handle := func(name string) Handle {
+		return func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+			route, params = name, ps
+		}
+	}
+
+	router := New()
+	router.GET("/feed/@format{rss|atom|json}", handle("feed"))
+	router.GET("/feed/@name", handle("named"))
+	router.POST("/feed/@format{rss|atom}", handle("post"))
+	router.GET("/feed/@format{rss|atom|json}/a", handle("feed a"))
+	router.GET("/feed/@name/b", handle("named b"))
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		route  string
+		params Params
+	}{
+		{http.MethodGet, "/feed/atom", http.StatusOK, "feed", Params{{"format", "atom"}}},
+		{http.MethodGet, "/feed/xml", http.StatusOK, "named", Params{{"name", "xml"}}},
+		{http.MethodGet, "/feed/ATOM", http.StatusOK, "named", Params{{"name", "ATOM"}}},
+		{http.MethodPost, "/feed/rss", http.StatusOK, "post", Params{{"format", "rss"}}},
+		{http.MethodPost, "/feed/json", http.StatusMethodNotAllowed, "", nil},
+
+		// routes diverging after the param
+		{http.MethodGet, "/feed/rss/a", http.StatusOK, "feed a", Params{{"format", "rss"}}},
+		{http.MethodGet, "/feed/rss/b", http.StatusOK, "named b", Params{{"name", "rss"}}},
+		{http.MethodGet, "/feed/xml/b", http.StatusOK, "named b", Params{{"name", "xml"}}},
+		{http.MethodGet, "/feed/xml/a", http.StatusNotFound, "", nil},
+	}
+	for _, test := range tests {
+		route, params = "", nil
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code || route != test.route || !reflect.DeepEqual(params, test.params) {
+			t.Errorf("routing %s %s failed: Code=%d, route=%q, Params=%v", test.method, test.path, w.Code, route, params)
+		}
+	}
+
+	for _, fold := range []bool{false, true} {
+		router.UnicodeCaseFold = fold
+		r, _ := http.NewRequest(http.MethodGet, "/FEED/RSS/B", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if location := w.Header().Get("Location"); w.Code != http.StatusMovedPermanently || location != "/feed/RSS/b" {
+			t.Errorf("fixing /FEED/RSS/B with UnicodeCaseFold=%v failed: Code=%d, Location=%q", fold, w.Code, location)
+		}
+	}
+}
+
+func TestRouterRegexpParams(t *testing.T) {
+	var params Params
+	handle := func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		params = ps
+	}
+
+	router := New()
+	user := router.GET("/users/@id([0-9]+)", handle)
+	router.GET("/articles/@id([0-9]+)/@slug([a-z-]+)?", handle)
+	router.GET("/files/@kind(a|b)/*path", handle)
+
+	tests := []struct {
+		route  string
+		code   int
+		params Params
+	}{
+		{"/users/42", http.StatusOK, Params{{"id", "42"}}},
+		{"/users/gopher", http.StatusNotFound, nil},
+		{"/articles/7", http.StatusOK, Params{{"id", "7"}}},
+		{"/articles/7/my-title", http.StatusOK, Params{{"id", "7"}, {"slug", "my-title"}}},
+		{"/articles/7/My_Title", http.StatusNotFound, nil},
+		{"/files/a/x/y", http.StatusOK, Params{{"kind", "a"}, {"path", "/x/y"}}},
+		{"/files/c/x/y", http.StatusNotFound, nil},
+	}
+	for _, test := range tests {
+		params = nil
+		r, _ := http.NewRequest(http.MethodGet, test.route, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code || !reflect.DeepEqual(params, test.params) {
+			t.Errorf("routing %s failed: Code=%d, Params=%v", test.route, w.Code, params)
+		}
+	}
+
+	if u, err := user.URL(Params{{"id", "42"}}); err != nil || u != "/users/42" {
+		t.Errorf("wrong URL: %q, %v", u, err)
+	}
+	if _, err := user.URL(Params{{"id", "gopher"}}); err == nil {
+		t.Error("no error for a value not matching the regular expression")
+	}
+}
+
+func TestRouterTypedParams(t *testing.T) {
+	var params Params
+	handle := func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		params = ps
+	}
+
+	router := New()
+	order := router.GET("/orders/@id<int>", handle)
+	router.GET("/files/@key<uuid>", handle)
+	router.GET("/pages/@page<uint>?=1", handle)
+
+	tests := []struct {
+		route  string
+		code   int
+		params Params
+	}{
+		{"/orders/42", http.StatusOK, Params{{"id", "42"}}},
+		{"/orders/forty-two", http.StatusNotFound, nil},
+		{"/files/123e4567-e89b-12d3-a456-426614174000", http.StatusOK, Params{{"key", "123e4567-e89b-12d3-a456-426614174000"}}},
+		{"/files/readme", http.StatusNotFound, nil},
+		{"/pages", http.StatusOK, Params{{"page", "1"}}},
+		{"/pages/3", http.StatusOK, Params{{"page", "3"}}},
+		{"/pages/last", http.StatusNotFound, nil},
+	}
+	for _, test := range tests {
+		params = nil
+		r, _ := http.NewRequest(http.MethodGet, test.route, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code || !reflect.DeepEqual(params, test.params) {
+			t.Errorf("routing %s failed: Code=%d, Params=%v", test.route, w.Code, params)
+		}
+	}
+
+	if u, err := order.URL(Params{{"id", "42"}}); err != nil || u != "/orders/42" {
+		t.Errorf("wrong URL: %q, %v", u, err)
+	}
+	if _, err := order.URL(Params{{"id", "forty-two"}}); err == nil {
+		t.Error("no error for a value not matching the type")
+	}
+}
+
+func TestRouterHeaderTimeout(t *testing.T) {
+	var timeout time.Duration
+	var hasDeadline bool
+	router := New()
+	router.HeaderTimeout = "X-Request-Timeout"
+	router.GET("/", func(_ http.ResponseWriter, req *http.Request, _ Params) {
+		var deadline time.Time
+		deadline, hasDeadline = req.Context().Deadline()
+		timeout = deadline.Sub(time.Now())
+	})
+
+	testHeaders := []struct {
+		value       string
+		hasDeadline bool
+		timeout     time.Duration
+	}{
+		{"5s", true, 5 * time.Second},
+		{"1m30s", true, 90 * time.Second},
+		{"", false, 0},
+		{"5", false, 0},
+		{"soon", false, 0},
+		{"-5s", false, 0},
+	}
+	for _, th := range testHeaders {
+		hasDeadline = false
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		if th.value != "" {
+			r.Header.Set("X-Request-Timeout", th.value)
+		}
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		if hasDeadline != th.hasDeadline {
+			t.Errorf("header %q: unexpected deadline %v", th.value, hasDeadline)
+		}
+		if th.hasDeadline && (timeout > th.timeout || timeout < th.timeout-time.Second) {
+			t.Errorf("header %q: wrong timeout %v", th.value, timeout)
+		}
+	}
+}
+
+func TestRouterGone(t *testing.T) {
+	router := New()
+	router.GET("/v2/users", fakeHandler("/v2/users"))
+	router.Gone(http.MethodGet, "/v1/users")
+	router.Gone(http.MethodPost, "/v1/users", "use /v2/users instead")
+
+	testRoutes := []struct {
+		method string
+		route  string
+		code   int
+		body   string
+		allow  string
+	}{
+		{http.MethodGet, "/v1/users", http.StatusGone, "Gone\n", ""},
+		{http.MethodPost, "/v1/users", http.StatusGone, "use /v2/users instead\n", ""},
+		{http.MethodPut, "/v1/users", http.StatusMethodNotAllowed, "Method Not Allowed\n", "GET, OPTIONS, POST"},
+		{http.MethodOptions, "/v1/users", http.StatusOK, "", "GET, OPTIONS, POST"},
+	}
+	for _, tr := range testRoutes {
+		r, _ := http.NewRequest(tr.method, tr.route, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if !(w.Code == tr.code && w.Body.String() == tr.body && w.Header().Get("Allow") == tr.allow) {
+			t.Errorf("%s %s failed: Code=%d, Body=%q, Allow=%q", tr.method, tr.route, w.Code, w.Body.String(), w.Header().Get("Allow"))
+		}
+	}
+
+	var routes []string
+	for _, rt := range router.Routes() {
+		routes = append(routes, rt.Method()+" "+rt.Path())
+	}
+	if expected := []string{"GET /v2/users", "GET /v1/users", "POST /v1/users"}; !reflect.DeepEqual(routes, expected) {
+		t.Errorf("wrong routes: %v", routes)
+	}
+}
+
+func TestRouterStatus(t *testing.T) {
+	router := New()
+	router.Status(http.MethodGet, "/healthz", http.StatusOK, "ok")
+	router.Status(http.MethodPost, "/hook", http.StatusAccepted, "")
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		body   string
+		ct     string
+	}{
+		{http.MethodGet, "/healthz", http.StatusOK, "ok", "text/plain; charset=utf-8"},
+		{http.MethodPost, "/hook", http.StatusAccepted, "", ""},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("%s %s: Code=%d, body=%q, want %d, %q", test.method, test.path, w.Code, w.Body.String(), test.code, test.body)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != test.ct {
+			t.Errorf("%s %s: unexpected Content-Type %q", test.method, test.path, ct)
+		}
+	}
+
+	routes := router.Routes()
+	if len(routes) != 2 || routes[0].Path() != "/healthz" || routes[1].Method() != http.MethodPost {
+		t.Errorf("status routes are not listed by Routes: %v", routes)
+	}
+}
+
+func TestRouterDisableAutoOptions(t *testing.T) {
+	router := New()
+	router.GET("/internal", fakeHandler("/internal"))
+	router.GET("/internal/status", fakeHandler("/internal/status"))
+	router.GET("/internalx", fakeHandler("/internalx"))
+	router.GET("/public", fakeHandler("/public"))
+	router.OPTIONS("/internal/custom", fakeHandler("OPTIONS /internal/custom"))
+	router.DisableAutoOptions("/internal")
+
+	testRoutes := []struct {
+		route string
+		code  int
+		allow string
+	}{
+		{"/internal", http.StatusNotFound, ""},
+		{"/internal/status", http.StatusNotFound, ""},
+		{"/internal/custom", http.StatusOK, ""}, // registered handle
+		{"/internalx", http.StatusOK, "GET, OPTIONS"},
+		{"/public", http.StatusOK, "GET, OPTIONS"},
+	}
+	for _, tr := range testRoutes {
+		r, _ := http.NewRequest(http.MethodOptions, tr.route, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if !(w.Code == tr.code && w.Header().Get("Allow") == tr.allow) {
+			t.Errorf("OPTIONS %s failed: Code=%d, Allow=%q", tr.route, w.Code, w.Header().Get("Allow"))
+		}
+	}
+
+	// the empty prefix disables automatic replies for all paths
+	router.DisableAutoOptions("")
+	for _, path := range []string{"/internalx", "/public"} {
+		r, _ := http.NewRequest(http.MethodOptions, path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("OPTIONS %s with empty prefix failed: Code=%d", path, w.Code)
+		}
+	}
+}
+
+func TestRouterCanonicalRedirect(t *testing.T) {
+	router := New()
+	router.RedirectTrailingSlash = false
+	router.RedirectFixedPath = false
+	router.CanonicalRedirect = true
+	router.DebugRedirects = true
+	router.GET("/foo", fakeHandler("/foo"))
+	router.GET("/bar/", fakeHandler("/bar/"))
+
+	testRoutes := []struct {
+		route    string
+		code     int
+		location string
+	}{
+		{"/FOO/", http.StatusMovedPermanently, "/foo"},
+		{"/foo/", http.StatusMovedPermanently, "/foo"},
+		{"/Foo", http.StatusMovedPermanently, "/foo"},
+		{"/x/../FOO/", http.StatusMovedPermanently, "/foo"},
+		{"/BAR", http.StatusMovedPermanently, "/bar/"},
+		{"/foo", http.StatusOK, ""},
+		{"/baz", http.StatusNotFound, ""},
+	}
+	for _, tr := range testRoutes {
+		r, _ := http.NewRequest(http.MethodGet, tr.route, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if !(w.Code == tr.code && w.Header().Get("Location") == tr.location) {
+			t.Errorf("routing %s failed: Code=%d, Location=%q", tr.route, w.Code, w.Header().Get("Location"))
+			continue
+		}
+		if tr.location == "" {
+			continue
+		}
+		if reason := w.Header().Get("X-Redirect-Reason"); reason != "canonical" {
+			t.Errorf("routing %s: wrong redirect reason %q", tr.route, reason)
+		}
+
+		// the target is served without another redirect
+		r, _ = http.NewRequest(http.MethodGet, tr.location, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("routing redirect target %s failed: Code=%d", tr.location, w.Code)
+		}
+	}
+}
+
+func TestRouteMatchBarePrefix(t *testing.T) {
+	var value string
+	var routed bool
+	handle := func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		routed = true
+		value = ps.ByName("path")
+	}
+
+	router := New()
+	router.GET("/assets/*path", handle).MatchBarePrefix()
+	router.GET("/docs/*path", handle).MatchBarePrefixValue("/")
+	router.GET("/files/*path", handle)
+
+	testRoutes := []struct {
+		route    string
+		code     int
+		location string
+		value    string
+	}{
+		{"/assets", http.StatusOK, "", ""},
+		{"/assets/", http.StatusOK, "", "/"},
+		{"/assets/x/y", http.StatusOK, "", "/x/y"},
+		{"/docs", http.StatusOK, "", "/"},
+		{"/docs/", http.StatusOK, "", "/"},
+		{"/files", http.StatusMovedPermanently, "/files/", ""},
+	}
+	for _, tr := range testRoutes {
+		routed, value = false, ""
+		r, _ := http.NewRequest(http.MethodGet, tr.route, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if !(w.Code == tr.code && w.Header().Get("Location") == tr.location && value == tr.value) {
+			t.Errorf("routing %s failed: Code=%d, Location=%q, path=%q, routed=%v", tr.route, w.Code, w.Header().Get("Location"), value, routed)
+		}
+	}
+
+	// invalid and conflicting routes panic and leave the router unchanged
+	rt := router.GET("/static/*path", handle)
+	router.GET("/static", fakeHandler("/static"))
+	if recv := catchPanic(func() { rt.MatchBarePrefix() }); recv == nil {
+		t.Error("no panic for a bare path conflicting with an existing route")
+	}
+	if recv := catchPanic(func() { router.GET("/plain", handle).MatchBarePrefix() }); recv == nil {
+		t.Error("no panic for a route without catch-all")
+	}
+	for _, path := range []string{"/static", "/static/x", "/assets"} {
+		if handle, _, _ := router.Lookup(http.MethodGet, path); handle == nil {
+			t.Errorf("route %s lost after a failed MatchBarePrefix", path)
+		}
+	}
+}
+
+func TestRouterSaveRestore(t *testing.T) {
+	router := New()
+	users := router.GET("/users", fakeHandler("/users"))
+	items := router.GET("/items/@id", fakeHandler("/items/@id"))
+
+	state := router.Save()
+
+	router.GET("/users/@id", fakeHandler("/users/@id"))
+	router.POST("/users", fakeHandler("POST /users"))
+	users.MaxBody(10)
+	items.Remove()
+
+	router.Restore(state)
+
+	for _, path := range []string{"/users", "/items/1"} {
+		if handle, _, _ := router.Lookup(http.MethodGet, path); handle == nil {
+			t.Errorf("route GET %s not restored", path)
+		}
+	}
+	if handle, _, _ := router.Lookup(http.MethodGet, "/users/1"); handle != nil {
+		t.Error("route GET /users/@id registered after Save not removed")
+	}
+	if _, ok := router.trees[http.MethodPost]; ok {
+		t.Error("route POST /users registered after Save not removed")
+	}
+	if users.hasMaxBody {
+		t.Error("route option changed after Save not reset")
+	}
+	if len(router.Routes()) != 2 {
+		t.Errorf("wrong number of routes after restore: %d", len(router.Routes()))
+	}
+
+	// restored routes can still be changed by their handles
+	items.Remove()
+	if handle, _, _ := router.Lookup(http.MethodGet, "/items/1"); handle != nil {
+		t.Error("restored route not removed")
+	}
+
+	// allowed methods are restored, too
+	router.Restore(router.Save())
+	r, _ := http.NewRequest(http.MethodOptions, "*", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if allow := w.Header().Get("Allow"); allow != "GET, OPTIONS" {
+		t.Errorf("wrong global Allow header after restore: %q", allow)
+	}
+}
+
+func TestRouterMethodAny(t *testing.T) {
+	router := New()
+	router.Handle("QUERY", "/rpc", fakeHandler("QUERY /rpc"))
+	router.Any("/rpc", fakeHandler("ANY /rpc"))
+	router.Any("/rpc/@op", fakeHandler("ANY /rpc/@op"))
+	router.GET("/rpc/status", fakeHandler("GET /rpc/status"))
+	router.Any("/status", fakeHandler("ANY /status"))
+	router.GET("/status", fakeHandler("GET /status"))
+
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{"QUERY", "/rpc", "QUERY /rpc"},
+		{http.MethodGet, "/rpc", "ANY /rpc"},
+		{http.MethodPost, "/rpc", "ANY /rpc"},
+		{"PURGE", "/rpc", "ANY /rpc"},
+		{http.MethodGet, "/rpc/status", "GET /rpc/status"},
+		{http.MethodGet, "/rpc/call", "ANY /rpc/@op"},
+		{http.MethodPost, "/rpc/status", "ANY /rpc/@op"},
+		{http.MethodGet, "/status", "GET /status"},
+		{http.MethodDelete, "/status", "ANY /status"},
+		{http.MethodOptions, "/status", "ANY /status"},
+	}
+	for _, test := range tests {
+		fakeHandlerValue = ""
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if fakeHandlerValue != test.want {
+			t.Errorf("routing %s %s failed: got %q, want %q", test.method, test.path, fakeHandlerValue, test.want)
+		}
+
+		handle, _, _ := router.Lookup(test.method, test.path)
+		if handle == nil {
+			t.Errorf("lookup of %s %s failed", test.method, test.path)
+		}
+	}
+
+	// MethodAny is not listed as an allowed method
+	r, _ := http.NewRequest(http.MethodOptions, "*", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if allow := w.Header().Get("Allow"); allow != "GET, OPTIONS, QUERY" {
+		t.Errorf("wrong Allow header: %q", allow)
+	}
+
+	// unmatched paths are still not found
+	r, _ = http.NewRequest(http.MethodPut, "/other", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("wrong status for unmatched path: %d", w.Code)
+	}
+}
+
+func TestRouteURL(t *testing.T) {
+	tests := []struct {
+		path string
+		ps   Params
+		want string
+		err  bool
+	}{
+		{"/users", nil, "/users", false},
+		{"/users/@id", Params{{"id", "42"}}, "/users/42", false},
+		{"/users/@id/posts/@post", Params{{"post", "7"}, {"id", "42"}}, "/users/42/posts/7", false},
+		{"/users/@id", nil, "", true},
+		{"/users/@id", Params{{"id", "a/b"}}, "", true},
+		{"/names/@name", Params{{"name", "a b"}}, "/names/a%20b", false},
+		{"/files/@name.json", Params{{"name", "data"}}, "/files/data.json", false},
+		{"/src/*filepath", Params{{"filepath", "/dir/file.go"}}, "/src/dir/file.go", false},
+		{"/issues/@state{open|closed}", Params{{"state", "open"}}, "/issues/open", false},
+		{"/tickets/@state{open|closed}", Params{{"state", "all"}}, "", true},
+		{"/items/@page?=1", Params{{"page", "3"}}, "/items/3", false},
+		{"/things/@page?", nil, "/things", false},
+		{"/orders/@id([0-9]+)", Params{{"id", "12"}}, "/orders/12", false},
+		{"/orders/@id([0-9]+)", Params{{"id", "x"}}, "", true},
+		{"/pages/@page<int>?", Params{{"page", "x"}}, "", true},
+	}
+	for _, test := range tests {
+		rt := New().GET(test.path, fakeHandler(test.path))
+		u, err := rt.URL(test.ps)
+		if test.err {
+			if err == nil {
+				t.Errorf("URL of %s with %v: expected error, got %q", test.path, test.ps, u)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("URL of %s with %v: unexpected error: %v", test.path, test.ps, err)
+		} else if u != test.want {
+			t.Errorf("URL of %s with %v: got %q, want %q", test.path, test.ps, u, test.want)
+		}
+	}
+}
+
+func BenchmarkRouteURL(b *testing.B) {
+	rt := New().GET("/users/@id([0-9]+)/posts/@slug{draft|published}", fakeHandler("post"))
+	ps := Params{{"id", "42"}, {"slug", "draft"}}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := rt.URL(ps); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestRouteNamed(t *testing.T) {
+	router := New()
+	rt := router.GET("/users/@id", fakeHandler("/users/@id")).Named("user")
+	if router.NamedRoute("user") != rt {
+		t.Error("named route not found")
+	}
+	if router.NamedRoute("other") != nil {
+		t.Error("unexpected route for unknown name")
+	}
+	rt.Named("user") // renaming to its own name is fine
+
+	recv := catchPanic(func() {
+		router.GET("/people/@id", fakeHandler("/people/@id")).Named("user")
+	})
+	if recv == nil {
+		t.Error("no panic for duplicate route name")
+	}
+
+	if _, err := router.Add(RouteSpec{
+		Method: http.MethodGet,
+		Path:   "/members/@id",
+		Name:   "user",
+		Handle: fakeHandler("/members/@id"),
+	}); err == nil {
+		t.Error("no error for duplicate route name")
+	}
+	if handle, _, _ := router.Lookup(http.MethodGet, "/members/1"); handle != nil {
+		t.Error("route with duplicate name registered")
+	}
+
+	// renamed and removed routes are not found by their old names
+	rt.Named("member")
+	if router.NamedRoute("user") != nil || router.NamedRoute("member") != rt {
+		t.Error("renamed route not found by its new name only")
+	}
+	rt.Remove()
+	if router.NamedRoute("member") != nil {
+		t.Error("removed route found by its name")
+	}
+}
+
+func TestRouteLink(t *testing.T) {
+	router := New()
+	router.GET("/users/@id/posts", fakeHandler("posts")).Named("user-posts")
+	router.GET("/users/@id/friends/@page?", fakeHandler("friends")).Named("user-friends")
+	router.GET("/posts/@post", fakeHandler("post")).Named("post")
+	router.GET("/users/@id", fakeHandler("user")).
+		Link("posts", "user-posts").
+		Link("friends", "user-friends").
+		Link("post", "post").
+		Link("missing", "unknown")
+
+	r, _ := http.NewRequest(http.MethodGet, "/users/42", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if fakeHandlerValue != "user" {
+		t.Fatalf("routing failed: %q", fakeHandlerValue)
+	}
+
+	want := []string{
+		`</users/42/posts>; rel="posts"`,
+		`</users/42/friends>; rel="friends"`,
+	}
+	if got := w.Header()["Link"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong Link headers:\n got: %q\nwant: %q", got, want)
+	}
+}
+
+func TestRouterStrictNoRedirect(t *testing.T) {
+	router := New()
+	router.RedirectFixedPath = true
+	router.CanonicalRedirect = true
+	router.StrictNoRedirect = true
+	router.GET("/foo", fakeHandler("/foo"))
+	router.GET("/bar/", fakeHandler("/bar/"))
+	router.POST("/baz", fakeHandler("/baz"))
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		body   string
+	}{
+		{http.MethodGet, "/foo", http.StatusOK, ""},
+		{http.MethodGet, "/foo/", http.StatusNotFound, `{"error":"not_found","suggestion":"/foo"}`},
+		{http.MethodGet, "/bar", http.StatusNotFound, `{"error":"not_found","suggestion":"/bar/"}`},
+		{http.MethodGet, "/FOO", http.StatusNotFound, `{"error":"not_found","suggestion":"/foo"}`},
+		{http.MethodGet, "/FOO/", http.StatusNotFound, `{"error":"not_found","suggestion":"/foo"}`},
+		{http.MethodGet, "/x/../foo", http.StatusNotFound, `{"error":"not_found","suggestion":"/foo"}`},
+		{http.MethodPost, "/baz/", http.StatusNotFound, `{"error":"not_found","suggestion":"/baz"}`},
+		{http.MethodGet, "/nothing", http.StatusNotFound, "404 page not found\n"},
+		{http.MethodGet, "/baz", http.StatusMethodNotAllowed, "Method Not Allowed\n"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("routing %s %s failed: Code=%d, want %d", test.method, test.path, w.Code, test.code)
+		}
+		if test.body != "" && w.Body.String() != test.body {
+			t.Errorf("routing %s %s failed: Body=%q, want %q", test.method, test.path, w.Body.String(), test.body)
+		}
+		if strings.HasPrefix(test.body, "{") {
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("routing %s %s failed: Content-Type=%q", test.method, test.path, ct)
+			}
+		}
+	}
+}
+
+func TestRouterAllowEmptySegments(t *testing.T) {
+	var x string
+	handle := func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		x = ps.ByName("x")
+	}
+
+	for _, allow := range []bool{false, true} {
+		router := New()
+		router.AllowEmptySegments = allow
+		router.GET("/a/@x/b", handle)
+		router.GET("/c/@x", handle)
+
+		tests := []struct {
+			path string
+			code int
+			x    string
+		}{
+			{"/a/1/b", http.StatusOK, "1"},
+			{"/a//b", http.StatusOK, ""},
+			{"/c/1", http.StatusOK, "1"},
+			{"/c/", http.StatusNotFound, ""},
+		}
+		for _, test := range tests {
+			code := test.code
+			if allow {
+				code = http.StatusOK
+			}
+
+			x = "unset"
+			r, _ := http.NewRequest(http.MethodGet, test.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			if w.Code != code {
+				t.Errorf("AllowEmptySegments=%v: routing %s failed: Code=%d, want %d", allow, test.path, w.Code, code)
+			}
+			if code == http.StatusOK && x != test.x {
+				t.Errorf("AllowEmptySegments=%v: routing %s failed: x=%q, want %q", allow, test.path, x, test.x)
+			}
+		}
+	}
+}
+
+func TestRouterRedirectHooks(t *testing.T) {
+	type event struct {
+		method, target string
+		code           int
+	}
+	var redirects, suppressed []event
+
+	router := New()
+	router.OnRedirect = func(method, target string, code int) {
+		redirects = append(redirects, event{method, target, code})
+	}
+	router.OnRedirectSuppressed = func(method, target string) {
+		suppressed = append(suppressed, event{method, target, 0})
+	}
+	router.GET("/items", fakeHandler("GET /items"))
+	router.POST("/items/", fakeHandler("POST /items/"))
+	router.PUT("/things/", fakeHandler("PUT /things/"))
+
+	serve := func(method, path string) {
+		r, _ := http.NewRequest(method, path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	serve(http.MethodGet, "/items/")
+	serve(http.MethodPost, "/items")
+	serve(http.MethodGet, "/items")
+
+	want := []event{
+		{http.MethodGet, "/items", http.StatusMovedPermanently},
+		{http.MethodPost, "/items/", http.StatusPermanentRedirect},
+	}
+	if !reflect.DeepEqual(redirects, want) {
+		t.Errorf("wrong redirects:\n got: %v\nwant: %v", redirects, want)
+	}
+	if len(suppressed) != 0 {
+		t.Errorf("unexpected suppressed redirects: %v", suppressed)
+	}
+
+	// suppressed by method
+	redirects = nil
+	router.RedirectTrailingSlashMethods = []string{http.MethodGet, http.MethodHead}
+	serve(http.MethodPut, "/things")
+	if len(redirects) != 0 {
+		t.Errorf("unexpected redirects: %v", redirects)
+	}
+	if want := []event{{http.MethodPut, "/things/", 0}}; !reflect.DeepEqual(suppressed, want) {
+		t.Errorf("wrong suppressed redirects:\n got: %v\nwant: %v", suppressed, want)
+	}
+}
+
+func TestRouteRequireBody(t *testing.T) {
+	router := New()
+	router.POST("/things", fakeHandler("create")).RequireBody()
+	router.POST("/things", fakeHandler("trigger")).NoBody()
+	router.POST("/hooks", fakeHandler("hook")).RequireBody()
+	router.POST("/events", fakeHandler("event")).RequireBody()
+	router.POST("/events", fakeHandler("fallback"))
+
+	tests := []struct {
+		path string
+		body string
+		code int
+		want string
+	}{
+		{"/things", `{"name":"gopher"}`, http.StatusOK, "create"},
+		{"/things", "", http.StatusOK, "trigger"},
+		{"/hooks", "payload", http.StatusOK, "hook"},
+		{"/hooks", "", http.StatusNotFound, ""},
+		{"/events", "payload", http.StatusOK, "event"},
+		{"/events", "", http.StatusOK, "fallback"},
+	}
+	for _, test := range tests {
+		var body io.Reader
+		if test.body != "" {
+			body = strings.NewReader(test.body)
+		}
+		fakeHandlerValue = ""
+		r, _ := http.NewRequest(http.MethodPost, test.path, body)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code || fakeHandlerValue != test.want {
+			t.Errorf("routing %s with body %q failed: Code=%d, handler=%q, want %d, %q",
+				test.path, test.body, w.Code, fakeHandlerValue, test.code, test.want)
+		}
+	}
+
+	// routes without guards still conflict
+	recv := catchPanic(func() {
+		router.POST("/events", fakeHandler("conflict"))
+	})
+	if recv == nil {
+		t.Error("no panic for route registered after a route without guards")
+	}
+
+	// removing a guarded route keeps the others
+	router.Routes()[0].Remove()
+	r, _ := http.NewRequest(http.MethodPost, "/things", nil)
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	if fakeHandlerValue != "trigger" {
+		t.Errorf("wrong handler after removal: %q", fakeHandlerValue)
+	}
+}
+
+func TestRouterStaticURLs(t *testing.T) {
+	router := New()
+	router.GET("/", fakeHandler("/")).Named("home")
+	router.GET("/about", fakeHandler("/about")).Named("about")
+	router.GET("/unnamed", fakeHandler("/unnamed"))
+	router.GET("/users/@id", fakeHandler("/users/@id")).Named("user")
+	router.GET("/files/*path", fakeHandler("/files/*path")).Named("files")
+	router.GET("/items/@page?", fakeHandler("/items/@page?")).Named("items")
+	router.POST("/v1/things:batch", fakeHandler("/v1/things:batch")).Named("batch")
+
+	want := map[string]string{
+		"home":  "/",
+		"about": "/about",
+		"batch": "/v1/things:batch",
+	}
+	if got := router.StaticURLs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong static URLs:\n got: %v\nwant: %v", got, want)
+	}
+}
+
+func TestRouterRequireAccept(t *testing.T) {
+	router := New()
+	router.RequireAccept = "application/json"
+	router.GET("/api", fakeHandler("/api"))
+	router.GET("/files/*path", fakeHandler("/files/*path")).AnyAccept()
+
+	tests := []struct {
+		path   string
+		accept string
+		code   int
+	}{
+		{"/api", "", http.StatusOK},
+		{"/api", "application/json", http.StatusOK},
+		{"/api", "text/html, application/json;q=0.9", http.StatusOK},
+		{"/api", "Application/JSON", http.StatusOK},
+		{"/api", "*/*", http.StatusOK},
+		{"/api", "application/*", http.StatusOK},
+		{"/api", "text/html", http.StatusNotAcceptable},
+		{"/api", "text/*, image/png", http.StatusNotAcceptable},
+		{"/api", "application/json;q=0", http.StatusNotAcceptable},
+		{"/api", "application/json;q=0, */*", http.StatusNotAcceptable},
+		{"/api", "*/*, application/*;q=0", http.StatusNotAcceptable},
+		{"/api", "application/*;q=0, application/json", http.StatusOK},
+		{"/api", "*/*;q=0, application/*", http.StatusOK},
+		{"/api", "application/xml", http.StatusNotAcceptable},
+		{"/files/logo.png", "image/png", http.StatusOK},
+		{"/nothing", "text/html", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(http.MethodGet, test.path, nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("routing %s with Accept %q failed: Code=%d, want %d", test.path, test.accept, w.Code, test.code)
+		}
+	}
+}
+
+func TestRouterTrailingSlashVariants(t *testing.T) {
+	type result struct {
+		code     int
+		location string
+		handler  string
+	}
+	served := func(handler string) result { return result{http.StatusOK, "", handler} }
+	moved := func(location string) result { return result{http.StatusMovedPermanently, location, ""} }
+	notFound := result{http.StatusNotFound, "", ""}
+
+	tests := []struct {
+		routes     []string
+		noSlash    result
+		withSlash  result
+		reqNoSlash string
+	}{
+		// static paths
+		{nil, notFound, notFound, "/foo"},
+		{[]string{"/foo"}, served("/foo"), moved("/foo"), "/foo"},
+		{[]string{"/foo/"}, moved("/foo/"), served("/foo/"), "/foo"},
+		{[]string{"/foo", "/foo/"}, served("/foo"), served("/foo/"), "/foo"},
+		{[]string{"/foo/", "/foo"}, served("/foo"), served("/foo/"), "/foo"},
+
+		// paths ending in a param
+		{[]string{"/p/@id"}, served("/p/@id"), moved("/p/1"), "/p/1"},
+		{[]string{"/p/@id/"}, moved("/p/1/"), served("/p/@id/"), "/p/1"},
+		{[]string{"/p/@id", "/p/@id/"}, served("/p/@id"), served("/p/@id/"), "/p/1"},
+	}
+	for _, canonical := range []bool{false, true} {
+		for _, test := range tests {
+			router := New()
+			router.RedirectFixedPath = true
+			router.CanonicalRedirect = canonical
+			for _, route := range test.routes {
+				router.GET(route, fakeHandler(route))
+			}
+
+			for _, req := range []struct {
+				path string
+				want result
+			}{
+				{test.reqNoSlash, test.noSlash},
+				{test.reqNoSlash + "/", test.withSlash},
+			} {
+				fakeHandlerValue = ""
+				r, _ := http.NewRequest(http.MethodGet, req.path, nil)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, r)
+				got := result{w.Code, w.Header().Get("Location"), fakeHandlerValue}
+				if got != req.want {
+					t.Errorf("routes %v, CanonicalRedirect=%v: routing %s failed: got %+v, want %+v",
+						test.routes, canonical, req.path, got, req.want)
+				}
+			}
+		}
+	}
+}
+
+type wrappedError struct {
+	msg string
+	err error
+}
+
+func (e wrappedError) Error() string { return e.msg + ": " + e.err.Error() }
+func (e wrappedError) Unwrap() error { return e.err }
+
+func TestRouterHandleErrorSkip(t *testing.T) {
+	proxy := func(w http.ResponseWriter, req *http.Request, ps Params) error {
+		switch ps.ByName("rest") {
+		case "/missing":
+			return ErrSkip
+		case "/moved":
+			return wrappedError{"lookup failed", ErrSkip}
+		case "/broken":
+			return errors.New("upstream failed")
+		}
+		w.Write([]byte("proxy"))
+		return nil
+	}
+
+	router := New()
+	router.HandleError(http.MethodGet, "/api/*rest", proxy)
+	router.GET("/api/*rest", func(w http.ResponseWriter, _ *http.Request, _ Params) {
+		w.Write([]byte("local"))
+	})
+	router.HandleError(http.MethodGet, "/files/*rest", proxy)
+	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("not found"))
+	})
+
+	var applied int
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			applied++
+			next.ServeHTTP(w, req)
+		})
+	})
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/api/users", http.StatusOK, "proxy"},
+		{"/api/missing", http.StatusOK, "local"},
+		{"/api/moved", http.StatusOK, "local"},
+		{"/api/broken", http.StatusInternalServerError, "Internal Server Error\n"},
+		{"/files/x", http.StatusOK, "proxy"},
+		{"/files/missing", http.StatusNotFound, "not found"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(http.MethodGet, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("routing %s failed: Code=%d, Body=%q, want %d, %q",
+				test.path, w.Code, w.Body.String(), test.code, test.body)
+		}
+	}
+
+	// the middleware runs for each route trying the request
+	if applied != 8 {
+		t.Errorf("middleware applied %d times, want 8", applied)
+	}
+
+	// routes after a handle which can skip don't conflict
+	recv := catchPanic(func() {
+		router.GET("/files/*rest", fakeHandler("/files/*rest"))
+	})
+	if recv != nil {
+		t.Errorf("unexpected panic: %v", recv)
+	}
+}
+
+func TestRouterCapabilities(t *testing.T) {
+	router := New()
+	router.GET("/users/@id", fakeHandler("GET"))
+	router.PUT("/users/@id/@version?", fakeHandler("PUT"))
+	router.DELETE("/users/*path", fakeHandler("DELETE"))
+	router.POST("/users", fakeHandler("POST"))
+	router.Any("/users/@user", fakeHandler("ANY"))
+
+	want := map[string][]string{
+		http.MethodGet:    {"id"},
+		http.MethodPut:    {"id"},
+		http.MethodDelete: {"path"},
+		MethodAny:         {"user"},
+	}
+	if got := router.Capabilities("/users/42"); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong capabilities for /users/42:\n got: %v\nwant: %v", got, want)
+	}
+
+	want = map[string][]string{
+		http.MethodPut:    {"id", "version"},
+		http.MethodDelete: {"path"},
+	}
+	if got := router.Capabilities("/users/42/3"); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong capabilities for /users/42/3:\n got: %v\nwant: %v", got, want)
+	}
+
+	want = map[string][]string{
+		http.MethodPost: {},
+	}
+	if got := router.Capabilities("/users"); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong capabilities for /users:\n got: %v\nwant: %v", got, want)
+	}
+
+	if got := router.Capabilities("/nothing"); len(got) != 0 {
+		t.Errorf("unexpected capabilities for /nothing: %v", got)
+	}
+}
+
+func TestRouterServerWidePath(t *testing.T) {
+	router := New()
+	router.GET("/path", fakeHandler("GET /path"))
+	router.POST("/path", fakeHandler("POST /path"))
+	router.Any("/*all", fakeHandler("ANY /*all"))
+
+	r, _ := http.NewRequest(http.MethodOptions, "*", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("OPTIONS * failed: Code=%d", w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "GET, OPTIONS, POST" {
+		t.Errorf("OPTIONS * failed: wrong Allow header %q", allow)
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodDelete, "PURGE"} {
+		fakeHandlerValue = ""
+		r, _ := http.NewRequest(method, "*", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s * failed: Code=%d, want %d", method, w.Code, http.StatusBadRequest)
+		}
+		if fakeHandlerValue != "" {
+			t.Errorf("%s * served by %q", method, fakeHandlerValue)
+		}
+	}
+}
+
+func TestRouterRedirectVeto(t *testing.T) {
+	router := New()
+	router.RedirectFixedPath = true
+	router.GET("/strict", fakeHandler("/strict")).NoRedirect()
+	router.GET("/loose", fakeHandler("/loose"))
+	router.GET("/admin/", fakeHandler("/admin/"))
+	router.RedirectPolicy = func(req *http.Request, target string) bool {
+		return !strings.HasPrefix(target, "/admin")
+	}
+
+	tests := []struct {
+		path     string
+		code     int
+		location string
+	}{
+		{"/strict", http.StatusOK, ""},
+		{"/strict/", http.StatusNotFound, ""},
+		{"/STRICT", http.StatusNotFound, ""},
+		{"/loose/", http.StatusMovedPermanently, "/loose"},
+		{"/LOOSE", http.StatusMovedPermanently, "/loose"},
+		{"/admin/", http.StatusOK, ""},
+		{"/admin", http.StatusNotFound, ""},
+		{"/ADMIN/", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(http.MethodGet, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code || w.Header().Get("Location") != test.location {
+			t.Errorf("routing %s failed: Code=%d, Location=%q, want %d, %q",
+				test.path, w.Code, w.Header().Get("Location"), test.code, test.location)
+		}
+	}
+}
+
+func TestRouteRequireHeader(t *testing.T) {
+	router := New()
+	router.GET("/admin", fakeHandler("internal")).
+		RequireHeader("X-Internal", "true").
+		RequireHeader("x-team", "")
+	router.GET("/admin", fakeHandler("public")).RequireHeader("X-Public", "")
+	router.GET("/internal", fakeHandler("internal")).RequireHeader("X-Internal", "true")
+
+	tests := []struct {
+		path    string
+		headers map[string][]string
+		code    int
+		want    string
+	}{
+		{"/admin", map[string][]string{"X-Internal": {"true"}, "X-Team": {"ops"}}, http.StatusOK, "internal"},
+		{"/admin", map[string][]string{"X-Internal": {"false", "true"}, "X-Team": {""}}, http.StatusOK, "internal"},
+		{"/admin", map[string][]string{"X-Internal": {"true"}}, http.StatusNotFound, ""},
+		{"/admin", map[string][]string{"X-Internal": {"yes"}, "X-Team": {"ops"}, "X-Public": {"1"}}, http.StatusOK, "public"},
+		{"/admin", nil, http.StatusNotFound, ""},
+		{"/internal", map[string][]string{"X-Internal": {"true"}}, http.StatusOK, "internal"},
+		{"/internal", map[string][]string{"X-Internal": {"false"}}, http.StatusNotFound, ""},
+		{"/internal", nil, http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		fakeHandlerValue = ""
+		r, _ := http.NewRequest(http.MethodGet, test.path, nil)
+		for key, values := range test.headers {
+			r.Header[key] = values
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code || fakeHandlerValue != test.want {
+			t.Errorf("routing %s with headers %v failed: Code=%d, handler=%q, want %d, %q",
+				test.path, test.headers, w.Code, fakeHandlerValue, test.code, test.want)
+		}
+	}
+}
+
+func TestRouterAbsoluteRedirects(t *testing.T) {
+	router := New()
+	router.RedirectFixedPath = true
+	router.GET("/foo", fakeHandler("/foo"))
+
+	tests := []struct {
+		absolute  bool
+		trust     bool
+		host      string
+		path      string
+		tls       bool
+		forwarded string
+		location  string
+	}{
+		{false, false, "example.com", "/foo/", false, "", "/foo"},
+		{false, false, "example.com", "/FOO?x=1", false, "", "/foo?x=1"},
+		{true, false, "example.com", "/foo/", false, "", "http://example.com/foo"},
+		{true, false, "example.com:8080", "/FOO?x=1", false, "", "http://example.com:8080/foo?x=1"},
+		{true, false, "example.com", "/foo/", true, "", "https://example.com/foo"},
+		{true, false, "example.com", "/foo/", false, "https", "http://example.com/foo"},
+		{true, true, "example.com", "/foo/", false, "https", "https://example.com/foo"},
+		{true, true, "example.com", "/foo/", true, "http", "http://example.com/foo"},
+		{true, true, "example.com", "/foo/", false, "gopher", "http://example.com/foo"},
+	}
+	for _, test := range tests {
+		router.AbsoluteRedirects = test.absolute
+		router.TrustForwardedProto = test.trust
+
+		r := httptest.NewRequest(http.MethodGet, test.path, nil)
+		r.Host = test.host
+		if test.tls {
+			r.TLS = &tls.ConnectionState{}
+		}
+		if test.forwarded != "" {
+			r.Header.Set("X-Forwarded-Proto", test.forwarded)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusMovedPermanently {
+			t.Errorf("request to %s not redirected: Code=%d", test.host+test.path, w.Code)
+		}
+		if location := w.Header().Get("Location"); location != test.location {
+			t.Errorf("AbsoluteRedirects=%v, TrustForwardedProto=%v: wrong Location for %s (X-Forwarded-Proto %q): %q, want %q",
+				test.absolute, test.trust, test.host+test.path, test.forwarded, location, test.location)
+		}
+	}
+}
+
+func TestRouterAutoHEAD(t *testing.T) {
+	router := New()
+	router.AutoHEAD = true
+
+	var served string
+	router.GET("/page", func(w http.ResponseWriter, r *http.Request, _ Params) {
+		served = "page " + r.Method
+		w.Header().Set("X-Page", "1")
+	})
+	router.GET("/events", func(w http.ResponseWriter, r *http.Request, _ Params) {
+		served = "events " + r.Method
+	}).NoAutoHEAD()
+	router.GET("/both", func(w http.ResponseWriter, r *http.Request, _ Params) {
+		served = "both GET"
+	})
+	router.HEAD("/both", func(w http.ResponseWriter, r *http.Request, _ Params) {
+		served = "both HEAD"
+	})
+
+	tests := []struct {
+		path   string
+		code   int
+		served string
+		allow  string
+	}{
+		{"/page", http.StatusOK, "page HEAD", ""},
+		{"/events", http.StatusMethodNotAllowed, "", "GET, OPTIONS"},
+		{"/both", http.StatusOK, "both HEAD", ""},
+		{"/missing", http.StatusNotFound, "", ""},
+	}
+	for _, test := range tests {
+		served = ""
+		r, _ := http.NewRequest(http.MethodHead, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code || served != test.served {
+			t.Errorf("HEAD %s failed: Code=%d, served=%q, want %d, %q", test.path, w.Code, served, test.code, test.served)
+		}
+		if allow := w.Header().Get("Allow"); allow != test.allow {
+			t.Errorf("HEAD %s: unexpected Allow header %q, want %q", test.path, allow, test.allow)
+		}
+	}
+
+	r, _ := http.NewRequest(http.MethodOptions, "/page", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if allow := w.Header().Get("Allow"); allow != "GET, HEAD, OPTIONS" {
+		t.Errorf("unexpected Allow header for /page: %q", allow)
+	}
+	r, _ = http.NewRequest(http.MethodOptions, "/events", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if allow := w.Header().Get("Allow"); allow != "GET, OPTIONS" {
+		t.Errorf("unexpected Allow header for /events: %q", allow)
+	}
+
+	router.AutoHEAD = false
+	r, _ = http.NewRequest(http.MethodHead, "/page", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("HEAD /page without AutoHEAD: Code=%d, want 405", w.Code)
+	}
+}
+
+func TestRouterPreserveEncodedSlash(t *testing.T) {
+	router := New()
+	var got string
+	router.GET("/a/@name", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		got = "name=" + ps.ByName("name")
+	})
+	router.GET("/a/@name/edit", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		got = "edit=" + ps.ByName("name")
+	})
+	router.GET("/files/*filepath", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		got = "filepath=" + ps.ByName("filepath")
+	})
+
+	tests := []struct {
+		preserve bool
+		path     string
+		code     int
+		got      string
+	}{
+		{false, "/a/x%2Fy", http.StatusNotFound, ""},
+		{false, "/a/x%2Fy/edit", http.StatusNotFound, ""},
+		{true, "/a/x%2Fy", http.StatusOK, "name=x/y"},
+		{true, "/a/x%2fy", http.StatusOK, "name=x/y"},
+		{true, "/a/x%2Fy/edit", http.StatusOK, "edit=x/y"},
+		{true, "/a/x%252Fy", http.StatusOK, "name=x%2Fy"},
+		{true, "/a/caf%C3%A9", http.StatusOK, "name=café"},
+		{true, "/a/plain", http.StatusOK, "name=plain"},
+		{true, "/files/a%2Fb/c", http.StatusOK, "filepath=/a/b/c"},
+	}
+	for _, test := range tests {
+		router.PreserveEncodedSlash = test.preserve
+		got = ""
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if w.Code != test.code || got != test.got {
+			t.Errorf("PreserveEncodedSlash=%v: routing %s failed: Code=%d, got %q, want %d, %q",
+				test.preserve, test.path, w.Code, got, test.code, test.got)
+		}
+	}
+
+	// redirects keep the encoded slashes
+	router.GET("/dirs/@name/", fakeHandler("/dirs/@name/"))
+	redirects := []struct {
+		path     string
+		location string
+	}{
+		{"/dirs/a%2Fb", "/dirs/a%2Fb/"},
+		{"/dirs/a%25b%2Fc%20d", "/dirs/a%25b%2Fc%20d/"},
+		{"/DIRS/a%2Fb/", "/dirs/a%2Fb/"},
+	}
+	router.PreserveEncodedSlash = true
+	for _, test := range redirects {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if location := w.Header().Get("Location"); w.Code != http.StatusMovedPermanently || location != test.location {
+			t.Errorf("redirecting %s failed: Code=%d, Location=%q, want %q", test.path, w.Code, location, test.location)
+		}
+	}
+}
+
+func TestRouterRedirectCodes(t *testing.T) {
+	router := New()
+	router.GET("/bar", func(http.ResponseWriter, *http.Request, Params) {})
+	router.POST("/bar", func(http.ResponseWriter, *http.Request, Params) {})
+
+	tests := []struct {
+		tsrCode   int
+		fixedCode int
+		method    string
+		path      string
+		code      int
+	}{
+		// defaults
+		{0, 0, http.MethodGet, "/bar/", http.StatusMovedPermanently},
+		{0, 0, http.MethodGet, "/BAR", http.StatusMovedPermanently},
+		{0, 0, http.MethodPost, "/bar/", http.StatusPermanentRedirect},
+		{0, 0, http.MethodPost, "/BAR", http.StatusPermanentRedirect},
+
+		// 308 for trailing slashes, 301 for fixed paths
+		{http.StatusPermanentRedirect, http.StatusMovedPermanently, http.MethodGet, "/bar/", http.StatusPermanentRedirect},
+		{http.StatusPermanentRedirect, http.StatusMovedPermanently, http.MethodGet, "/BAR", http.StatusMovedPermanently},
+		{http.StatusPermanentRedirect, http.StatusMovedPermanently, http.MethodPost, "/bar/", http.StatusPermanentRedirect},
+		{http.StatusPermanentRedirect, http.StatusMovedPermanently, http.MethodPost, "/BAR", http.StatusPermanentRedirect},
+
+		// temporary redirects
+		{http.StatusFound, http.StatusTemporaryRedirect, http.MethodGet, "/bar/", http.StatusFound},
+		{http.StatusFound, http.StatusTemporaryRedirect, http.MethodGet, "/BAR", http.StatusTemporaryRedirect},
+		{http.StatusFound, http.StatusTemporaryRedirect, http.MethodPost, "/bar/", http.StatusTemporaryRedirect},
+		{http.StatusFound, http.StatusTemporaryRedirect, http.MethodPost, "/BAR", http.StatusTemporaryRedirect},
+	}
+	for _, test := range tests {
+		router.RedirectTrailingSlashCode = test.tsrCode
+		router.RedirectFixedPathCode = test.fixedCode
+
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("RedirectTrailingSlashCode=%d, RedirectFixedPathCode=%d: %s %s: Code=%d, want %d",
+				test.tsrCode, test.fixedCode, test.method, test.path, w.Code, test.code)
+		}
+	}
+}
+
+func TestRouterOptionsMethodFilter(t *testing.T) {
+	router := New()
+	purged := false
+	router.GET("/cache", func(http.ResponseWriter, *http.Request, Params) {})
+	router.Handle("PURGE", "/cache", func(http.ResponseWriter, *http.Request, Params) {
+		purged = true
+	})
+	router.OptionsMethodFilter = func(method string) bool {
+		return method != "PURGE"
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		allow  string
+	}{
+		{http.MethodOptions, "/cache", http.StatusOK, "GET, OPTIONS"},
+		{http.MethodOptions, "*", http.StatusOK, "GET, OPTIONS"},
+		{http.MethodPut, "/cache", http.StatusMethodNotAllowed, "GET, OPTIONS"},
+		{"PURGE", "/cache", http.StatusOK, ""},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("%s %s: Code=%d, want %d", test.method, test.path, w.Code, test.code)
+		}
+		if allow := w.Header().Get("Allow"); allow != test.allow {
+			t.Errorf("%s %s: unexpected Allow header %q, want %q", test.method, test.path, allow, test.allow)
+		}
+	}
+	if !purged {
+		t.Error("PURGE request was not served")
+	}
+
+	router.OptionsMethodFilter = nil
+	r, _ := http.NewRequest(http.MethodOptions, "/cache", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if allow := w.Header().Get("Allow"); allow != "GET, OPTIONS, PURGE" {
+		t.Errorf("unexpected Allow header without filter: %q", allow)
+	}
+}
+
+func TestRouterHandleTRACE(t *testing.T) {
+	router := New()
+	router.GET("/path", func(http.ResponseWriter, *http.Request, Params) {})
+	router.GET("/internal/status", func(http.ResponseWriter, *http.Request, Params) {})
+	traced := false
+	router.Handle(http.MethodTrace, "/custom", func(http.ResponseWriter, *http.Request, Params) {
+		traced = true
+	})
+	router.DisableAutoTRACE("/internal")
+
+	newRequest := func(path string) *http.Request {
+		r := httptest.NewRequest(http.MethodTrace, path, nil)
+		r.Host = "example.com"
+		r.Header.Set("X-Test", "1")
+		r.Header.Set("Accept", "*/*")
+		r.Header.Set("Cookie", "session=secret")
+		r.Header.Set("Authorization", "Bearer secret")
+		return r
+	}
+
+	// disabled by default
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newRequest("/path"))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("TRACE without HandleTRACE: Code=%d, want 405", w.Code)
+	}
+
+	router.HandleTRACE = true
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newRequest("/path?x=1"))
+	if w.Code != http.StatusOK {
+		t.Errorf("TRACE failed: Code=%d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "message/http" {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+	want := "TRACE /path?x=1 HTTP/1.1\r\nHost: example.com\r\nAccept: */*\r\nX-Test: 1\r\n\r\n"
+	if body := w.Body.String(); body != want {
+		t.Errorf("unexpected body %q, want %q", body, want)
+	}
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/internal/status", http.StatusMethodNotAllowed},
+		{"/missing", http.StatusNotFound},
+		{"/custom", http.StatusOK},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newRequest(test.path))
+		if w.Code != test.code {
+			t.Errorf("TRACE %s: Code=%d, want %d", test.path, w.Code, test.code)
+		}
+		if w.Header().Get("Content-Type") == "message/http" {
+			t.Errorf("TRACE %s was reflected", test.path)
+		}
+	}
+	if !traced {
+		t.Error("TRACE handle was not called")
+	}
+}
+
+func TestRouterMeasureLookupDepth(t *testing.T) {
+	router := New()
+	depths := make(map[string]int)
+	handle := func(_ http.ResponseWriter, r *http.Request, _ Params) {
+		depth, ok := ContextLookupDepth(r.Context())
+		if !ok {
+			depth = -1
+		}
+		depths[r.URL.Path] = depth
+	}
+	router.GET("/", handle)
+	router.GET("/users/@id/posts/@post", handle)
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	if depths["/"] != -1 {
+		t.Errorf("lookup depth measured without MeasureLookupDepth: %d", depths["/"])
+	}
+
+	router.MeasureLookupDepth = true
+	for _, path := range []string{"/", "/users/1/posts/2"} {
+		r, _ := http.NewRequest(http.MethodGet, path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	if depths["/"] != 1 {
+		t.Errorf("wrong lookup depth of /: %d", depths["/"])
+	}
+	if depth := depths["/users/1/posts/2"]; depth <= depths["/"] {
+		t.Errorf("lookup depth of deep route not greater than of /: %d", depth)
+	}
+
+	// unmatched requests are handled as usual
+	r, _ = http.NewRequest(http.MethodGet, "/users/1/posts/2/", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusMovedPermanently {
+		t.Errorf("unmatched request not redirected: Code=%d", w.Code)
+	}
+}
+
+func BenchmarkLookupDepth(b *testing.B) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request, _ Params) {}
+
+	router := New()
+	router.GET("/", handlerFunc)
+	router.GET("/users/@id", handlerFunc)
+	router.GET("/users/@id/posts/@post", handlerFunc)
+	root := router.trees[http.MethodGet]
+
+	b.Run("Disabled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, ps, _ := root.getLeafDepth("/users/1/posts/2", router.getParams, false, nil)
+			router.putParams(ps)
+		}
+	})
+	b.Run("Enabled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			depth := 0
+			_, ps, _ := root.getLeafDepth("/users/1/posts/2", router.getParams, false, &depth)
+			router.putParams(ps)
+		}
+	})
+}
+
+func TestRouterPathAliases(t *testing.T) {
+	router := New()
+	var got string
+	router.GET("/color/@name", func(_ http.ResponseWriter, r *http.Request, ps Params) {
+		got = r.URL.Path + " " + ps.ByName("name")
+	})
+	router.GET("/colors", func(_ http.ResponseWriter, r *http.Request, _ Params) {
+		got = r.URL.Path
+	})
+	router.PathAliases(map[string]string{
+		"colour":  "color",
+		"colours": "colors",
+	})
+
+	tests := []struct {
+		path string
+		code int
+		got  string
+	}{
+		{"/color/red", http.StatusOK, "/color/red red"},
+		{"/colour/red", http.StatusOK, "/colour/red red"},
+		{"/colours", http.StatusOK, "/colours"},
+		{"/colour/colour", http.StatusOK, "/colour/colour color"},
+		{"/colourful/red", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		got = ""
+		r, _ := http.NewRequest(http.MethodGet, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code || got != test.got {
+			t.Errorf("routing %s failed: Code=%d, got %q, want %d, %q", test.path, w.Code, got, test.code, test.got)
+		}
+	}
+}
+
+func TestRouteIsolateParams(t *testing.T) {
+	var params Params
+	handler := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		params = ParamsFromContext(r.Context())
+	})
+
+	// a router mounted as the handler of a route of another router
+	child := New()
+	child.Handler(http.MethodGet, "/v/shared", handler)
+	child.Handler(http.MethodGet, "/v/isolated", handler).IsolateParams()
+	parent := New()
+	parent.Handler(http.MethodGet, "/v/*rest", child)
+
+	// a host router
+	router := New()
+	tenant := router.Host("@id.example.com")
+	tenant.Handler(http.MethodGet, "/users/@name", handler)
+	tenant.Handler(http.MethodGet, "/users/@name/isolated", handler).IsolateParams()
+	tenant.Handler(http.MethodGet, "/static", handler).IsolateParams()
+
+	tests := []struct {
+		router *Router
+		host   string
+		path   string
+		params Params
+	}{
+		{parent, "example.com", "/v/shared", Params{{"rest", "/shared"}}},
+		{parent, "example.com", "/v/isolated", nil},
+		{router, "acme.example.com", "/users/bob", Params{{"name", "bob"}, {"id", "acme"}}},
+		{router, "acme.example.com", "/users/bob/isolated", Params{{"name", "bob"}}},
+		{router, "acme.example.com", "/static", nil},
+	}
+	for _, test := range tests {
+		params = nil
+		r, _ := http.NewRequest(http.MethodGet, test.path, nil)
+		r.Host = test.host
+		w := httptest.NewRecorder()
+		test.router.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || !reflect.DeepEqual(params, test.params) {
+			t.Errorf("routing %s%s failed: Code=%d, Params=%v, want %v", test.host, test.path, w.Code, params, test.params)
+		}
+	}
+}
+
+func TestRouterGuardedCatchAlls(t *testing.T) {
+	var served string
+	handle := func(name string) Handle {
+		return func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+			served = name + " " + ps.ByName("path")
+		}
+	}
+
+	router := New()
+	router.GET("/api/*path", handle("legacy")).RequireHeader("X-Backend", "legacy")
+	router.GET("/api/*path", handle("canary")).Guard(func(r *http.Request) bool {
+		return strings.HasSuffix(r.Host, ".canary.example.com")
+	})
+	router.GET("/health", handle("health"))
+
+	tests := []struct {
+		host    string
+		backend string
+		path    string
+		code    int
+		served  string
+	}{
+		{"a.canary.example.com", "", "/api/users", http.StatusOK, "canary /users"},
+		{"a.canary.example.com", "legacy", "/api/users", http.StatusOK, "legacy /users"},
+		{"example.com", "legacy", "/api/users", http.StatusOK, "legacy /users"},
+		{"example.com", "", "/api/users", http.StatusNotFound, ""},
+		{"a.canary.example.com", "", "/health", http.StatusOK, "health "},
+	}
+	for _, test := range tests {
+		served = ""
+		r, _ := http.NewRequest(http.MethodGet, test.path, nil)
+		r.Host = test.host
+		if test.backend != "" {
+			r.Header.Set("X-Backend", test.backend)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code || served != test.served {
+			t.Errorf("routing %s%s (X-Backend %q) failed: Code=%d, served=%q, want %d, %q",
+				test.host, test.path, test.backend, w.Code, served, test.code, test.served)
+		}
+	}
+
+	// an unguarded catch-all serves the remaining requests
+	router.GET("/api/*path", handle("proxy"))
+	served = ""
+	r, _ := http.NewRequest(http.MethodGet, "/api/users", nil)
+	r.Host = "example.com"
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	if served != "proxy /users" {
+		t.Errorf("request not served by the unguarded catch-all: %q", served)
+	}
+}
+
+func TestParamsCacheKey(t *testing.T) {
+	tests := []struct {
+		pattern string
+		ps      Params
+		key     string
+	}{
+		{"/", nil, "/"},
+		{"/users/@id", Params{{"id", "42"}}, "/users/@id?id=42"},
+		{"/users/@id/posts/@post", Params{{"id", "42"}, {"post", "7"}}, "/users/@id/posts/@post?id=42&post=7"},
+		{"/files/*path", Params{{"path", "/a b&c=d"}}, "/files/%2Apath?path=%2Fa+b%26c%3Dd"},
+		{"/users/@id", Params{{"id", "42"}, {MatchedRoutePathParam, "/users/@id"}}, "/users/@id?id=42"},
+	}
+	for _, test := range tests {
+		if key := test.ps.CacheKey(test.pattern); key != test.key {
+			t.Errorf("CacheKey(%q) of %v = %q, want %q", test.pattern, test.ps, key, test.key)
+		}
+	}
+
+	distinct := []struct {
+		pattern string
+		ps      Params
+	}{
+		{"/users/@id", Params{{"id", "42"}}},
+		{"/users/@id", Params{{"id", "43"}}},
+		{"/users/@name", Params{{"name", "42"}}},
+		{"/users/@id", Params{{"id", "4&2"}}},
+		{"/users/@id", Params{{"id", "4"}, {"2", ""}}},
+		{"/users/@id?id=42", nil},
+	}
+	keys := make(map[string]int)
+	for i, d := range distinct {
+		key := d.ps.CacheKey(d.pattern)
+		if j, ok := keys[key]; ok {
+			t.Errorf("matches %d and %d have the same key %q", j, i, key)
+		}
+		keys[key] = i
+	}
+}
+
+func TestRouterDecorator(t *testing.T) {
+	router := New()
+	var spans []string
+	decorations := make(map[string]int)
+	router.Decorator = func(pattern string, h http.HandlerFunc) http.HandlerFunc {
+		decorations[pattern]++
+		return func(w http.ResponseWriter, r *http.Request) {
+			spans = append(spans, pattern)
+			h(w, r)
+		}
+	}
+
+	var id string
+	router.GET("/users/@id", func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		id = ps.ByName("id")
+	})
+	router.GET("/health", func(http.ResponseWriter, *http.Request, Params) {})
+	router.Add(RouteSpec{
+		Method: http.MethodGet,
+		Path:   "/wrapped",
+		Handle: func(http.ResponseWriter, *http.Request, Params) {},
+		Middleware: []func(http.Handler) http.Handler{
+			func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					spans = append(spans, "middleware")
+					next.ServeHTTP(w, r)
+				})
+			},
+		},
+	})
+
+	for _, path := range []string{"/users/1", "/users/2", "/health", "/wrapped"} {
+		r, _ := http.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("routing %s failed: Code=%d", path, w.Code)
+		}
+	}
+
+	if id != "2" {
+		t.Errorf("wrong param value passed through the decorator: %q", id)
+	}
+	wantSpans := []string{"/users/@id", "/users/@id", "/health", "/wrapped", "middleware"}
+	if !reflect.DeepEqual(spans, wantSpans) {
+		t.Errorf("wrong spans: got %v, want %v", spans, wantSpans)
+	}
+	wantDecorations := map[string]int{"/users/@id": 1, "/health": 1, "/wrapped": 1}
+	if !reflect.DeepEqual(decorations, wantDecorations) {
+		t.Errorf("routes not decorated exactly once: %v", decorations)
+	}
+}
+
+func TestRouteSecureOnly(t *testing.T) {
+	router := New()
+	served := false
+	router.POST("/login", func(http.ResponseWriter, *http.Request, Params) {
+		served = true
+	}).SecureOnly()
+	router.GET("/login", func(http.ResponseWriter, *http.Request, Params) {
+		served = true
+	}).SecureOnly()
+
+	tests := []struct {
+		redirect  bool
+		trust     bool
+		method    string
+		tls       bool
+		forwarded string
+		code      int
+		location  string
+	}{
+		{false, false, http.MethodPost, false, "", http.StatusForbidden, ""},
+		{false, false, http.MethodPost, true, "", http.StatusOK, ""},
+		{false, false, http.MethodPost, false, "https", http.StatusForbidden, ""},
+		{false, true, http.MethodPost, false, "https", http.StatusOK, ""},
+		{false, true, http.MethodPost, true, "http", http.StatusForbidden, ""},
+		{true, false, http.MethodGet, false, "", http.StatusMovedPermanently, "https://example.com/login?next=%2F"},
+		{true, false, http.MethodPost, false, "", http.StatusPermanentRedirect, "https://example.com/login?next=%2F"},
+		{true, false, http.MethodGet, true, "", http.StatusOK, ""},
+	}
+	for _, test := range tests {
+		router.SecureRedirect = test.redirect
+		router.TrustForwardedProto = test.trust
+		served = false
+
+		r := httptest.NewRequest(test.method, "/login?next=%2F", nil)
+		r.Host = "example.com"
+		if test.tls {
+			r.TLS = &tls.ConnectionState{}
+		}
+		if test.forwarded != "" {
+			r.Header.Set("X-Forwarded-Proto", test.forwarded)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code || served != (test.code == http.StatusOK) {
+			t.Errorf("SecureRedirect=%v, TrustForwardedProto=%v: %s (TLS %v, X-Forwarded-Proto %q): Code=%d, served=%v, want %d",
+				test.redirect, test.trust, test.method, test.tls, test.forwarded, w.Code, served, test.code)
+		}
+		if location := w.Header().Get("Location"); location != test.location {
+			t.Errorf("%s (TLS %v): wrong Location %q, want %q", test.method, test.tls, location, test.location)
+		}
+	}
+}
+
+func TestRouterAllowWithoutHandleOPTIONS(t *testing.T) {
+	handlerFunc := func(_ http.ResponseWriter, _ *http.Request, _ Params) {}
+
+	router := New()
+	router.GET("/path", handlerFunc)
+	router.POST("/path", handlerFunc)
+	router.GET("/custom", handlerFunc)
+	router.OPTIONS("/custom", handlerFunc)
+
+	tests := []struct {
+		handleOPTIONS bool
+		path          string
+		allow         string
+	}{
+		{true, "/path", "GET, OPTIONS, POST"},
+		{false, "/path", "GET, POST"},
+		{true, "/custom", "GET, OPTIONS"},
+		{false, "/custom", "GET, OPTIONS"},
+	}
+	for _, test := range tests {
+		router.HandleOPTIONS = test.handleOPTIONS
+		r, _ := http.NewRequest(http.MethodDelete, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("HandleOPTIONS=%v: DELETE %s: Code=%d, want 405", test.handleOPTIONS, test.path, w.Code)
+		}
+		if allow := w.Header().Get("Allow"); allow != test.allow {
+			t.Errorf("HandleOPTIONS=%v: DELETE %s: unexpected Allow header %q, want %q", test.handleOPTIONS, test.path, allow, test.allow)
+		}
+	}
+}
+
+func TestRouterLoading(t *testing.T) {
+	router := New()
+	router.GET("/users", func(w http.ResponseWriter, _ *http.Request, _ Params) {
+		w.Write([]byte("users"))
+	})
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	router.SetLoading(true)
+	for _, path := range []string{"/users", "/missing"} {
+		if w := serve(path); w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s while loading: Code=%d, want 503", path, w.Code)
+		}
+	}
+
+	router.LoadingHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("loading"))
+	})
+	if w := serve("/users"); w.Code != http.StatusServiceUnavailable || w.Body.String() != "loading" || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("loading handler not called: Code=%d, body=%q", w.Code, w.Body.String())
+	}
+
+	router.SetLoading(false)
+	if w := serve("/users"); w.Code != http.StatusOK || w.Body.String() != "users" {
+		t.Errorf("request not routed after loading: Code=%d, body=%q", w.Code, w.Body.String())
+	}
+
+	router.SetLoading(true)
+	if w := serve("/users"); w.Body.String() != "loading" {
+		t.Errorf("loading handler not called after SetLoading(true): body=%q", w.Body.String())
+	}
+
+	router = New()
+	if w := serve("/users"); w.Code != http.StatusNotFound {
+		t.Errorf("router loading by default: Code=%d", w.Code)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+// ShadowWarning describes a registered route which can never be matched, see
+// Router.Shadowed.
+type ShadowWarning struct {
+	// The route which can never be matched
+	Route *Route
+
+	// The route matching all requests to the path of Route instead, if any
+	ShadowedBy *Route
+
+	// A description of why the route can't be matched
+	Reason string
+}
+
+// Shadowed analyzes the trees of the router and reports all routes which can
+// never be matched because another route always wins.
+// Most of these conflicts, e.g. a static route below a catch-all at the same
+// position, are already rejected when the route is registered. A route can
+// still be shadowed if it is tried after a route for the same method and path
+// which matches all requests, e.g. a route without guards, constraints or
+// ErrSkip support, or if the tree does not lead to the route at all, e.g.
+// after ImportStructure. No requests are served to find the routes.
+func (r *Router) Shadowed() []ShadowWarning {
+	var warnings []ShadowWarning
+	for _, rt := range r.routes {
+		root := r.trees[rt.method]
+		for _, l := range r.leaves(rt) {
+			var n *node
+			if root != nil {
+				n = root.patternNode(l.path)
+			}
+			if n == nil || n.handle == nil || n.route == nil {
+				warnings = append(warnings, ShadowWarning{
+					Route:  rt,
+					Reason: "no handle is registered for path '" + l.path + "'",
+				})
+				break
+			}
+
+			if !inChain(n.route, rt) {
+				warnings = append(warnings, ShadowWarning{
+					Route:      rt,
+					ShadowedBy: n.route,
+					Reason:     "path '" + l.path + "' is served by route " + n.route.method + " " + n.route.path,
+				})
+				break
+			}
+			if by := shadowedBy(n.route, rt); by != nil {
+				warnings = append(warnings, ShadowWarning{
+					Route:      rt,
+					ShadowedBy: by,
+					Reason:     "route " + by.method + " " + by.path + " matches all requests before it",
+				})
+				break
+			}
+		}
+	}
+	return warnings
+}
+
+// shadowedBy returns the route of the chain starting at head which matches all
+// requests before rt is tried, or nil if there is none.
+func shadowedBy(head, rt *Route) *Route {
+	for cur := head; cur != nil && cur != rt; cur = cur.next {
+		if cur.matchesAll() {
+			return cur
+		}
+	}
+	return nil
+}
+
+// inChain reports whether rt is part of the chain starting at head.
+func inChain(head, rt *Route) bool {
+	for cur := head; cur != nil; cur = cur.next {
+		if cur == rt {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAll reports whether the route serves all requests passed to it,
+// instead of passing some to the next route for the same method and path.
+func (rt *Route) matchesAll() bool {
+	return len(rt.guards) == 0 && len(rt.constraints) == 0 && !rt.skippable && rt.language == ""
+}
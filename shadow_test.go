@@ -0,0 +1,61 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRouterShadowed(t *testing.T) {
+	noop := func(http.ResponseWriter, *http.Request, Params) {}
+
+	router := New()
+	router.GET("/", noop)
+	router.GET("/files/*filepath", noop)
+	router.GET("/users/@id?", noop)
+	json := router.GET("/items", noop).RequireHeader("Accept", "application/json").Named("json")
+	html := router.GET("/items", noop).Named("html")
+	if warnings := router.Shadowed(); len(warnings) != 0 {
+		t.Fatalf("unexpected warnings: %+v", warnings)
+	}
+
+	// the guards of routes are not part of the structure, so the second
+	// route is tried after a route matching all requests after the import
+	imported := New()
+	err := imported.ImportStructure(router.ExportStructure(), map[string]http.HandlerFunc{
+		"GET /":                func(http.ResponseWriter, *http.Request) {},
+		"GET /files/*filepath": func(http.ResponseWriter, *http.Request) {},
+		"GET /users/@id?":      func(http.ResponseWriter, *http.Request) {},
+		"json":                 func(http.ResponseWriter, *http.Request) {},
+		"html":                 func(http.ResponseWriter, *http.Request) {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	warnings := imported.Shadowed()
+	if len(warnings) != 1 {
+		t.Fatalf("expected 1 warning, got %+v", warnings)
+	}
+	if w := warnings[0]; w.Route.Name() != "html" || w.ShadowedBy == nil || w.ShadowedBy.Name() != "json" {
+		t.Errorf("wrong warning: %+v", w)
+	}
+
+	imported.NamedRoute("json").RequireHeader("Accept", "application/json")
+	if warnings := imported.Shadowed(); len(warnings) != 0 {
+		t.Errorf("unexpected warnings after setting the guard again: %+v", warnings)
+	}
+
+	// a route the tree does not lead to
+	json.guards = nil
+	router.trees[http.MethodGet].patternNode("/items").route = html
+	warnings = router.Shadowed()
+	if len(warnings) != 1 {
+		t.Fatalf("expected 1 warning, got %+v", warnings)
+	}
+	if w := warnings[0]; w.Route != json || w.ShadowedBy != html {
+		t.Errorf("wrong warning: %+v", w)
+	}
+}
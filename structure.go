@@ -0,0 +1,248 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// structure is the serialized form of the routes and trees of a router, see
+// ExportStructure.
+type structure struct {
+	Routes []structureRoute          `json:"routes"`
+	Trees  map[string]*structureNode `json:"trees"`
+}
+
+type structureRoute struct {
+	Method          string `json:"method"`
+	Path            string `json:"path"`
+	Name            string `json:"name,omitempty"`
+	SaveMatchedPath bool   `json:"saveMatchedPath,omitempty"`
+	BareCatchAll    bool   `json:"bareCatchAll,omitempty"`
+	BareValue       string `json:"bareValue,omitempty"`
+}
+
+type structureNode struct {
+	Path      string           `json:"p,omitempty"`
+	Indices   string           `json:"i,omitempty"`
+	WildChild bool             `json:"w,omitempty"`
+	Type      nodeType         `json:"t,omitempty"`
+	Priority  uint32           `json:"r,omitempty"`
+	HasHandle bool             `json:"h,omitempty"`
+	Enum      []string         `json:"e,omitempty"`
+	Children  []*structureNode `json:"c,omitempty"`
+}
+
+// ExportStructure serializes the registered routes and the trees built from
+// them, without the handles, which can't be serialized. The structure can be
+// loaded by ImportStructure, which is faster than registering the routes.
+// Options of routes other than their name, like middleware or guards, are not
+// part of the structure.
+func (r *Router) ExportStructure() []byte {
+	s := structure{
+		Routes: make([]structureRoute, len(r.routes)),
+		Trees:  make(map[string]*structureNode, len(r.trees)),
+	}
+	for i, rt := range r.routes {
+		s.Routes[i] = structureRoute{
+			Method:          rt.method,
+			Path:            rt.path,
+			Name:            rt.name,
+			SaveMatchedPath: rt.saveMatchedPath,
+			BareCatchAll:    rt.bareCatchAll,
+			BareValue:       rt.bareValue,
+		}
+	}
+	for method, root := range r.trees {
+		s.Trees[method] = exportNode(root)
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		// all fields are serializable
+		panic(err)
+	}
+	return data
+}
+
+func exportNode(n *node) *structureNode {
+	sn := &structureNode{
+		Path:      n.path,
+		Indices:   n.indices,
+		WildChild: n.wildChild,
+		Type:      n.nType,
+		Priority:  n.priority,
+		HasHandle: n.handle != nil,
+	}
+	for value := range n.enum {
+		sn.Enum = append(sn.Enum, value)
+	}
+	sort.Strings(sn.Enum)
+	for _, child := range n.children {
+		sn.Children = append(sn.Children, exportNode(child))
+	}
+	return sn
+}
+
+// ImportStructure registers the routes of a structure serialized by
+// ExportStructure, binding a handler to each route. The handler of a named
+// route is looked up by the name of the route, the handler of other routes by
+// the method and path, e.g. "GET /users/@id". The params are available in the
+// request context, like for routes registered by Handler.
+// The trees are loaded as they are instead of being built from the routes.
+// The router must not have any routes registered. Options of the routes can be
+// set again after the import, see Routes and NamedRoute.
+func (r *Router) ImportStructure(data []byte, handlers map[string]http.HandlerFunc) error {
+	if len(r.routes) > 0 {
+		return errors.New("router has routes registered")
+	}
+
+	var s structure
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid structure: %v", err)
+	}
+
+	trees := make(map[string]*node, len(s.Trees))
+	for method, sn := range s.Trees {
+		root, err := importNode(sn, r.constraints)
+		if err != nil {
+			return fmt.Errorf("invalid tree of method %s: %v", method, err)
+		}
+		trees[method] = root
+	}
+
+	routes := make([]*Route, len(s.Routes))
+	last := make(map[string]*Route)
+	for i, sr := range s.Routes {
+		key := sr.Name
+		if key == "" {
+			key = sr.Method + " " + sr.Path
+		}
+		handler := handlers[key]
+		if handler == nil {
+			return fmt.Errorf("missing handler for route %s", key)
+		}
+
+		rt := &Route{
+			router:          r,
+			method:          sr.Method,
+			path:            sr.Path,
+			name:            sr.Name,
+			handle:          handlerHandle(handler),
+			saveMatchedPath: sr.SaveMatchedPath,
+			bareCatchAll:    sr.BareCatchAll,
+			bareValue:       sr.BareValue,
+			decoration:      new(decoration),
+		}
+		rt.checks = pathChecks(rt.path, r.constraints)
+		routes[i] = rt
+
+		// Routes for the same method and path are tried in order
+		if prev := last[sr.Method+" "+sr.Path]; prev != nil {
+			prev.next = rt
+			last[sr.Method+" "+sr.Path] = rt
+			continue
+		}
+		last[sr.Method+" "+sr.Path] = rt
+
+		root := trees[sr.Method]
+		if root == nil {
+			return fmt.Errorf("missing tree for route %s %s", sr.Method, sr.Path)
+		}
+		for _, l := range r.leaves(rt) {
+			n := root.patternNode(l.path)
+			if n == nil || n.handle != nil {
+				return fmt.Errorf("tree does not match route %s %s", sr.Method, sr.Path)
+			}
+			n.handle, n.route = l.handle, rt
+		}
+	}
+
+	for method, sn := range s.Trees {
+		if !boundHandles(sn, trees[method]) {
+			return fmt.Errorf("tree of method %s does not match routes", method)
+		}
+	}
+
+	r.trees = trees
+	r.routes = routes
+	for _, rt := range routes {
+		r.updateMaxParams(rt)
+	}
+	r.indexNames()
+	r.globalAllowed = r.allowed("*", "")
+	return nil
+}
+
+// importCheck returns the check of the values of a param node with the given
+// path, see parseCheck.
+func importCheck(path string, types map[string]func(value string) bool) (check func(value string) bool, err error) {
+	defer func() {
+		if rcv := recover(); rcv != nil {
+			err = fmt.Errorf("%v", rcv)
+		}
+	}()
+	return parseCheck(path, path, types), nil
+}
+
+func importNode(sn *structureNode, types map[string]func(value string) bool) (*node, error) {
+	if sn == nil {
+		return nil, errors.New("missing node")
+	}
+	if sn.Type > catchAll {
+		return nil, fmt.Errorf("invalid type of node '%s'", sn.Path)
+	}
+	if sn.WildChild && len(sn.Children) == 0 ||
+		!sn.WildChild && len(sn.Indices) != len(sn.Children) {
+		return nil, fmt.Errorf("invalid children of node '%s'", sn.Path)
+	}
+
+	n := &node{
+		path:      sn.Path,
+		indices:   sn.Indices,
+		wildChild: sn.WildChild,
+		nType:     sn.Type,
+		priority:  sn.Priority,
+	}
+	if len(sn.Enum) > 0 {
+		n.enum = make(map[string]bool, len(sn.Enum))
+		for _, value := range sn.Enum {
+			n.enum[value] = true
+		}
+	}
+	if n.nType == param {
+		check, err := importCheck(n.path, types)
+		if err != nil {
+			return nil, err
+		}
+		n.check = check
+	}
+	for _, child := range sn.Children {
+		c, err := importNode(child, types)
+		if err != nil {
+			return nil, err
+		}
+		n.children = append(n.children, c)
+	}
+	return n, nil
+}
+
+// boundHandles reports whether exactly the nodes which had a handle when the
+// structure was exported have a handle bound.
+func boundHandles(sn *structureNode, n *node) bool {
+	if sn.HasHandle != (n.handle != nil) {
+		return false
+	}
+	for i, child := range sn.Children {
+		if !boundHandles(child, n.children[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,137 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouterExportImportStructure(t *testing.T) {
+	noop := func(http.ResponseWriter, *http.Request, Params) {}
+
+	router := New()
+	router.GET("/", noop)
+	router.GET("/users", noop).Named("users")
+	router.GET("/users/@id", noop).Named("user")
+	router.GET("/users/@id/posts/@page?=1", noop)
+	router.GET("/issues/@state{open|closed}", noop)
+	router.GET("/src/*filepath", noop).MatchBarePrefix()
+	router.GET("/repos/*path/commits", noop)
+	router.GET("/feeds/@format{rss|atom}", noop)
+	router.GET("/feeds/@name", noop)
+	router.POST("/users", noop)
+	router.POST("/hooks", noop).RequireBody()
+	router.POST("/hooks", noop)
+
+	data := router.ExportStructure()
+
+	var got string
+	handler := func(name string) http.HandlerFunc {
+		return func(_ http.ResponseWriter, req *http.Request) {
+			got = name
+			for _, p := range ParamsFromContext(req.Context()) {
+				got += " " + p.Key + "=" + p.Value
+			}
+		}
+	}
+	handlers := map[string]http.HandlerFunc{
+		"GET /":                           handler("index"),
+		"users":                           handler("users"),
+		"user":                            handler("user"),
+		"GET /users/@id/posts/@page?=1":   handler("posts"),
+		"GET /issues/@state{open|closed}": handler("issues"),
+		"GET /src/*filepath":              handler("src"),
+		"GET /repos/*path/commits":        handler("commits"),
+		"GET /feeds/@format{rss|atom}":    handler("feed"),
+		"GET /feeds/@name":                handler("named feed"),
+		"POST /users":                     handler("create"),
+		"POST /hooks":                     handler("hook"),
+	}
+
+	imported := New()
+	if err := imported.ImportStructure(data, handlers); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{http.MethodGet, "/", "index"},
+		{http.MethodGet, "/users", "users"},
+		{http.MethodGet, "/users/42", "user id=42"},
+		{http.MethodGet, "/users/42/posts", "posts id=42 page=1"},
+		{http.MethodGet, "/users/42/posts/3", "posts id=42 page=3"},
+		{http.MethodGet, "/issues/open", "issues state=open"},
+		{http.MethodGet, "/src", "src filepath="},
+		{http.MethodGet, "/src/a/b.go", "src filepath=/a/b.go"},
+		{http.MethodGet, "/repos/a/b/commits", "commits path=/a/b"},
+		{http.MethodGet, "/feeds/rss", "feed format=rss"},
+		{http.MethodGet, "/feeds/xml", "named feed name=xml"},
+		{http.MethodPost, "/users", "create"},
+		{http.MethodPost, "/hooks", "hook"},
+	}
+	for _, test := range tests {
+		got = ""
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		imported.ServeHTTP(w, r)
+		if got != test.want {
+			t.Errorf("routing %s %s failed: got %q, want %q (Code=%d)", test.method, test.path, got, test.want, w.Code)
+		}
+	}
+
+	// non-matching requests are handled as usual
+	r, _ := http.NewRequest(http.MethodGet, "/issues/all", nil)
+	w := httptest.NewRecorder()
+	imported.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("wrong status for value outside of enum: %d", w.Code)
+	}
+	r, _ = http.NewRequest(http.MethodPut, "/users", nil)
+	w = httptest.NewRecorder()
+	imported.ServeHTTP(w, r)
+	if allow := w.Header().Get("Allow"); w.Code != http.StatusMethodNotAllowed || allow != "GET, OPTIONS, POST" {
+		t.Errorf("wrong 405 response: Code=%d, Allow=%q", w.Code, allow)
+	}
+
+	if imported.NamedRoute("user") == nil || len(imported.Routes()) != len(router.Routes()) {
+		t.Error("routes not imported")
+	}
+
+	// the exported structure of the imported router is the same
+	if again := imported.ExportStructure(); string(again) != string(data) {
+		t.Errorf("structure changed by round trip:\n%s\n%s", data, again)
+	}
+}
+
+func TestRouterImportStructureErrors(t *testing.T) {
+	noop := func(http.ResponseWriter, *http.Request, Params) {}
+	router := New()
+	router.GET("/users/@id", noop)
+	data := router.ExportStructure()
+
+	if err := New().ImportStructure(data, nil); err == nil {
+		t.Error("no error for missing handler")
+	}
+
+	handlers := map[string]http.HandlerFunc{
+		"GET /users/@id": func(http.ResponseWriter, *http.Request) {},
+	}
+	if err := router.ImportStructure(data, handlers); err == nil {
+		t.Error("no error for router with routes")
+	}
+	if err := New().ImportStructure([]byte("{"), handlers); err == nil {
+		t.Error("no error for invalid data")
+	}
+
+	tampered := []byte(`{"routes":[{"method":"GET","path":"/users/@id"}],"trees":{"GET":{"p":"/users"}}}`)
+	if err := New().ImportStructure(tampered, handlers); err == nil {
+		t.Error("no error for tree not matching the routes")
+	}
+}
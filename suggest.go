@@ -0,0 +1,87 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import "strings"
+
+// maxSuggestions is the maximum number of routes suggested for an unmatched
+// request, see SuggestRoutes.
+const maxSuggestions = 3
+
+// suggestRoutes returns the routes closest to the given path by edit distance,
+// as "METHOD pattern", see SuggestRoutes. Segments of the path matched by
+// params of a route don't count towards the distance.
+func (r *Router) suggestRoutes(path string) []string {
+	type suggestion struct {
+		route    string
+		distance int
+	}
+
+	limit := 1 + len(path)/3
+	var suggestions []suggestion
+	for _, rt := range r.routes {
+		d := levenshtein(path, instantiate(rt.path, path))
+		if d <= limit {
+			suggestions = append(suggestions, suggestion{rt.method + " " + rt.path, d})
+		}
+	}
+	// stable insertion sort by distance, keeping the order of registration
+	for i := 1; i < len(suggestions); i++ {
+		for j := i; j > 0 && suggestions[j].distance < suggestions[j-1].distance; j-- {
+			suggestions[j], suggestions[j-1] = suggestions[j-1], suggestions[j]
+		}
+	}
+
+	var routes []string
+	for i := 0; i < len(suggestions) && i < maxSuggestions; i++ {
+		routes = append(routes, suggestions[i].route)
+	}
+	return routes
+}
+
+// instantiate returns the route pattern with its wildcards replaced by the
+// segments of the path at the same position, e.g. /users/42 for /users/@id
+// and /users/42.
+func instantiate(pattern, path string) string {
+	if strings.IndexByte(pattern, '@') < 0 && strings.IndexByte(pattern, '*') < 0 {
+		return pattern
+	}
+
+	patternSegments := strings.Split(pattern, "/")
+	pathSegments := strings.Split(path, "/")
+	for i, segment := range patternSegments {
+		if segment == "" || i >= len(pathSegments) {
+			continue
+		}
+		switch segment[0] {
+		case '@':
+			patternSegments[i] = pathSegments[i]
+		case '*':
+			patternSegments[i] = strings.Join(pathSegments[i:], "/")
+		}
+	}
+	return strings.Join(patternSegments, "/")
+}
+
+// levenshtein returns the edit distance of a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(min(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
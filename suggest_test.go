@@ -0,0 +1,94 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "abc", 0},
+		{"/users", "/usres", 2},
+		{"/users", "/user", 1},
+		{"kitten", "sitting", 3},
+	}
+	for _, test := range tests {
+		if d := levenshtein(test.a, test.b); d != test.distance {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", test.a, test.b, d, test.distance)
+		}
+	}
+}
+
+func TestInstantiate(t *testing.T) {
+	tests := []struct {
+		pattern, path, result string
+	}{
+		{"/users", "/usres", "/users"},
+		{"/users/@id", "/user/42", "/users/42"},
+		{"/users/@id/posts", "/users", "/users/@id/posts"},
+		{"/files/*filepath", "/file/a/b", "/files/a/b"},
+	}
+	for _, test := range tests {
+		if s := instantiate(test.pattern, test.path); s != test.result {
+			t.Errorf("instantiate(%q, %q) = %q, want %q", test.pattern, test.path, s, test.result)
+		}
+	}
+}
+
+func TestRouterSuggestRoutes(t *testing.T) {
+	noop := func(http.ResponseWriter, *http.Request, Params) {}
+
+	router := New()
+	router.GET("/users", noop)
+	router.POST("/users", noop)
+	router.GET("/users/@id", noop)
+	router.GET("/settings", noop)
+
+	tests := []struct {
+		path        string
+		suggestions string
+		body        string
+	}{
+		{"/usres", "GET /users, POST /users", "404 page not found\n\nDid you mean:\n  GET /users\n  POST /users\n"},
+		{"/user/42", "GET /users/@id, GET /users, POST /users", "404 page not found\n\nDid you mean:\n  GET /users/@id\n  GET /users\n  POST /users\n"},
+		{"/setings", "GET /settings", "404 page not found\n\nDid you mean:\n  GET /settings\n"},
+		{"/completely/different", "", "404 page not found\n"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(http.MethodGet, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: Code=%d, want 404", test.path, w.Code)
+		}
+		if s := w.Header().Get("X-Route-Suggestions"); s != "" {
+			t.Errorf("%s: suggestions without SuggestRoutes: %q", test.path, s)
+		}
+	}
+
+	router.SuggestRoutes = true
+	for _, test := range tests {
+		r, _ := http.NewRequest(http.MethodGet, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: Code=%d, want 404", test.path, w.Code)
+		}
+		if s := w.Header().Get("X-Route-Suggestions"); s != test.suggestions {
+			t.Errorf("%s: wrong suggestions %q, want %q", test.path, s, test.suggestions)
+		}
+		if body := w.Body.String(); body != test.body {
+			t.Errorf("%s: wrong body %q, want %q", test.path, body, test.body)
+		}
+	}
+}
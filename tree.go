@@ -5,6 +5,7 @@
 package httprouter
 
 import (
+	"regexp"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -38,12 +39,20 @@ func findWildcard(path string) (wilcard string, i int, valid bool) {
 
 		// Find end and check for invalid characters
 		valid = true
-		for end, c := range []byte(path[start+1:]) {
-			switch c {
-			case '/', ':':
-				return path[start : start+1+end], start, valid
+		for end := start + 1; end < len(path); end++ {
+			switch path[end] {
+			case '/', ':', '.':
+				return path[start:end], start, valid
 			case '@', '*':
 				valid = false
+			case '{', '(', '<':
+				// Skip the enum values, the regular expression or the type,
+				// which may contain any of the above
+				if i := closeConstraint(path, end); i > 0 {
+					end = i
+				} else {
+					valid = false
+				}
 			}
 		}
 		return path[start:], start, valid
@@ -51,6 +60,77 @@ func findWildcard(path string) (wilcard string, i int, valid bool) {
 	return "", -1, false
 }
 
+// closeConstraint returns the index of the bracket closing the constraint of
+// a wildcard opened at path[i], an enum like {open|closed}, a regular
+// expression like ([0-9]+) or a type like <int>, or -1 if it is not closed.
+// The parentheses of a regular expression nest and may be escaped by a
+// backslash.
+func closeConstraint(path string, i int) int {
+	switch path[i] {
+	case '{':
+		if j := strings.IndexByte(path[i:], '}'); j > 0 {
+			return i + j
+		}
+	case '<':
+		if j := strings.IndexByte(path[i:], '>'); j > 0 {
+			return i + j
+		}
+	case '(':
+		depth := 0
+		for j := i; j < len(path); j++ {
+			switch path[j] {
+			case '\\':
+				j++
+			case '(':
+				depth++
+			case ')':
+				if depth--; depth == 0 {
+					return j
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// splitConstraint splits a wildcard like @id([0-9]+) into the wildcard without
+// the constraint, @id, and the constraint, ([0-9]+). An unclosed constraint
+// is returned with the rest of the wildcard.
+func splitConstraint(wildcard string) (name, constraint string) {
+	i := strings.IndexAny(wildcard, "{(<")
+	if i < 0 {
+		return wildcard, ""
+	}
+	if j := closeConstraint(wildcard, i); j > 0 {
+		return wildcard[:i], wildcard[i : j+1]
+	}
+	return wildcard[:i], wildcard[i:]
+}
+
+// maskConstraints returns the path with the constraints of its wildcards
+// replaced by underscores, so that it can be searched for characters with a
+// special meaning in paths, like '?' and '*', which may also appear in
+// regular expressions.
+func maskConstraints(path string) string {
+	if strings.IndexAny(path, "{(<") < 0 {
+		return path
+	}
+	buf := []byte(path)
+	for offset := 0; ; {
+		wildcard, i, _ := findWildcard(path[offset:])
+		if i < 0 {
+			break
+		}
+		name, constraint := splitConstraint(wildcard)
+		start := offset + i + len(name)
+		for j := start; j < start+len(constraint); j++ {
+			buf[j] = '_'
+		}
+		offset += i + len(wildcard)
+	}
+	return string(buf)
+}
+
 func countParams(path string) uint16 {
 	var n uint
 	for i := range []byte(path) {
@@ -62,6 +142,11 @@ func countParams(path string) uint16 {
 	return uint16(n)
 }
 
+// isParamEnd reports whether c terminates a named parameter in a pattern.
+func isParamEnd(c byte) bool {
+	return c == '/' || c == ':' || c == '.'
+}
+
 type nodeType uint8
 
 const (
@@ -79,6 +164,107 @@ type node struct {
 	priority  uint32
 	children  []*node
 	handle    Handle
+
+	// The route of the handle, if added by a Router
+	route *Route
+
+	// The allowed values of a param node with an enum, e.g. @state{open|closed}
+	enum map[string]bool
+
+	// The check of the values of a param node with a regular expression or a
+	// type, e.g. @id([0-9]+) or @id<int>
+	check func(value string) bool
+}
+
+// parseEnum splits a param wildcard like @state{open|closed} into the wildcard
+// without the enum and the set of enum values.
+// The returned set is nil if the wildcard has no enum. Other constraints,
+// see parseCheck, are stripped from the wildcard as well.
+func parseEnum(wildcard, fullPath string) (string, map[string]bool) {
+	name, constraint := splitConstraint(wildcard)
+	if constraint == "" {
+		return wildcard, nil
+	}
+	if len(name)+len(constraint) != len(wildcard) {
+		panic("constraint must be the end of the wildcard '" + wildcard + "' in path '" + fullPath + "'")
+	}
+	if constraint[0] != '{' {
+		return name, nil
+	}
+
+	enum := make(map[string]bool)
+	for _, value := range strings.Split(constraint[1:len(constraint)-1], "|") {
+		if value == "" {
+			panic("enum values must not be empty in wildcard '" + wildcard + "' in path '" + fullPath + "'")
+		}
+		enum[value] = true
+	}
+	return name, enum
+}
+
+// parseCheck returns the check of the values of a param wildcard with a
+// regular expression like @id([0-9]+), which must match the whole value, or a
+// type like @id<int>, see paramTypes, or nil if the wildcard has neither.
+// Types not built in are looked up in types, see Router.RegisterConstraint.
+func parseCheck(wildcard, fullPath string, types map[string]func(value string) bool) func(value string) bool {
+	_, constraint := splitConstraint(wildcard)
+	if constraint == "" || constraint[0] == '{' {
+		return nil
+	}
+	if constraint[0] == '<' {
+		typ := constraint[1 : len(constraint)-1]
+		check := paramTypes[typ]
+		if check == nil {
+			check = types[typ]
+		}
+		if check == nil {
+			panic("unknown type in wildcard '" + wildcard + "' in path '" + fullPath + "'")
+		}
+		return check
+	}
+	re, err := regexp.Compile("^(?:" + constraint[1:len(constraint)-1] + ")$")
+	if err != nil {
+		panic("invalid regular expression in wildcard '" + wildcard + "' in path '" + fullPath + "': " + err.Error())
+	}
+	return re.MatchString
+}
+
+// paramName returns the name of the param of a wildcard like @id([0-9]+).
+func paramName(wildcard string) string {
+	name, _ := splitConstraint(wildcard)
+	return name[1:]
+}
+
+// paramKey returns the name of the param node n.
+func (n *node) paramKey() string {
+	if n.enum != nil || n.check != nil {
+		return paramName(n.path)
+	}
+	return n.path[1:]
+}
+
+// accepts reports whether value is a valid value of the param node n.
+func (n *node) accepts(value string) bool {
+	return (n.enum == nil || n.enum[value]) && (n.check == nil || n.check(value))
+}
+
+// enumValue checks value against the enum and the regular expression of the
+// param node n and returns the enum value it matches. Nodes without
+// constraints match any value.
+// If caseInsensitive is set, the value is matched case-insensitively to the
+// enum and the enum value in the registered casing is returned.
+func (n *node) enumValue(value string, caseInsensitive bool) (string, bool) {
+	if n.accepts(value) {
+		return value, true
+	}
+	if caseInsensitive && n.enum != nil {
+		for v := range n.enum {
+			if strings.EqualFold(v, value) && n.accepts(v) {
+				return v, true
+			}
+		}
+	}
+	return "", false
 }
 
 // Increments priority of the given child and reorders if necessary
@@ -104,15 +290,83 @@ func (n *node) incrementChildPrio(pos int) int {
 	return newPos
 }
 
+// dotChild returns the child node which continues a param node with a '.'
+// within the same path segment, e.g. for @name.@ext or @id.json.
+// Returns nil if there is no such child.
+func (n *node) dotChild() *node {
+	if len(n.children) == 0 {
+		return nil
+	}
+	child := n.children[0]
+	if child.path != "" {
+		if child.path[0] == '.' {
+			return child
+		}
+		return nil
+	}
+	for i, c := range []byte(child.indices) {
+		if c == '.' {
+			return child.children[i]
+		}
+	}
+	return nil
+}
+
+// paramEnd returns the end of the value of the param node n in path, which is
+// the next '/' or ':' or the path end.
+// If the param is continued by a '.' within the same segment, the value ends
+// at the last '.' of the segment instead, provided the rest of the segment
+// can match the continuation. E.g. @name.@ext matches "archive.tar.gz" as
+// name="archive.tar" and ext="gz". If the rest of the path does not match
+// below the continuation, the lookup retries with the value up to the end of
+// the segment, see getLeaf.
+func (n *node) paramEnd(path string, caseInsensitive bool) int {
+	end := segmentEnd(path)
+
+	if child := n.dotChild(); child != nil {
+		if dot := strings.LastIndexByte(path[:end], '.'); dot >= 0 {
+			rest := path[dot:]
+			if len(rest) >= len(child.path) &&
+				(rest[:len(child.path)] == child.path ||
+					caseInsensitive && strings.EqualFold(rest[:len(child.path)], child.path)) {
+				return dot
+			}
+		}
+	}
+	return end
+}
+
+// segmentEnd returns the end of the path segment path starts with, which is
+// the next '/' or ':' or the path end.
+func segmentEnd(path string) int {
+	end := 0
+	for end < len(path) && path[end] != '/' && path[end] != ':' {
+		end++
+	}
+	return end
+}
+
+// cutAtDot reports whether the param value path[:end], as returned by
+// paramEnd, was cut at a '.' before the end of the path segment.
+func cutAtDot(path string, end int) bool {
+	return end < len(path) && path[end] == '.'
+}
+
 // addRoute adds a node with the given handle to the path.
 // Not concurrency-safe!
 func (n *node) addRoute(path string, handle Handle) {
+	n.addTypedRoute(path, handle, nil)
+}
+
+// addTypedRoute is like addRoute, but looks up the types of params which are
+// not built in, like @slug<slug>, in types.
+func (n *node) addTypedRoute(path string, handle Handle, types map[string]func(value string) bool) {
 	fullPath := path
 	n.priority++
 
 	// Empty tree
 	if n.path == "" && n.indices == "" {
-		n.insertChild(path, fullPath, handle)
+		n.insertChild(path, fullPath, handle, types)
 		n.nType = root
 		return
 	}
@@ -133,6 +387,7 @@ walk:
 				indices:   n.indices,
 				children:  n.children,
 				handle:    n.handle,
+				route:     n.route,
 				priority:  n.priority - 1,
 			}
 
@@ -141,6 +396,7 @@ walk:
 			n.indices = string([]byte{n.path[i]})
 			n.path = path[:i]
 			n.handle = nil
+			n.route = nil
 			n.wildChild = false
 		}
 
@@ -149,35 +405,45 @@ walk:
 			path = path[i:]
 
 			if n.wildChild {
-				n = n.children[0]
-				n.priority++
-
-				// Check if the wildcard matches
-				if len(path) >= len(n.path) && n.path == path[:len(n.path)] &&
-					// Adding a child to a catchAll is not possible
-					n.nType != catchAll &&
-					// Check for longer wildcard, e.g. :name and :names
-					(len(n.path) >= len(path) || path[len(n.path)] == '/' || path[len(n.path)] == ':') {
-					continue walk
-				} else {
-					// Wildcard conflict
-					pathSeg := path
-					if n.nType != catchAll {
-						pathSeg = strings.SplitN(pathSeg, "/", 2)[0]
+				parent := n
+				for _, child := range parent.children {
+					n = child
+
+					// Check if the wildcard matches
+					if len(path) >= len(n.path) && n.path == path[:len(n.path)] &&
+						// Check for longer wildcard, e.g. :name and :names
+						(len(n.path) >= len(path) || isParamEnd(path[len(n.path)]) &&
+							// A catchAll can only be followed by path segments
+							(n.nType != catchAll || path[len(n.path)] == '/')) {
+						n.priority++
+						continue walk
 					}
-					prefix := fullPath[:strings.Index(fullPath, pathSeg)] + n.path
-					panic("'" + pathSeg +
-						"' in new path '" + fullPath +
-						"' conflicts with existing wildcard '" + n.path +
-						"' in existing prefix '" + prefix +
-						"'")
 				}
+
+				// A param with an enum or a regular expression can be an
+				// alternative to other params at the same position
+				if parent.addAlternative(path, fullPath, handle, types) {
+					return
+				}
+
+				// Wildcard conflict
+				n = parent.children[0]
+				pathSeg := path
+				if n.nType != catchAll {
+					pathSeg = strings.SplitN(pathSeg, "/", 2)[0]
+				}
+				prefix := fullPath[:strings.Index(fullPath, pathSeg)] + n.path
+				panic("'" + pathSeg +
+					"' in new path '" + fullPath +
+					"' conflicts with existing wildcard '" + n.path +
+					"' in existing prefix '" + prefix +
+					"'")
 			}
 
 			idxc := path[0]
 
-			// '/' or ':' after param
-			if n.nType == param && (idxc == '/' || idxc == ':') && len(n.children) == 1 {
+			// '/', ':' or '.' after param
+			if n.nType == param && isParamEnd(idxc) && len(n.children) == 1 {
 				n = n.children[0]
 				n.priority++
 				continue walk
@@ -201,7 +467,7 @@ walk:
 				n.incrementChildPrio(len(n.indices) - 1)
 				n = child
 			}
-			n.insertChild(path, fullPath, handle)
+			n.insertChild(path, fullPath, handle, types)
 			return
 		}
 
@@ -214,7 +480,7 @@ walk:
 	}
 }
 
-func (n *node) insertChild(path, fullPath string, handle Handle) {
+func (n *node) insertChild(path, fullPath string, handle Handle, types map[string]func(value string) bool) {
 	for {
 		// Find prefix until first wildcard
 		wildcard, i, valid := findWildcard(path)
@@ -229,7 +495,9 @@ func (n *node) insertChild(path, fullPath string, handle Handle) {
 		}
 
 		// Check if the wildcard has a name
-		if len(wildcard) < 2 {
+		name, enum := parseEnum(wildcard, fullPath)
+		check := parseCheck(wildcard, fullPath, types)
+		if len(name) < 2 {
 			panic("wildcards must be named with a non-empty name in path '" + fullPath + "'")
 		}
 
@@ -252,13 +520,16 @@ func (n *node) insertChild(path, fullPath string, handle Handle) {
 			child := &node{
 				nType: param,
 				path:  wildcard,
+				enum:  enum,
+				check: check,
 			}
 			n.children = []*node{child}
 			n = child
 			n.priority++
 
 			// If the path doesn't end with the wildcard, then there
-			// will be another non-wildcard subpath starting with '/'
+			// will be another non-wildcard subpath starting with '/',
+			// ':' or '.'
 			if len(wildcard) < len(path) {
 				path = path[len(wildcard):]
 				child := &node{
@@ -275,8 +546,12 @@ func (n *node) insertChild(path, fullPath string, handle Handle) {
 		}
 
 		// catchAll
-		if i+len(wildcard) != len(path) {
-			panic("catch-all routes are only allowed at the end of the path in path '" + fullPath + "'")
+		if enum != nil || check != nil {
+			panic("catch-all routes can't have an enum or a regular expression in path '" + fullPath + "'")
+		}
+		end := i + len(wildcard)
+		if end < len(path) && path[end] != '/' {
+			panic("catch-all routes can only be followed by a path segment in path '" + fullPath + "'")
 		}
 
 		if len(n.path) > 0 && (n.path[len(n.path)-1] == '/' || n.path[len(n.path)-1] == ':') {
@@ -303,13 +578,28 @@ func (n *node) insertChild(path, fullPath string, handle Handle) {
 
 		// Second node: node holding the variable
 		child = &node{
-			path:     path[i:],
+			path:     path[i:end],
 			nType:    catchAll,
-			handle:   handle,
 			priority: 1,
 		}
 		n.children = []*node{child}
+		n = child
 
+		// If the path doesn't end with the catch-all, the rest of the path
+		// follows in a child indexed by the '/' it starts with, e.g. for
+		// /repos/*path/commits
+		if end < len(path) {
+			path = path[end:]
+			child := &node{
+				priority: 1,
+			}
+			n.indices = "/"
+			n.children = []*node{child}
+			n = child
+			continue
+		}
+
+		n.handle = handle
 		return
 	}
 
@@ -318,14 +608,79 @@ func (n *node) insertChild(path, fullPath string, handle Handle) {
 	n.handle = handle
 }
 
+// addAlternative adds the path starting with a param wildcard as an
+// alternative to the param children of n, if both the new param and all
+// children but one have a constraint, like @format{rss|atom} or
+// @id<int>. A value rejected by the constraint of a param, or for which the
+// rest of the path does not match below it, is offered to the next
+// alternative, see getLeaf, where params with constraints come first in the
+// order of their registration.
+// It reports whether the path was added.
+// It panics if the enums of two alternatives share a value.
+func (n *node) addAlternative(path, fullPath string, handle Handle, types map[string]func(value string) bool) bool {
+	if path[0] != '@' || n.children[0].nType != param {
+		return false
+	}
+	wildcard, _, _ := findWildcard(path)
+	_, enum := parseEnum(wildcard, fullPath)
+	constrained := enum != nil || parseCheck(wildcard, fullPath, types) != nil
+
+	i := len(n.children)
+	for k, child := range n.children {
+		if !child.constrained() {
+			if !constrained {
+				return false
+			}
+			i = k
+			break
+		}
+		for value := range enum {
+			if child.enum[value] {
+				panic("enum value '" + value + "' of '" + wildcard + "' in new path '" + fullPath +
+					"' conflicts with existing wildcard '" + child.path + "'")
+			}
+		}
+	}
+
+	tmp := &node{}
+	tmp.insertChild(path, fullPath, handle, types)
+	n.children = append(n.children, nil)
+	copy(n.children[i+1:], n.children[i:])
+	n.children[i] = tmp.children[0]
+	return true
+}
+
+// constrained reports whether the param node n has an enum or a check.
+func (n *node) constrained() bool {
+	return n.enum != nil || n.check != nil
+}
+
 // Returns the handle registered with the given path (key). The values of
 // wildcards are saved to a map.
 // If no handle can be found, a TSR (trailing slash redirect) recommendation is
 // made if a handle exists with an extra (without the) trailing slash for the
 // given path.
-func (n *node) getValue(path string, params func() *Params) (handle Handle, ps *Params, tsr bool) {
+func (n *node) getValue(path string, params func() *Params, allowEmpty bool) (handle Handle, ps *Params, tsr bool) {
+	leaf, ps, tsr := n.getLeaf(path, params, allowEmpty)
+	if leaf != nil {
+		handle = leaf.handle
+	}
+	return
+}
+
+// getLeaf is like getValue, but returns the node holding the handle.
+func (n *node) getLeaf(path string, params func() *Params, allowEmpty bool) (leaf *node, ps *Params, tsr bool) {
+	return n.getLeafDepth(path, params, allowEmpty, nil)
+}
+
+// getLeafDepth is getLeaf, additionally counting the visited nodes in depth,
+// if not nil.
+func (n *node) getLeafDepth(path string, params func() *Params, allowEmpty bool, depth *int) (leaf *node, ps *Params, tsr bool) {
 walk: // Outer loop for walking the tree
 	for {
+		if depth != nil {
+			*depth++
+		}
 		prefix := n.path
 		if len(path) > len(prefix) {
 			if path[:len(prefix)] == prefix {
@@ -351,13 +706,32 @@ walk: // Outer loop for walking the tree
 				}
 
 				// Handle wildcard child
-				n = n.children[0]
+				alternatives := n.children
+				n = alternatives[0]
+				if depth != nil {
+					*depth++
+				}
 				switch n.nType {
 				case param:
-					// Find param end (either '/' or ':' or path end)
-					end := 0
-					for end < len(path) && path[end] != '/' && path[end] != ':' {
-						end++
+					end := n.paramEnd(path, false)
+
+					// A value rejected by the constraint of a param, or for
+					// which the rest of the path does not match below it, is
+					// offered to its alternatives, see addAlternative.
+					// Likewise a value cut at a '.' is retried up to the end
+					// of the path segment, see paramEnd.
+					if len(alternatives) > 1 || cutAtDot(path, end) {
+						if params != nil && ps == nil {
+							ps = params()
+						}
+						leaf, tsr = alternativesLeaf(alternatives, path, ps, allowEmpty, depth)
+						return
+					}
+
+					// Values outside of the enum or not matching the
+					// regular expression don't match
+					if !n.accepts(path[:end]) {
+						return
 					}
 
 					// Save param value
@@ -369,7 +743,7 @@ walk: // Outer loop for walking the tree
 						i := len(*ps)
 						*ps = (*ps)[:i+1]
 						(*ps)[i] = Param{
-							Key:   n.path[1:],
+							Key:   n.paramKey(),
 							Value: path[:end],
 						}
 					}
@@ -387,7 +761,8 @@ walk: // Outer loop for walking the tree
 						return
 					}
 
-					if handle = n.handle; handle != nil {
+					if n.handle != nil {
+						leaf = n
 						return
 					} else if len(n.children) == 1 {
 						// No handle found. Check if a handle for this path + a
@@ -399,13 +774,41 @@ walk: // Outer loop for walking the tree
 					return
 
 				case catchAll:
-					// Save param value
+					i := 0
 					if params != nil {
 						if ps == nil {
 							ps = params()
 						}
+						i = len(*ps)
+					}
+
+					// A catchAll followed by further path segments, e.g.
+					// /repos/*path/commits, takes the longest value for
+					// which the rest of the path matches
+					for end := len(path) - 1; len(n.children) > 0 && end > 1; end-- {
+						if path[end] != '/' {
+							continue
+						}
+						var rest func() *Params
+						if params != nil {
+							*ps = (*ps)[:i+1]
+							(*ps)[i] = Param{
+								Key:   n.path[2:],
+								Value: path[:end],
+							}
+							rest = func() *Params { return ps }
+						}
+						var restTSR bool
+						if leaf, _, restTSR = n.children[0].getLeafDepth(path[end:], rest, allowEmpty, depth); leaf != nil {
+							tsr = false
+							return
+						}
+						tsr = tsr || restTSR
+					}
+
+					// Save param value
+					if params != nil {
 						// Expand slice within preallocated capacity
-						i := len(*ps)
 						*ps = (*ps)[:i+1]
 						(*ps)[i] = Param{
 							Key:   n.path[2:],
@@ -413,7 +816,10 @@ walk: // Outer loop for walking the tree
 						}
 					}
 
-					handle = n.handle
+					if n.handle != nil {
+						leaf = n
+						tsr = false
+					}
 					return
 
 				default:
@@ -423,10 +829,33 @@ walk: // Outer loop for walking the tree
 		} else if path == prefix {
 			// We should have reached the node containing the handle.
 			// Check if this node has a handle registered.
-			if handle = n.handle; handle != nil {
+			if n.handle != nil {
+				leaf = n
 				return
 			}
 
+			// An empty last path segment may be the value of a param
+			if allowEmpty && n.wildChild && len(path) > 0 && path[len(path)-1] == '/' {
+				for _, child := range n.children {
+					if child.nType != param || !child.accepts("") {
+						continue
+					}
+					if child.handle != nil {
+						if params != nil {
+							if ps == nil {
+								ps = params()
+							}
+							i := len(*ps)
+							*ps = (*ps)[:i+1]
+							(*ps)[i] = Param{Key: child.paramKey()}
+						}
+						leaf = child
+						return
+					}
+					break
+				}
+			}
+
 			// If there is no handle for this route, but this route has a
 			// wildcard child, there must be a handle for this path with an
 			// additional trailing slash
@@ -457,6 +886,111 @@ walk: // Outer loop for walking the tree
 	}
 }
 
+// alternativesLeaf looks up path in the alternative param nodes in turn, see
+// addAlternative, and returns the first leaf found below a param accepting its
+// value. A value cut at a '.', see paramEnd, is retried up to the end of the
+// path segment if the rest of the path does not match below the param. The
+// value of the param is saved to ps, if not nil.
+func alternativesLeaf(alternatives []*node, path string, ps *Params, allowEmpty bool, depth *int) (leaf *node, tsr bool) {
+	i := 0
+	var rest func() *Params
+	if ps != nil {
+		i = len(*ps)
+		rest = func() *Params { return ps }
+	}
+	for k, n := range alternatives {
+		if depth != nil && k > 0 {
+			*depth++
+		}
+		for end := n.paramEnd(path, false); ; end = segmentEnd(path) {
+			if n.accepts(path[:end]) {
+				if ps != nil {
+					*ps = (*ps)[:i+1]
+					(*ps)[i] = Param{
+						Key:   n.paramKey(),
+						Value: path[:end],
+					}
+				}
+				var paramTSR bool
+				if leaf, paramTSR = n.paramLeaf(path, end, rest, allowEmpty, depth); leaf != nil {
+					return leaf, false
+				}
+				tsr = tsr || paramTSR
+			}
+			if !cutAtDot(path, end) {
+				break
+			}
+		}
+	}
+	if ps != nil {
+		*ps = (*ps)[:i]
+	}
+	return nil, tsr
+}
+
+// paramLeaf looks up the rest of path below the param node n, whose value
+// ends at byte end of path, like getLeaf.
+func (n *node) paramLeaf(path string, end int, params func() *Params, allowEmpty bool, depth *int) (leaf *node, tsr bool) {
+	if end < len(path) {
+		if len(n.children) > 0 {
+			leaf, _, tsr = n.children[0].getLeafDepth(path[end:], params, allowEmpty, depth)
+			return
+		}
+		return nil, len(path) == end+1
+	}
+
+	if n.handle != nil {
+		return n, false
+	}
+	if len(n.children) == 1 {
+		// No handle found. Check if a handle for this path + a trailing
+		// slash exists for TSR recommendation
+		child := n.children[0]
+		tsr = (child.path == "/" && child.handle != nil) || (child.path == "" && child.indices == "/")
+	}
+	return nil, tsr
+}
+
+// patternNode returns the node of the given route path, as added by addRoute,
+// or nil if there is no such node.
+func (n *node) patternNode(path string) *node {
+walk:
+	for {
+		if !strings.HasPrefix(path, n.path) {
+			return nil
+		}
+		path = path[len(n.path):]
+		if path == "" {
+			return n
+		}
+
+		if n.wildChild {
+			for _, child := range n.children {
+				if strings.HasPrefix(path, child.path) &&
+					(len(path) == len(child.path) || isParamEnd(path[len(child.path)])) {
+					n = child
+					continue walk
+				}
+			}
+			return nil
+		}
+		for i, c := range []byte(n.indices) {
+			if c == path[0] {
+				n = n.children[i]
+				continue walk
+			}
+		}
+		// The children of param nodes are not indexed
+		for _, child := range n.children {
+			if n.indices == "" && strings.HasPrefix(path, child.path) {
+				n = child
+				continue walk
+			}
+		}
+		return nil
+	}
+}
+
 // Makes a case-insensitive lookup of the given path and tries to find a handler.
 // It can optionally also fix trailing slashes.
 // It returns the case-corrected path and a bool indicating whether the lookup
@@ -534,7 +1068,12 @@ walk: // Outer loop for walking the tree
 					// Find rune start.
 					// Runes are up to 4 byte long,
 					// -4 would definitely be another rune.
+					// Nodes with an empty path, like the continuations of a
+					// param, start with a new rune.
 					var off int
+					if npLen == 0 {
+						rv, _ = utf8.DecodeRuneInString(path)
+					}
 					for max := min(npLen, 3); off < max; off++ {
 						if i := npLen - off; utf8.RuneStart(oldPath[i]) {
 							// read rune from cached path
@@ -593,48 +1132,45 @@ walk: // Outer loop for walking the tree
 				return nil
 			}
 
-			n = n.children[0]
+			alternatives := n.children
+			n = alternatives[0]
 			switch n.nType {
 			case param:
-				// Find param end (either '/' or ':' or path end)
-				end := 0
-				for end < len(path) && path[end] != '/' && path[end] != ':' {
-					end++
-				}
-
-				// Add param value to case insensitive path
-				ciPath = append(ciPath, path[:end]...)
-
-				// We need to go deeper!
-				if end < len(path) {
-					if len(n.children) > 0 {
-						// Continue with child node
-						n = n.children[0]
-						npLen = len(n.path)
-						path = path[end:]
-						continue
-					}
-
-					// ... but we can't
-					if fixTrailingSlash && len(path) == end+1 {
-						return ciPath
-					}
-					return nil
-				}
-
-				if n.handle != nil {
-					return ciPath
-				} else if fixTrailingSlash && len(n.children) == 1 {
-					// No handle found. Check if a handle for this path + a
-					// trailing slash exists
-					n = n.children[0]
-					if (n.path == "/" || n.path == ":") && n.handle != nil {
-						return append(ciPath, n.path[0])
+				// A value rejected by the constraint of a param, or for which
+				// the rest of the path does not match below it, is offered to
+				// its alternatives, and a value cut at a '.' is retried up to
+				// the end of the path segment, see getLeaf
+				for _, alt := range alternatives {
+					for end := alt.paramEnd(path, true); ; end = segmentEnd(path) {
+						// Add param value to case insensitive path
+						if value, ok := alt.enumValue(path[:end], true); ok {
+							if out := alt.findCaseInsensitiveParamRec(
+								path, end, append(ciPath, value...), fixTrailingSlash,
+							); out != nil {
+								return out
+							}
+						}
+						if !cutAtDot(path, end) {
+							break
+						}
 					}
 				}
 				return nil
 
 			case catchAll:
+				// A catchAll followed by further path segments, see getLeaf
+				for end := len(path) - 1; len(n.children) > 0 && end > 1; end-- {
+					if path[end] == '/' {
+						if out := n.children[0].findCaseInsensitivePathRec(
+							path[end:], append(ciPath, path[:end]...), [4]byte{}, fixTrailingSlash,
+						); out != nil {
+							return out
+						}
+					}
+				}
+				if n.handle == nil {
+					return nil
+				}
 				return append(ciPath, path...)
 
 			default:
@@ -678,3 +1214,228 @@ walk: // Outer loop for walking the tree
 	}
 	return nil
 }
+
+// findCaseInsensitiveParamRec continues n.findCaseInsensitivePathRec below
+// the param node n, whose value ends at byte end of path.
+func (n *node) findCaseInsensitiveParamRec(path string, end int, ciPath []byte, fixTrailingSlash bool) []byte {
+	// We need to go deeper!
+	if end < len(path) {
+		if len(n.children) > 0 {
+			return n.children[0].findCaseInsensitivePathRec(
+				path[end:], ciPath, [4]byte{}, fixTrailingSlash,
+			)
+		}
+
+		// ... but we can't
+		if fixTrailingSlash && len(path) == end+1 {
+			return ciPath
+		}
+		return nil
+	}
+
+	if n.handle != nil {
+		return ciPath
+	} else if fixTrailingSlash && len(n.children) == 1 {
+		// No handle found. Check if a handle for this path + a
+		// trailing slash exists
+		child := n.children[0]
+		if (child.path == "/" || child.path == ":") && child.handle != nil {
+			return append(ciPath, child.path[0])
+		}
+	}
+	return nil
+}
+
+// Makes a case-insensitive lookup of the given path like
+// findCaseInsensitivePath, but compares runes by Unicode case folding.
+// Unlike findCaseInsensitivePath it also matches case variants of a rune which
+// are encoded with a different number of bytes, e.g. 'ẞ' and 'ß' or 'K'
+// (Kelvin sign) and 'k'.
+func (n *node) findCaseFoldPath(path string, fixTrailingSlash bool) (fixedPath string, found bool) {
+	ciPath := n.findCaseFoldPathRec(0, path, make([]byte, 0, len(path)+1), fixTrailingSlash)
+	return string(ciPath), ciPath != nil
+}
+
+// Recursive lookup function used by n.findCaseFoldPath. The lookup continues
+// at byte i of the path of n.
+func (n *node) findCaseFoldPathRec(i int, path string, ciPath []byte, fixTrailingSlash bool) []byte {
+	for len(path) > 0 {
+		if i == len(n.path) {
+			if n.wildChild {
+				child := n.children[0]
+				switch child.nType {
+				case param:
+					// A value rejected by the constraint of a param, or for
+					// which the rest of the path does not match below it, is
+					// offered to its alternatives, and a value cut at a '.'
+					// is retried up to the end of the path segment, see
+					// getLeaf
+					for _, alt := range n.children {
+						for end := alt.paramEnd(path, true); ; end = segmentEnd(path) {
+							// Add param value to case insensitive path
+							if value, ok := alt.enumValue(path[:end], true); ok {
+								if out := alt.findCaseFoldParamRec(
+									path, end, append(ciPath, value...), fixTrailingSlash,
+								); out != nil {
+									return out
+								}
+							}
+							if !cutAtDot(path, end) {
+								break
+							}
+						}
+					}
+					return nil
+
+				case catchAll:
+					// A catchAll followed by further path segments, see getLeaf
+					for end := len(path) - 1; len(child.children) > 0 && end > 1; end-- {
+						if path[end] == '/' {
+							if out := child.children[0].findCaseFoldPathRec(
+								0, path[end:], append(ciPath, path[:end]...), fixTrailingSlash,
+							); out != nil {
+								return out
+							}
+						}
+					}
+					if child.handle == nil {
+						return nil
+					}
+					return append(ciPath, path...)
+
+				default:
+					panic("invalid node type")
+				}
+			}
+
+			// The catchAll node is indexed by the '/' it starts with
+			if path[0] == '/' {
+				for k, c := range []byte(n.indices) {
+					if c == '/' && n.children[k].nType == catchAll {
+						return n.children[k].findCaseFoldPathRec(0, path, ciPath, fixTrailingSlash)
+					}
+				}
+			}
+		}
+
+		// Try all case variants of the next rune. Invalid UTF-8 is matched
+		// byte by byte.
+		rv, size := utf8.DecodeRuneInString(path)
+		if rv == utf8.RuneError && size == 1 {
+			if m, j, ok := n.walkStatic(i, []byte(path[:1])); ok {
+				return m.findCaseFoldPathRec(j, path[1:], append(ciPath, path[0]), fixTrailingSlash)
+			}
+		} else {
+			var rb [utf8.UTFMax]byte
+			for fv := rv; ; {
+				b := rb[:utf8.EncodeRune(rb[:], fv)]
+				if m, j, ok := n.walkStatic(i, b); ok {
+					if out := m.findCaseFoldPathRec(
+						j, path[size:], append(ciPath, b...), fixTrailingSlash,
+					); out != nil {
+						return out
+					}
+				}
+				if fv = unicode.SimpleFold(fv); fv == rv {
+					break
+				}
+			}
+		}
+
+		// Nothing found. We can recommend to redirect to the same URL
+		// without a trailing slash if a leaf exists for that path
+		if fixTrailingSlash && path == "/" && i == len(n.path) && n.handle != nil {
+			return ciPath
+		}
+		return nil
+	}
+
+	// We should have reached the node containing the handle.
+	if i == len(n.path) {
+		if n.handle != nil {
+			return ciPath
+		}
+
+		// No handle found.
+		// Try to fix the path by adding a trailing slash
+		if fixTrailingSlash {
+			for k, c := range []byte(n.indices) {
+				if c == '/' {
+					child := n.children[k]
+					if (len(child.path) == 1 && child.handle != nil) ||
+						(child.nType == catchAll && child.children[0].handle != nil) {
+						return append(ciPath, '/')
+					}
+					return nil
+				}
+			}
+		}
+		return nil
+	}
+
+	// Try to fix the path by adding a trailing slash
+	if fixTrailingSlash && n.path[i:] == "/" && n.handle != nil {
+		return append(ciPath, '/')
+	}
+	return nil
+}
+
+// findCaseFoldParamRec continues n.findCaseFoldPathRec below the param node
+// n, whose value ends at byte end of path.
+func (n *node) findCaseFoldParamRec(path string, end int, ciPath []byte, fixTrailingSlash bool) []byte {
+	// We need to go deeper!
+	if end < len(path) {
+		if len(n.children) > 0 {
+			return n.children[0].findCaseFoldPathRec(0, path[end:], ciPath, fixTrailingSlash)
+		}
+
+		// ... but we can't
+		if fixTrailingSlash && len(path) == end+1 {
+			return ciPath
+		}
+		return nil
+	}
+
+	if n.handle != nil {
+		return ciPath
+	} else if fixTrailingSlash && len(n.children) == 1 {
+		// No handle found. Check if a handle for this path + a
+		// trailing slash exists
+		child := n.children[0]
+		if (child.path == "/" || child.path == ":") && child.handle != nil {
+			return append(ciPath, child.path[0])
+		}
+	}
+	return nil
+}
+
+// walkStatic walks the static path bytes b down the tree, starting at byte i
+// of the path of n. It returns the node and the position within its path at
+// which b ends.
+func (n *node) walkStatic(i int, b []byte) (*node, int, bool) {
+	for len(b) > 0 {
+		if i == len(n.path) {
+			if n.wildChild {
+				return nil, 0, false
+			}
+			next := -1
+			for k, c := range []byte(n.indices) {
+				if c == b[0] {
+					next = k
+					break
+				}
+			}
+			if next < 0 || n.children[next].nType == catchAll {
+				return nil, 0, false
+			}
+			n, i = n.children[next], 0
+			continue
+		}
+		if n.path[i] != b[0] {
+			return nil, 0, false
+		}
+		i++
+		b = b[1:]
+	}
+	return n, i, true
+}
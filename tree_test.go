@@ -46,7 +46,7 @@ func getParams() *Params {
 
 func checkRequests(t *testing.T, tree *node, requests testRequests) {
 	for _, request := range requests {
-		handler, psp, _ := tree.getValue(request.path, getParams)
+		handler, psp, _ := tree.getValue(request.path, getParams, false)
 
 		switch {
 		case handler == nil:
@@ -204,6 +204,61 @@ func TestTreeWildcardWithVerbs(t *testing.T) {
 	checkPriorities(t, tree)
 }
 
+func TestTreeWildcardWithExtension(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/report",
+		"/report.@format",
+		"/files/@name.@ext",
+		"/files/@name.@ext/meta",
+		"/docs/@name",
+		"/docs/@name.json",
+		"/docs/@name:verb",
+		"/docs/@name/raw",
+		"/reports/@id.json",
+		"/reports/@id.csv",
+	}
+	for _, route := range routes {
+		tree.addRoute(route, fakeHandler(route))
+	}
+
+	// printChildren(tree, "")
+
+	checkRequests(t, tree, testRequests{
+		{"/report", false, "/report", nil},
+		{"/report.json", false, "/report.@format", Params{Param{"format", "json"}}},
+		{"/report.csv", false, "/report.@format", Params{Param{"format", "csv"}}},
+		{"/files/photo.png", false, "/files/@name.@ext", Params{Param{"name", "photo"}, Param{"ext", "png"}}},
+		{"/files/archive.tar.gz", false, "/files/@name.@ext", Params{Param{"name", "archive.tar"}, Param{"ext", "gz"}}},
+		{"/files/photo.png/meta", false, "/files/@name.@ext/meta", Params{Param{"name", "photo"}, Param{"ext", "png"}}},
+		{"/files/photo", true, "", Params{Param{"name", "photo"}}}, // no dot
+		{"/docs/readme", false, "/docs/@name", Params{Param{"name", "readme"}}},
+		{"/docs/readme.json", false, "/docs/@name.json", Params{Param{"name", "readme"}}},
+		{"/docs/readme.txt", false, "/docs/@name", Params{Param{"name", "readme.txt"}}},
+		{"/docs/readme:verb", false, "/docs/@name:verb", Params{Param{"name", "readme"}}},
+		{"/docs/readme.txt/raw", false, "/docs/@name/raw", Params{Param{"name", "readme.txt"}}},
+		{"/docs/readme.json/raw", false, "/docs/@name/raw", Params{Param{"name", "readme.json"}}},
+		{"/docs/readme.json:verb", false, "/docs/@name:verb", Params{Param{"name", "readme.json"}}},
+		{"/reports/42.json", false, "/reports/@id.json", Params{Param{"id", "42"}}},
+		{"/reports/42.csv", false, "/reports/@id.csv", Params{Param{"id", "42"}}},
+		{"/reports/4.2.csv", false, "/reports/@id.csv", Params{Param{"id", "4.2"}}},
+		{"/reports/42.xml", true, "", Params{}},
+		{"/reports/42", true, "", Params{Param{"id", "42"}}},
+	})
+
+	checkPriorities(t, tree)
+
+	for _, find := range []func(n *node, path string, fixTrailingSlash bool) (string, bool){
+		(*node).findCaseInsensitivePath,
+		(*node).findCaseFoldPath,
+	} {
+		if out, found := find(tree, "/DOCS/Readme.JSON/RAW", true); !found || out != "/docs/Readme.JSON/raw" {
+			t.Errorf("Wrong result for '/DOCS/Readme.JSON/RAW': got %s, %t", out, found)
+		}
+	}
+}
+
 func catchPanic(testFunc func()) (recv interface{}) {
 	defer func() {
 		recv = recover()
@@ -337,15 +392,83 @@ func TestEmptyWildcardName(t *testing.T) {
 
 func TestTreeCatchAllConflict(t *testing.T) {
 	routes := []testRoute{
-		{"/src/*filepath/x", true},
+		{"/src/*filepath/x", false},
+		{"/src/*filepath/y", false},
+		{"/src/*other/z", true},
+		{"/src/*filepath.x", true},
+		{"/src/*filepathx/x", true},
 		{"/src2/", false},
 		{"/src2/*filepath/x", true},
 		{"/src3/*filepath", false},
-		{"/src3/*filepath/x", true},
+		{"/src3/*filepath/x", false},
 	}
 	testRoutes(t, routes)
 }
 
+func TestTreeMidPathCatchAll(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/repos/*path/commits",
+		"/repos/*path/commits/@sha",
+		"/repos/*path/tree/*file",
+		"/repos/*path",
+		"/blobs/*path/raw",
+	}
+	for _, route := range routes {
+		tree.addRoute(route, fakeHandler(route))
+	}
+
+	checkRequests(t, tree, testRequests{
+		{"/repos/gopher/router/commits", false, "/repos/*path/commits", Params{Param{"path", "/gopher/router"}}},
+		{"/repos/a/commits/b/commits", false, "/repos/*path/commits", Params{Param{"path", "/a/commits/b"}}},
+		{"/repos/a/commits/0ab1", false, "/repos/*path/commits/@sha", Params{Param{"path", "/a"}, Param{"sha", "0ab1"}}},
+		{"/repos/a/b/tree/x/y.go", false, "/repos/*path/tree/*file", Params{Param{"path", "/a/b"}, Param{"file", "/x/y.go"}}},
+		{"/repos/a/b", false, "/repos/*path", Params{Param{"path", "/a/b"}}},
+		{"/repos/commits", false, "/repos/*path", Params{Param{"path", "/commits"}}},
+		{"/blobs/a/b/raw", false, "/blobs/*path/raw", Params{Param{"path", "/a/b"}}},
+		{"/blobs/raw", true, "", Params{Param{"path", "/raw"}}},
+		{"/blobs/a/b", true, "", Params{Param{"path", "/a/b"}}},
+	})
+
+	checkPriorities(t, tree)
+
+	for _, route := range routes {
+		if recv := catchPanic(func() { tree.addRoute(route, fakeHandler(route)) }); recv == nil {
+			t.Errorf("no panic while inserting duplicate route '%s'", route)
+		}
+	}
+
+	for _, test := range []struct {
+		path string
+		tsr  bool
+	}{
+		{"/blobs/a/b/raw/", true},
+		{"/blobs/a/b/raw/x", false},
+	} {
+		if handler, _, tsr := tree.getValue(test.path, nil, false); handler != nil || tsr != test.tsr {
+			t.Errorf("wrong result for %q: handler=%v, tsr=%v", test.path, handler != nil, tsr)
+		}
+	}
+
+	for _, test := range []struct {
+		path, fixed string
+	}{
+		{"/REPOS/A/B/COMMITS", "/repos/A/B/commits"},
+		{"/Blobs/A/Raw", "/blobs/A/raw"},
+	} {
+		if out, found := tree.findCaseInsensitivePath(test.path, false); !found || out != test.fixed {
+			t.Errorf("findCaseInsensitivePath(%q) = %q, %v, want %q", test.path, out, found, test.fixed)
+		}
+		if out, found := tree.findCaseFoldPath(test.path, false); !found || out != test.fixed {
+			t.Errorf("findCaseFoldPath(%q) = %q, %v, want %q", test.path, out, found, test.fixed)
+		}
+	}
+	if out, found := tree.findCaseInsensitivePath("/blobs/a/b", false); found {
+		t.Errorf("found %q for a path not matching the rest of the route", out)
+	}
+}
+
 func TestTreeCatchAllConflictRoot(t *testing.T) {
 	routes := []testRoute{
 		{"/", false},
@@ -442,7 +565,7 @@ func TestTreeTrailingSlashRedirect(t *testing.T) {
 		"/vendor/x",
 	}
 	for _, route := range tsrRoutes {
-		handler, _, tsr := tree.getValue(route, nil)
+		handler, _, tsr := tree.getValue(route, nil, false)
 		if handler != nil {
 			t.Fatalf("non-nil handler for TSR route '%s", route)
 		} else if !tsr {
@@ -459,7 +582,7 @@ func TestTreeTrailingSlashRedirect(t *testing.T) {
 		"/api/world/abc",
 	}
 	for _, route := range noTsrRoutes {
-		handler, _, tsr := tree.getValue(route, nil)
+		handler, _, tsr := tree.getValue(route, nil, false)
 		if handler != nil {
 			t.Fatalf("non-nil handler for No-TSR route '%s", route)
 		} else if tsr {
@@ -478,7 +601,7 @@ func TestTreeRootTrailingSlashRedirect(t *testing.T) {
 		t.Fatalf("panic inserting test route: %v", recv)
 	}
 
-	handler, _, tsr := tree.getValue("/", nil)
+	handler, _, tsr := tree.getValue("/", nil, false)
 	if handler != nil {
 		t.Fatalf("non-nil handler")
 	} else if tsr {
@@ -649,6 +772,69 @@ func TestTreeFindCaseInsensitivePath(t *testing.T) {
 	}
 }
 
+func TestTreeFindCaseFoldPath(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/café",
+		"/straße/@nr",
+		"/kelvin/",
+		"/ǆ/@id.@ext",
+		"/files/*filepath",
+	}
+	for _, route := range routes {
+		tree.addRoute(route, fakeHandler(route))
+	}
+
+	tests := []struct {
+		in    string
+		out   string
+		found bool
+		slash bool
+	}{
+		{"/CAFÉ", "/café", true, false},
+		{"/Café/", "/café", true, true},
+		{"/STRAẞE/7", "/straße/7", true, false},
+		{"/STRAẞE/7/", "/straße/7", true, true},
+		{"/Kelvin/", "/kelvin/", true, false}, // Kelvin sign
+		{"/KELVIN", "/kelvin/", true, true},
+		{"/ǅ/Doc.PDF", "/ǆ/Doc.PDF", true, false},
+		{"/ǅ/", "", false, false},
+		{"/FILES/A/B", "/files/A/B", true, false},
+		{"/caf\xe9", "", false, false}, // invalid UTF-8
+		{"/cafe", "", false, false},
+	}
+	// With fixTrailingSlash = true
+	for _, test := range tests {
+		out, found := tree.findCaseFoldPath(test.in, true)
+		if found != test.found || (found && (out != test.out)) {
+			t.Errorf("Wrong result for '%s': got %s, %t; want %s, %t",
+				test.in, out, found, test.out, test.found)
+			return
+		}
+	}
+	// With fixTrailingSlash = false
+	for _, test := range tests {
+		out, found := tree.findCaseFoldPath(test.in, false)
+		if test.slash {
+			if found { // test needs a trailingSlash fix. It must not be found!
+				t.Errorf("Found without fixTrailingSlash: %s; got %s", test.in, out)
+			}
+		} else {
+			if found != test.found || (found && (out != test.out)) {
+				t.Errorf("Wrong result for '%s': got %s, %t; want %s, %t",
+					test.in, out, found, test.out, test.found)
+				return
+			}
+		}
+	}
+
+	// the byte based lookup does not match variants with a different length
+	if out, found := tree.findCaseInsensitivePath("/STRAẞE/7", true); found {
+		t.Errorf("Unexpected result for '/STRAẞE/7': got %s", out)
+	}
+}
+
 func TestTreeInvalidNodeType(t *testing.T) {
 	const panicMsg = "invalid node type"
 
@@ -661,7 +847,7 @@ func TestTreeInvalidNodeType(t *testing.T) {
 
 	// normal lookup
 	recv := catchPanic(func() {
-		tree.getValue("/test", nil)
+		tree.getValue("/test", nil, false)
 	})
 	if rs, ok := recv.(string); !ok || rs != panicMsg {
 		t.Fatalf("Expected panic '"+panicMsg+"', got '%v'", recv)
@@ -717,3 +903,365 @@ func TestTreeWildcardConflictEx(t *testing.T) {
 		}
 	}
 }
+
+func TestTreeWildcardWithStaticPrefix(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/v@version/users",
+		"/v@version/users/@id",
+		"/user-@id",
+		"/user-@id/profile",
+	}
+	for _, route := range routes {
+		tree.addRoute(route, fakeHandler(route))
+	}
+
+	checkRequests(t, tree, testRequests{
+		{"/v1/users", false, "/v@version/users", Params{Param{"version", "1"}}},
+		{"/v2/users/42", false, "/v@version/users/@id", Params{Param{"version", "2"}, Param{"id", "42"}}},
+		{"/v/users", false, "/v@version/users", Params{Param{"version", ""}}},
+		{"/x1/users", true, "", nil},
+		{"/user-42", false, "/user-@id", Params{Param{"id", "42"}}},
+		{"/user-42/profile", false, "/user-@id/profile", Params{Param{"id", "42"}}},
+		{"/user42", true, "", nil},
+	})
+
+	checkPriorities(t, tree)
+
+	// a static prefix within a segment excludes static routes sharing it
+	recv := catchPanic(func() {
+		tree.addRoute("/vx/users", fakeHandler("/vx/users"))
+	})
+	if recv == nil {
+		t.Error("no panic while inserting static route conflicting with prefixed param")
+	}
+
+	// a constraint of the param applies to the value after the prefix
+	tree = &node{}
+	tree.addRoute("/v@version([0-9]+)/users", fakeHandler("/v@version([0-9]+)/users"))
+	checkRequests(t, tree, testRequests{
+		{"/v1/users", false, "/v@version([0-9]+)/users", Params{Param{"version", "1"}}},
+		{"/v12/users", false, "/v@version([0-9]+)/users", Params{Param{"version", "12"}}},
+		{"/vx/users", true, "", nil},
+		{"/v/users", true, "", nil},
+	})
+}
+
+func TestTreeWildcardWithEnum(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/status/@state{open|closed|pending}",
+		"/status/@state{open|closed|pending}/history",
+		"/feed.@format{rss|atom}",
+		"/api/@version{v1.0|v2.0}/ping",
+	}
+	for _, route := range routes {
+		tree.addRoute(route, fakeHandler(route))
+	}
+
+	checkRequests(t, tree, testRequests{
+		{"/status/open", false, "/status/@state{open|closed|pending}", Params{Param{"state", "open"}}},
+		{"/status/pending/history", false, "/status/@state{open|closed|pending}/history", Params{Param{"state", "pending"}}},
+		{"/status/archived", true, "", nil},
+		{"/status/archived/history", true, "", nil},
+		{"/status/Open", true, "", nil},
+		{"/feed.rss", false, "/feed.@format{rss|atom}", Params{Param{"format", "rss"}}},
+		{"/feed.json", true, "", nil},
+		{"/api/v2.0/ping", false, "/api/@version{v1.0|v2.0}/ping", Params{Param{"version", "v2.0"}}},
+		{"/api/v3.0/ping", true, "", nil},
+	})
+
+	checkPriorities(t, tree)
+
+	for _, find := range []func(n *node, path string, fixTrailingSlash bool) (string, bool){
+		(*node).findCaseInsensitivePath,
+		(*node).findCaseFoldPath,
+	} {
+		if out, found := find(tree, "/STATUS/OPEN", true); !found || out != "/status/open" {
+			t.Errorf("Wrong result for '/STATUS/OPEN': got %s, %t", out, found)
+		}
+		if out, found := find(tree, "/STATUS/ARCHIVED", true); found {
+			t.Errorf("Unexpected result for '/STATUS/ARCHIVED': got %s", out)
+		}
+	}
+
+	for _, route := range []string{
+		"/status/@state{open}", // overlapping enum
+		"/status/@state{}",     // empty enum
+		"/x/@state{a||b}",      // empty enum value
+		"/x/@state{a|b",        // unterminated enum
+		"/x/@state{a}b",        // trailing chars
+		"/x/@{a|b}",            // no name
+		"/x/*filepath{a|b}",    // catch-all with enum
+	} {
+		recv := catchPanic(func() {
+			tree.addRoute(route, nil)
+		})
+		if recv == nil {
+			t.Errorf("no panic while inserting route with invalid or conflicting enum '%s'", route)
+		}
+	}
+}
+
+func TestTreeWildcardWithRegexp(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/users/@id([0-9]+)",
+		"/users/@id([0-9]+)/posts",
+		"/dates/@date([0-9]{4}-[0-9]{2}-[0-9]{2})",
+		"/files/@name([a-z]+(\\.[a-z]+)?)/raw",
+		"/feed.@format(rss|atom)",
+		"/tags/@tag(a/b|c?)",
+	}
+	for _, route := range routes {
+		tree.addRoute(route, fakeHandler(route))
+	}
+
+	checkRequests(t, tree, testRequests{
+		{"/users/42", false, "/users/@id([0-9]+)", Params{Param{"id", "42"}}},
+		{"/users/42/posts", false, "/users/@id([0-9]+)/posts", Params{Param{"id", "42"}}},
+		{"/users/abc", true, "", nil},
+		{"/users/42abc", true, "", nil},
+		{"/users/abc/posts", true, "", nil},
+		{"/dates/2024-01-31", false, "/dates/@date([0-9]{4}-[0-9]{2}-[0-9]{2})", Params{Param{"date", "2024-01-31"}}},
+		{"/dates/24-01-31", true, "", nil},
+		{"/files/notes.txt/raw", false, "/files/@name([a-z]+(\\.[a-z]+)?)/raw", Params{Param{"name", "notes.txt"}}},
+		{"/files/notes/raw", false, "/files/@name([a-z]+(\\.[a-z]+)?)/raw", Params{Param{"name", "notes"}}},
+		{"/files/Notes/raw", true, "", nil},
+		{"/feed.atom", false, "/feed.@format(rss|atom)", Params{Param{"format", "atom"}}},
+		{"/feed.json", true, "", nil},
+		{"/tags/c", false, "/tags/@tag(a/b|c?)", Params{Param{"tag", "c"}}},
+	})
+
+	checkPriorities(t, tree)
+
+	for _, route := range []string{
+		"/x/@id([0-9]+",       // unterminated regular expression
+		"/x/@id([0-9]+)b",     // trailing chars
+		"/x/@id([)",           // invalid regular expression
+		"/x/@([0-9]+)",        // no name
+		"/x/*filepath([a-z])", // catch-all with regular expression
+	} {
+		recv := catchPanic(func() {
+			tree.addRoute(route, nil)
+		})
+		if recv == nil {
+			t.Errorf("no panic while inserting route with invalid or conflicting regular expression '%s'", route)
+		}
+	}
+}
+
+func TestTreeWildcardWithType(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/orders/@id<int>",
+		"/orders/@id<int>/items/@n<uint>",
+		"/files/@key<uuid>",
+		"/colors/@rgb<hex>.png",
+		"/tags/@tag<alpha>",
+		"/codes/@code<alnum>",
+	}
+	for _, route := range routes {
+		tree.addRoute(route, fakeHandler(route))
+	}
+
+	checkRequests(t, tree, testRequests{
+		{"/orders/42", false, "/orders/@id<int>", Params{Param{"id", "42"}}},
+		{"/orders/-42", false, "/orders/@id<int>", Params{Param{"id", "-42"}}},
+		{"/orders/4x2", true, "", nil},
+		{"/orders/-", true, "", nil},
+		{"/orders/42/items/3", false, "/orders/@id<int>/items/@n<uint>", Params{Param{"id", "42"}, Param{"n", "3"}}},
+		{"/orders/42/items/-3", true, "", Params{Param{"id", "42"}}},
+		{"/files/123e4567-e89b-12d3-a456-426614174000", false, "/files/@key<uuid>", Params{Param{"key", "123e4567-e89b-12d3-a456-426614174000"}}},
+		{"/files/123e4567-e89b-12d3-a456-42661417400", true, "", nil},
+		{"/files/123e4567xe89b-12d3-a456-426614174000", true, "", nil},
+		{"/colors/ff00AA.png", false, "/colors/@rgb<hex>.png", Params{Param{"rgb", "ff00AA"}}},
+		{"/colors/gg0000.png", true, "", Params{}},
+		{"/tags/Go", false, "/tags/@tag<alpha>", Params{Param{"tag", "Go"}}},
+		{"/tags/go1", true, "", nil},
+		{"/codes/abc123", false, "/codes/@code<alnum>", Params{Param{"code", "abc123"}}},
+		{"/codes/abc-123", true, "", nil},
+	})
+
+	checkPriorities(t, tree)
+
+	for _, route := range []string{
+		"/x/@id<int",          // unterminated type
+		"/x/@id<int>b",        // trailing chars
+		"/x/@id<float>",       // unknown type
+		"/x/@<int>",           // no name
+		"/x/*filepath<alpha>", // catch-all with type
+	} {
+		recv := catchPanic(func() {
+			tree.addRoute(route, nil)
+		})
+		if recv == nil {
+			t.Errorf("no panic while inserting route with invalid or conflicting type '%s'", route)
+		}
+	}
+}
+
+func TestTreeWildcardAlternatives(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/feed/@format{rss|atom|json}",
+		"/feed/@format{rss|atom|json}/latest",
+		"/feed/@name",
+		"/feed/@name/items",
+		"/feed/@id<int>",
+		"/users/@id([0-9]+)",
+		"/users/@name([a-z]+)/posts",
+	}
+	for _, route := range routes {
+		tree.addRoute(route, fakeHandler(route))
+	}
+
+	checkRequests(t, tree, testRequests{
+		{"/feed/rss", false, "/feed/@format{rss|atom|json}", Params{Param{"format", "rss"}}},
+		{"/feed/rss/latest", false, "/feed/@format{rss|atom|json}/latest", Params{Param{"format", "rss"}}},
+		{"/feed/42", false, "/feed/@id<int>", Params{Param{"id", "42"}}},
+		{"/feed/xml", false, "/feed/@name", Params{Param{"name", "xml"}}},
+		{"/feed/xml/items", false, "/feed/@name/items", Params{Param{"name", "xml"}}},
+		{"/feed/rss/items", false, "/feed/@name/items", Params{Param{"name", "rss"}}},
+		{"/users/42", false, "/users/@id([0-9]+)", Params{Param{"id", "42"}}},
+		{"/users/gopher/posts", false, "/users/@name([a-z]+)/posts", Params{Param{"name", "gopher"}}},
+		{"/users/gopher", true, "", Params{}},
+		{"/users/Gopher", true, "", Params{}},
+	})
+
+	checkPriorities(t, tree)
+
+	for _, find := range []func(n *node, path string, fixTrailingSlash bool) (string, bool){
+		(*node).findCaseInsensitivePath,
+		(*node).findCaseFoldPath,
+	} {
+		if out, found := find(tree, "/FEED/RSS", true); !found || out != "/feed/rss" {
+			t.Errorf("Wrong result for '/FEED/RSS': got %s, %t", out, found)
+		}
+		if out, found := find(tree, "/FEED/Xml/ITEMS", true); !found || out != "/feed/Xml/items" {
+			t.Errorf("Wrong result for '/FEED/Xml/ITEMS': got %s, %t", out, found)
+		}
+	}
+
+	for _, route := range routes {
+		if n := tree.patternNode(route); n == nil || n.handle == nil {
+			t.Errorf("no node for pattern '%s'", route)
+		}
+	}
+
+	for _, route := range []string{
+		"/feed/@other",          // second param without constraint
+		"/feed/@kind{json|xml}", // overlapping enum
+		"/feed/*path",           // catch-all
+		"/feed/static",          // static
+	} {
+		recv := catchPanic(func() {
+			tree.addRoute(route, nil)
+		})
+		if recv == nil {
+			t.Errorf("no panic while inserting conflicting route '%s'", route)
+		}
+	}
+}
+
+func TestMaskConstraints(t *testing.T) {
+	tests := []struct {
+		path, masked string
+	}{
+		{"/users/@id", "/users/@id"},
+		{"/users/@id([0-9]*)/x", "/users/@id________/x"},
+		{"/items/@page(a?)?=1", "/items/@page____?=1"},
+		{"/s/@state{a|b}/*path", "/s/@state_____/*path"},
+		{"/o/@id<int>?", "/o/@id_____?"},
+	}
+	for _, test := range tests {
+		if masked := maskConstraints(test.path); masked != test.masked {
+			t.Errorf("maskConstraints(%q) = %q, want %q", test.path, masked, test.masked)
+		}
+	}
+}
+
+func TestTreePatternNode(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/",
+		"/cmd/@tool/@sub",
+		"/cmd/@tool/",
+		"/src/*filepath",
+		"/search/",
+		"/search/@query",
+		"/user_@name",
+		"/user_@name/about",
+		"/files/@dir/*filepath",
+		"/doc/go_faq.html",
+		"/info/@user/public",
+		"/info/@user/project/@project",
+		"/user/@name:test",
+		"/user/@name/details:test",
+		"/f/@name.@ext",
+		"/f/@name/x",
+		"/g/@id.json",
+		"/g/@id.xml",
+		"/status/@state{open|closed}",
+	}
+	for _, route := range routes {
+		tree.addRoute(route, fakeHandler(route))
+	}
+
+	for _, route := range routes {
+		n := tree.patternNode(route)
+		if n == nil || n.handle == nil {
+			t.Errorf("no node with handle found for route '%s'", route)
+			continue
+		}
+		n.handle(nil, nil, nil)
+		if fakeHandlerValue != route {
+			t.Errorf("wrong node found for route '%s': handle of '%s'", route, fakeHandlerValue)
+		}
+	}
+
+	for _, path := range []string{"/cmd/@other/", "/nothing", "/src/*other", "/g/@id.yaml"} {
+		if n := tree.patternNode(path); n != nil && n.handle != nil {
+			t.Errorf("unexpected node found for '%s'", path)
+		}
+	}
+}
+
+func TestTreeLookupDepth(t *testing.T) {
+	tree := &node{}
+	routes := [...]string{
+		"/",
+		"/hi",
+		"/users/@id",
+		"/users/@id/posts/@post",
+		"/static/*filepath",
+	}
+	for _, route := range routes {
+		tree.addRoute(route, fakeHandler(route))
+	}
+
+	tests := []struct {
+		path  string
+		depth int
+	}{
+		{"/", 1},
+		{"/hi", 2},
+		{"/users/1", 3},
+		{"/users/1/posts/2", 5},
+		{"/static/a/b", 4},
+		{"/missing", 1},
+	}
+	for _, test := range tests {
+		depth := 0
+		tree.getLeafDepth(test.path, nil, false, &depth)
+		if depth != test.depth {
+			t.Errorf("lookup of %s visited %d nodes, want %d", test.path, depth, test.depth)
+		}
+	}
+}
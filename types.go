@@ -0,0 +1,142 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import "strings"
+
+// paramTypes are the types of named parameters, like @id<int>, by name.
+// Their checks don't use regular expressions, so they are cheaper than the
+// equivalent regular expressions.
+var paramTypes = map[string]func(value string) bool{
+	"int":   isInt,
+	"uint":  isUint,
+	"alpha": isAlpha,
+	"alnum": isAlnum,
+	"hex":   isHex,
+	"uuid":  isUUID,
+}
+
+// RegisterConstraint registers a type of named parameters, so that it can be
+// referenced in the paths of routes like the built-in types:
+//
+//	router.RegisterConstraint("slug", isSlug)
+//	router.GET("/articles/@slug<slug>", showArticle)
+//
+// Like the built-in types, the check is evaluated while matching the request
+// path, so requests with a value it rejects never reach the handler.
+// The type must be registered before the routes using it.
+// It panics if the name is empty, contains a '>' or is already registered or
+// built in.
+func (r *Router) RegisterConstraint(name string, check func(value string) bool) {
+	if name == "" || strings.IndexByte(name, '>') >= 0 {
+		panic("invalid constraint name '" + name + "'")
+	}
+	if check == nil {
+		panic("constraint check must not be nil")
+	}
+	if _, ok := paramTypes[name]; ok {
+		panic("a constraint named '" + name + "' is built in")
+	}
+	if _, ok := r.constraints[name]; ok {
+		panic("a constraint named '" + name + "' is already registered")
+	}
+	if r.constraints == nil {
+		r.constraints = make(map[string]func(value string) bool)
+	}
+	r.constraints[name] = check
+}
+
+// isInt reports whether value is a decimal integer with an optional sign.
+func isInt(value string) bool {
+	if len(value) > 0 && (value[0] == '-' || value[0] == '+') {
+		value = value[1:]
+	}
+	return isUint(value)
+}
+
+// isUint reports whether value is a decimal integer without a sign.
+func isUint(value string) bool {
+	if value == "" {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		if !isDigit(value[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isAlpha reports whether value consists of ASCII letters.
+func isAlpha(value string) bool {
+	if value == "" {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		if !isLetter(value[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isAlnum reports whether value consists of ASCII letters and digits.
+func isAlnum(value string) bool {
+	if value == "" {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		if !isLetter(value[i]) && !isDigit(value[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isHex reports whether value consists of hexadecimal digits.
+func isHex(value string) bool {
+	if value == "" {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		if !isHexDigit(value[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isUUID reports whether value is a UUID in its canonical textual form,
+// e.g. 123e4567-e89b-12d3-a456-426614174000.
+func isUUID(value string) bool {
+	if len(value) != 36 {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if value[i] != '-' {
+				return false
+			}
+		default:
+			if !isHexDigit(value[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
@@ -0,0 +1,104 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParamTypes(t *testing.T) {
+	tests := []struct {
+		typ   string
+		value string
+		valid bool
+	}{
+		{"int", "0", true},
+		{"int", "+12", true},
+		{"int", "-12", true},
+		{"int", "", false},
+		{"int", "1.5", false},
+		{"uint", "12", true},
+		{"uint", "+12", false},
+		{"alpha", "abcXYZ", true},
+		{"alpha", "ab1", false},
+		{"alnum", "ab1", true},
+		{"alnum", "ab_1", false},
+		{"hex", "09afAF", true},
+		{"hex", "0x1f", false},
+		{"uuid", "123E4567-E89B-12D3-A456-426614174000", true},
+		{"uuid", "123e4567e89b12d3a456426614174000", false},
+		{"uuid", "", false},
+	}
+	for _, test := range tests {
+		if valid := paramTypes[test.typ](test.value); valid != test.valid {
+			t.Errorf("%s(%q) = %v, want %v", test.typ, test.value, valid, test.valid)
+		}
+	}
+}
+
+func TestRouterRegisterConstraint(t *testing.T) {
+	var params Params
+	handle := func(_ http.ResponseWriter, _ *http.Request, ps Params) {
+		params = ps
+	}
+	isSlug := func(value string) bool {
+		if value == "" || value[0] == '-' || value[len(value)-1] == '-' {
+			return false
+		}
+		for i := 0; i < len(value); i++ {
+			if value[i] != '-' && !isLetter(value[i]) && !isDigit(value[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	router := New()
+	router.RegisterConstraint("slug", isSlug)
+	article := router.GET("/articles/@slug<slug>", handle)
+	router.GET("/posts/@id<int>/@slug<slug>?", handle)
+
+	tests := []struct {
+		route  string
+		code   int
+		params Params
+	}{
+		{"/articles/hello-world", http.StatusOK, Params{{"slug", "hello-world"}}},
+		{"/articles/-hello", http.StatusNotFound, nil},
+		{"/articles/hello_world", http.StatusNotFound, nil},
+		{"/posts/7/hello-world", http.StatusOK, Params{{"id", "7"}, {"slug", "hello-world"}}},
+		{"/posts/7", http.StatusOK, Params{{"id", "7"}}},
+	}
+	for _, test := range tests {
+		params = nil
+		r, _ := http.NewRequest(http.MethodGet, test.route, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.code || !reflect.DeepEqual(params, test.params) {
+			t.Errorf("routing %s failed: Code=%d, Params=%v", test.route, w.Code, params)
+		}
+	}
+
+	if _, err := article.URL(Params{{"slug", "hello_world"}}); err == nil {
+		t.Error("no error for a value rejected by the constraint")
+	}
+
+	for name, register := range map[string]func(){
+		"empty name":  func() { router.RegisterConstraint("", isSlug) },
+		"invalid":     func() { router.RegisterConstraint("a>b", isSlug) },
+		"nil check":   func() { router.RegisterConstraint("x", nil) },
+		"built in":    func() { router.RegisterConstraint("int", isSlug) },
+		"duplicate":   func() { router.RegisterConstraint("slug", isSlug) },
+		"unknown":     func() { router.GET("/x/@id<unknown>", handle) },
+		"other route": func() { New().GET("/articles/@slug<slug>", handle) },
+	} {
+		if catchPanic(register) == nil {
+			t.Errorf("no panic for %s", name)
+		}
+	}
+}